@@ -0,0 +1,157 @@
+package awc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gocloud.dev/blob"
+	// the bucket kinds -archive-url can name
+	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/gcsblob"
+	_ "gocloud.dev/blob/s3blob"
+)
+
+// archive is a download kept for upload to Downloader.ArchiveURL: the
+// response as it came, compressed, spooled to a temporary file.
+type archive struct {
+	file     *os.File
+	key      string
+	uploaded bool
+}
+
+// archiveKey is where a download of rawURL at t is archived: under the day,
+// with the time added to the file name, like
+// 2024/03/01/metars.cache-20240301T120500Z.csv.gz.  Uncompressed downloads
+// are gzipped, and named so.
+func archiveKey(rawURL string, t time.Time, compressed bool) string {
+	name := "download"
+	if u, err := url.Parse(rawURL); err == nil && strings.Trim(u.Path, "/") != "" {
+		name = path.Base(u.Path)
+	}
+	if !compressed {
+		name += ".gz"
+	}
+	base, ext, _ := strings.Cut(name, ".")
+	if ext != "" {
+		ext = "." + ext
+	}
+	t = t.UTC()
+	return fmt.Sprintf("%s/%s-%s%s", t.Format("2006/01/02"), base, t.Format("20060102T150405Z"), ext)
+}
+
+// spool copies the raw response body r of rawURL to a temporary file, kept
+// until the download is closed, gzipping it if it isn't compressed already.
+// It returns the file to read the response from.  With d.ArchiveBefore, the
+// file is uploaded straight away.
+func (d *Downloader) spool(ctx context.Context, rawURL string, r io.Reader) (io.Reader, error) {
+	d.discardArchive()
+	file, err := os.CreateTemp("", "archive-*")
+	if err != nil {
+		return nil, errPermanent{fmt.Errorf("creating archive file: %w", err)}
+	}
+	a := &archive{file: file}
+	d.archived = a
+	head := make([]byte, len(zstdMagic))
+	n, _ := io.ReadFull(r, head)
+	head = head[:n]
+	r = io.MultiReader(bytes.NewReader(head), r)
+	compressed := bytes.HasPrefix(head, gzipMagic) || bytes.HasPrefix(head, zstdMagic)
+	if compressed {
+		_, err = io.Copy(file, r)
+	} else {
+		zw := gzip.NewWriter(file)
+		if _, err = io.Copy(zw, r); err == nil {
+			err = zw.Close()
+		}
+	}
+	if err != nil {
+		d.discardArchive()
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	a.key = archiveKey(rawURL, time.Now(), compressed)
+	if d.ArchiveBefore {
+		if err := d.Archive(ctx); err != nil {
+			d.discardArchive()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		d.discardArchive()
+		return nil, errPermanent{err}
+	}
+	if compressed {
+		return file, nil
+	}
+	// hand back what came, not the gzipped copy
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		d.discardArchive()
+		return nil, errPermanent{err}
+	}
+	return zr, nil
+}
+
+// discardArchive removes the spooled download, archived or not.
+func (d *Downloader) discardArchive() {
+	if d.archived != nil {
+		d.archived.file.Close()
+		os.Remove(d.archived.file.Name())
+		d.archived = nil
+	}
+}
+
+// discardOnClose is a download which removes its spooled copy when closed.
+type discardOnClose struct {
+	io.ReadCloser
+	d *Downloader
+}
+
+func (r *discardOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.d.discardArchive()
+	return err
+}
+
+// Archive uploads the last download to d.ArchiveURL, if it's set and the
+// download hasn't been uploaded already.  Unless d.ArchiveBefore is set, call
+// it once the download has been processed and before closing it, as with
+// SaveValidators, so that only files that were ingested are archived.
+func (d *Downloader) Archive(ctx context.Context) error {
+	a := d.archived
+	if d.ArchiveURL == "" || a == nil || a.uploaded {
+		return nil
+	}
+	bucket, err := blob.OpenBucket(ctx, d.ArchiveURL)
+	if err != nil {
+		return fmt.Errorf("opening archive bucket: %w", err)
+	}
+	defer bucket.Close()
+	// canceling rather than closing the writer abandons a partial upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w, err := bucket.NewWriter(ctx, a.key, nil)
+	if err != nil {
+		return fmt.Errorf("archiving %s: %w", a.key, err)
+	}
+	// read at offsets, leaving the file where the scraper is reading it
+	if _, err := io.Copy(w, io.NewSectionReader(a.file, 0, 1<<62)); err != nil {
+		cancel()
+		w.Close()
+		return fmt.Errorf("archiving %s: %w", a.key, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("archiving %s: %w", a.key, err)
+	}
+	a.uploaded = true
+	slog.Info("archived download", "key", a.key)
+	return nil
+}
@@ -0,0 +1,528 @@
+// Package awc fetches data from aviationweather.gov, the Aviation Weather
+// Center: downloading and decompressing cache files and data API responses,
+// with retries, conditional requests and archiving, and checking the cache
+// files' preamble.
+package awc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrHTMLResponse is returned when the data server answers with a web page
+// rather than a compressed file, which it does during outages.
+var ErrHTMLResponse = errors.New("server returned HTML instead of data, likely an outage")
+
+// ErrNotModified is returned when the server says the file hasn't changed
+// since the validators in Downloader.ValidatorFile were saved.  Nothing is
+// written to the file.
+var ErrNotModified = errors.New("not modified since the last download")
+
+// ErrNoContent is returned when the data API answers a query with 204 No
+// Content, which it does when there are no reports to return.
+var ErrNoContent = errors.New("no content")
+
+// Downloader downloads and decompresses cache files, retrying transient
+// failures.
+type Downloader struct {
+	// BadResponseFile, if set, is where an HTML response is saved.
+	BadResponseFile string
+	// MaxAttempts is how many times to try; 0 or 1 means no retries.
+	MaxAttempts int
+	// Backoff is the wait before the first retry.  It doubles on each retry
+	// after that, up to MaxBackoff if that's set, and each wait has jitter of
+	// up to half of it so that many scrapers don't retry in lockstep.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// ValidatorFile, if set, holds the ETag and Last-Modified of the last
+	// download that was successfully processed, which are sent with the next
+	// request so that an unchanged file gets a 304 instead.
+	ValidatorFile string
+	// ArchiveURL, if set, is a bucket URL like
+	// s3://bucket?region=...&prefix=metars/, gs://bucket?prefix=metars/ or
+	// file:///dir that each download is archived to, compressed and named
+	// for when it was downloaded.
+	ArchiveURL string
+	// ArchiveBefore uploads each download before it's read, rather than
+	// leaving it to Archive once it has been processed.
+	ArchiveBefore bool
+	// Fallbacks are urls tried in turn by Input when the one it's given
+	// still fails after retrying, like mirrors of the file or another
+	// source of the same data.
+	Fallbacks []string
+	// Client, if set, sends the requests, and Timeout, HeaderTimeout and
+	// Proxy are ignored.
+	Client *http.Client
+	// Timeout bounds each request, including reading the whole response, and
+	// HeaderTimeout the wait for the response to start.  0 means no limit.
+	Timeout       time.Duration
+	HeaderTimeout time.Duration
+	// Proxy, if set, is the URL of the proxy requests go through, rather
+	// than the one HTTPS_PROXY or HTTP_PROXY gives.
+	Proxy string
+	// UserAgent is sent with each request, or DefaultUserAgent if it's
+	// empty.
+	UserAgent string
+	// Resume keeps the .partial file of a download to a file that fails
+	// partway, and continues it with a range request on the next attempt or
+	// run, as long as the server's file hasn't changed in between.
+	Resume bool
+
+	// client is Client, or the client built from the other settings.
+	client *http.Client
+	// archived is the last download, kept for Archive.
+	archived *archive
+	// pending is the validators of the file just downloaded, saved to
+	// ValidatorFile by SaveValidators.
+	pending *validators
+}
+
+// validators are the cache validators for one url.
+type validators struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// loadValidators reads d.ValidatorFile, returning nil if it's unset, missing
+// or for a different url.
+func (d *Downloader) loadValidators(url string) (*validators, error) {
+	if d.ValidatorFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(d.ValidatorFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	v := &validators{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", d.ValidatorFile, err)
+	}
+	if v.URL != url {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// SaveValidators records the validators of the last download in
+// d.ValidatorFile.  Call it once the file has been processed, so that a run
+// which fails partway through downloads the file again next time rather than
+// getting a 304.
+func (d *Downloader) SaveValidators() error {
+	if d.ValidatorFile == "" || d.pending == nil {
+		return nil
+	}
+	data, err := json.Marshal(d.pending)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.ValidatorFile, data, 0666)
+}
+
+// AddFlags registers flags for d's settings on fs.
+func (d *Downloader) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&d.BadResponseFile, "bad-response-file", "", "if set, an HTML response from the server is saved here for debugging")
+	fs.IntVar(&d.MaxAttempts, "max-attempts", 3, "times to try the download before giving up; network errors, 5xx and 429 responses and outage pages are retried, waiting at least as long as a Retry-After header asks")
+	fs.DurationVar(&d.Backoff, "retry-backoff", 2*time.Second, "wait before the first retry, doubling for each one after")
+	fs.DurationVar(&d.MaxBackoff, "retry-max-backoff", time.Minute, "longest wait between retries")
+	fs.Func("fallback-url", "url to download from if the usual one fails, like a mirror or another source of the same data in a format the scraper reads; may be repeated to try each in turn", func(url string) error {
+		d.Fallbacks = append(d.Fallbacks, url)
+		return nil
+	})
+	fs.DurationVar(&d.Timeout, "http-timeout", 5*time.Minute, "longest a download may take, including reading the whole file, which for a streamed download includes writing it to the database; 0 for no limit")
+	fs.DurationVar(&d.HeaderTimeout, "http-header-timeout", 30*time.Second, "longest to wait for the server to start responding; 0 for no limit")
+	fs.StringVar(&d.Proxy, "proxy", "", "if set, url of the proxy to download through, like http://proxy:3128; otherwise HTTPS_PROXY and HTTP_PROXY are used")
+	fs.StringVar(&d.UserAgent, "user-agent", DefaultUserAgent, "User-Agent to download with; AWC asks for one that identifies the scraper and how to reach its operator")
+	fs.StringVar(&d.ArchiveURL, "archive-url", "", "if set, keep each downloaded file, compressed and timestamped, in this bucket, like s3://bucket?region=us-east-1&prefix=metars/, gs://bucket?prefix=metars/ or file:///dir, so it can be ingested again later")
+	fs.BoolVar(&d.ArchiveBefore, "archive-before", false, "if set with -archive-url, archive each file before ingesting it, rather than only once it has been ingested")
+	fs.BoolVar(&d.Resume, "resume", false, "if set with -filename, keep the .partial file of a download that fails partway and continue it on the next attempt or run, if the server supports range requests and the file hasn't changed")
+	fs.StringVar(&d.ValidatorFile, "validator-file", "", "if set, the ETag and Last-Modified of each processed download are kept here and the run is skipped when the server reports the file unchanged")
+}
+
+// DefaultUserAgent is the User-Agent sent unless -user-agent is set.
+const DefaultUserAgent = "mattdee123.com/aviationweather scraper"
+
+// httpClient returns the client to send requests with, building it the first
+// time from d's settings.
+func (d *Downloader) httpClient() (*http.Client, error) {
+	if d.Client != nil {
+		return d.Client, nil
+	}
+	if d.client != nil {
+		return d.client, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = d.HeaderTimeout
+	if d.Proxy != "" {
+		proxy, err := neturl.Parse(d.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("bad -proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	d.client = &http.Client{Transport: transport, Timeout: d.Timeout}
+	return d.client, nil
+}
+
+// tracer is used for the spans around downloads.
+var tracer = otel.Tracer("mattdee123.com/aviationweather/awc")
+
+// errPermanent marks a download error that retrying won't fix.
+type errPermanent struct{ error }
+
+func (e errPermanent) Unwrap() error { return e.error }
+
+// errRetryAfter is a download error from a response with a Retry-After
+// header, saying how long to wait before trying again.
+type errRetryAfter struct {
+	error
+	wait time.Duration
+}
+
+func (e errRetryAfter) Unwrap() error { return e.error }
+
+// retryAfter parses a Retry-After header, which is either a number of
+// seconds or a date, returning 0 if it's missing or bad.
+func retryAfter(header string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// Download downloads and decompresses url into filename, retrying as
+// configured.  The download is kept in filename.partial until it's complete,
+// and filename is replaced in one step, so it's only ever a whole file.
+func (d *Downloader) Download(ctx context.Context, url, filename string) error {
+	return d.retry(ctx, func() error {
+		return d.downloadOnce(ctx, url, filename)
+	})
+}
+
+// Open starts downloading url and returns the decompressed body, for reading
+// straight into the database without a file in between.  Only the request
+// is retried; an error partway through the body comes from Read.
+func (d *Downloader) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := d.retry(ctx, func() error {
+		var err error
+		body, err = d.openOnce(ctx, url)
+		return err
+	})
+	if err != nil {
+		d.discardArchive()
+	} else if d.ArchiveURL != "" {
+		body = &discardOnClose{body, d}
+	}
+	return body, err
+}
+
+// Input opens what a scraper run reads.  With download set, url is streamed
+// straight through if filename is empty, or downloaded to filename first
+// otherwise, which keeps the file around for debugging, and if that fails,
+// each of d.Fallbacks is tried in turn.  Without download, filename is read
+// as is.  It's traced as a download span, which for a
+// stream ends once the response has started.
+func (d *Downloader) Input(ctx context.Context, download bool, url, filename string) (io.ReadCloser, error) {
+	ctx, span := tracer.Start(ctx, "download", trace.WithAttributes(
+		attribute.String("url.full", url),
+		attribute.String("file.path", filename),
+		attribute.Bool("download", download)))
+	defer span.End()
+	r, err := d.input(ctx, download, url, filename)
+	if err != nil && !errors.Is(err, ErrNotModified) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return r, err
+}
+
+func (d *Downloader) input(ctx context.Context, download bool, url, filename string) (io.ReadCloser, error) {
+	if !download {
+		return d.inputFrom(ctx, false, url, filename)
+	}
+	r, err := d.inputFrom(ctx, true, url, filename)
+	for _, fallback := range d.Fallbacks {
+		if err == nil || errors.Is(err, ErrNotModified) || ctx.Err() != nil {
+			break
+		}
+		slog.Warn("download failed, trying a fallback", "url", url, "fallback", fallback, "err", err)
+		url = fallback
+		if r, err = d.inputFrom(ctx, true, url, filename); err == nil {
+			slog.Info("downloaded from a fallback", "url", url)
+		}
+	}
+	return r, err
+}
+
+func (d *Downloader) inputFrom(ctx context.Context, download bool, url, filename string) (io.ReadCloser, error) {
+	switch {
+	case download && filename == "":
+		return d.Open(ctx, url)
+	case download:
+		if err := d.Download(ctx, url, filename); err != nil {
+			d.discardArchive()
+			return nil, fmt.Errorf("downloading file: %w", err)
+		}
+	case filename == "":
+		return nil, errors.New("-filename is required with -download=false")
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		d.discardArchive()
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	if download && d.ArchiveURL != "" {
+		return &discardOnClose{file, d}, nil
+	}
+	return file, nil
+}
+
+// retry calls attempt until it succeeds, fails permanently or runs out of
+// attempts, backing off in between.
+func (d *Downloader) retry(ctx context.Context, attempt func() error) error {
+	backoff := d.Backoff
+	for n := 1; ; n++ {
+		err := attempt()
+		var permanent errPermanent
+		if err == nil || errors.As(err, &permanent) || n >= d.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		wait := backoff/2 + rand.N(backoff/2+1)
+		var after errRetryAfter
+		if errors.As(err, &after) && after.wait > wait {
+			// the server knows best, within MaxBackoff
+			wait = after.wait
+			if d.MaxBackoff > 0 {
+				wait = min(wait, d.MaxBackoff)
+			}
+		}
+		slog.Warn("download attempt failed, retrying", "attempt", n, "wait", wait.Round(time.Millisecond), "err", err)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", n),
+			attribute.String("error", err.Error())))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if d.MaxBackoff > 0 {
+			backoff = min(backoff, d.MaxBackoff)
+		}
+	}
+}
+
+// responseReader is a decompressed response body which closes the response
+// when done.
+type responseReader struct {
+	io.Reader
+	body io.Closer
+}
+
+func (r *responseReader) Close() error {
+	if c, ok := r.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return r.body.Close()
+}
+
+func (d *Downloader) openOnce(ctx context.Context, url string) (io.ReadCloser, error) {
+	resp, body, err := d.get(ctx, url, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			resp.Body.Close()
+		}
+	}()
+	var raw io.Reader = body
+	if d.ArchiveURL != "" {
+		if raw, err = d.spool(ctx, url, body); err != nil {
+			return nil, err
+		}
+	}
+	reader, err := Decompress(url, raw)
+	if err != nil {
+		return nil, err
+	}
+	d.pending = responseValidators(url, resp)
+	ok = true
+	return &responseReader{reader, resp.Body}, nil
+}
+
+// errBadRange is returned when a resumed download's range request can't be
+// satisfied, so that the next attempt starts over.
+var errBadRange = errors.New("server can't resume the download")
+
+// get requests url and checks the response, returning it with its raw body
+// buffered.  With offset set, only the rest of the file from offset on is
+// asked for, if it's still the version ifRange, an ETag or Last-Modified,
+// identifies; otherwise the server sends it all, with a 200.
+func (d *Downloader) get(ctx context.Context, url string, offset int64, ifRange string) (*http.Response, *bufio.Reader, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, errPermanent{err}
+	}
+	previous, err := d.loadValidators(url)
+	if err != nil {
+		return nil, nil, errPermanent{fmt.Errorf("reading validators: %w", err)}
+	}
+	if previous != nil {
+		if previous.ETag != "" {
+			req.Header.Set("If-None-Match", previous.ETag)
+		}
+		if previous.LastModified != "" {
+			req.Header.Set("If-Modified-Since", previous.LastModified)
+		}
+	}
+	if offset > 0 {
+		// net/http leaves a ranged response's encoding alone, so offsets are
+		// into the file as served
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", ifRange)
+	}
+	userAgent := d.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	client, err := d.httpClient()
+	if err != nil {
+		return nil, nil, errPermanent{err}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			resp.Body.Close()
+		}
+	}()
+	if resp.StatusCode == http.StatusNotModified && previous != nil {
+		return nil, nil, errPermanent{ErrNotModified}
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil, errPermanent{ErrNoContent}
+	}
+	if offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return nil, nil, errBadRange
+	}
+	if resp.StatusCode != 200 && (offset == 0 || resp.StatusCode != http.StatusPartialContent) {
+		err := fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return nil, nil, errPermanent{err}
+		}
+		if wait := retryAfter(resp.Header.Get("Retry-After"), time.Now()); wait > 0 {
+			return nil, nil, errRetryAfter{err, wait}
+		}
+		return nil, nil, err
+	}
+	body := bufio.NewReader(resp.Body)
+	if start, _ := body.Peek(512); isHTML(resp.Header.Get("Content-Type"), start) {
+		if d.BadResponseFile != "" {
+			if err := saveResponse(body, d.BadResponseFile); err != nil {
+				slog.Warn("saving bad response", "file", d.BadResponseFile, "err", err)
+			}
+		}
+		return nil, nil, ErrHTMLResponse
+	}
+	ok = true
+	return resp, body, nil
+}
+
+// responseValidators returns the cache validators resp gives for url.
+func responseValidators(url string, resp *http.Response) *validators {
+	return &validators{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// isHTML reports whether a response looks like a web page rather than a
+// compressed data file, based on its content type and first bytes.
+func isHTML(contentType string, start []byte) bool {
+	if bytes.HasPrefix(start, gzipMagic) || bytes.HasPrefix(start, zstdMagic) {
+		return false
+	}
+	if strings.HasPrefix(contentType, "text/html") {
+		return true
+	}
+	start = bytes.ToLower(bytes.TrimSpace(start))
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html"))
+}
+
+func saveResponse(r io.Reader, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, r)
+	return err
+}
+
+// Decompress wraps r in a gzip or zstd reader.  The format is taken from the
+// stream's magic bytes, falling back to the url's suffix.  A stream with
+// neither, such as a plain text product, is returned as is.
+func Decompress(url string, r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	useZstd := strings.HasSuffix(url, ".zst")
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		useZstd = true
+	case bytes.HasPrefix(magic, gzipMagic):
+		useZstd = false
+	case !useZstd && !strings.HasSuffix(url, ".gz"):
+		return br, nil
+	}
+	if useZstd {
+		reader, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("zstd error: %w", err)
+		}
+		return reader.IOReadCloser(), nil
+	}
+	reader, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("gzip error: %w", err)
+	}
+	return reader, nil
+}
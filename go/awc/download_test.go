@@ -0,0 +1,69 @@
+package awc_test
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+func TestDecompress(t *testing.T) {
+	for _, test := range []struct {
+		fixture, url string
+	}{
+		{"testdata/metars.cache.csv.gz", "https://example.com/metars.cache.csv.gz"},
+		{"testdata/metars.cache.csv.zst", "https://example.com/metars.cache.csv.zst"},
+		// detected by magic bytes when the url doesn't say
+		{"testdata/metars.cache.csv.gz", "https://example.com/metars"},
+		{"testdata/metars.cache.csv.zst", "https://example.com/metars"},
+		// and the magic bytes win over a wrong suffix
+		{"testdata/metars.cache.csv.zst", "https://example.com/metars.cache.csv.gz"},
+	} {
+		f, err := os.Open(test.fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		r, err := awc.Decompress(test.url, f)
+		if err != nil {
+			t.Errorf("%s from %s: %v", test.fixture, test.url, err)
+			continue
+		}
+		records, err := metarcsv.Open(r, "csv", nil)
+		if err != nil {
+			t.Errorf("%s from %s: %v", test.fixture, test.url, err)
+			continue
+		}
+		var stations []string
+		for records.Next() {
+			_, obs, err := records.Record()
+			if err != nil {
+				t.Fatalf("%s from %s: %v", test.fixture, test.url, err)
+			}
+			stations = append(stations, obs.Station)
+		}
+		if err := records.Err(); err != nil {
+			t.Errorf("%s from %s: %v", test.fixture, test.url, err)
+		}
+		if got := strings.Join(stations, " "); got != "KJFK EGLL" {
+			t.Errorf("%s from %s: stations %q, want KJFK EGLL", test.fixture, test.url, got)
+		}
+	}
+}
+
+func TestDecompressPlain(t *testing.T) {
+	r, err := awc.Decompress("https://example.com/metars.cache.csv", strings.NewReader("No errors\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "No errors\n" {
+		t.Errorf("plain text read as %q", got)
+	}
+}
@@ -0,0 +1,119 @@
+package awc
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Preamble returns patterns for the lines that precede the column header in
+// a cache file for the given data source, e.g. "metars".
+func Preamble(source string) []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile("^No errors$"),
+		regexp.MustCompile("^No warnings$"),
+		regexp.MustCompile("^[0-9]* ms$"),
+		regexp.MustCompile("^data source=" + regexp.QuoteMeta(source) + "$"),
+		resultsLine,
+	}
+}
+
+// resultsLine is the last preamble line, giving the number of data lines
+// after the header.
+var resultsLine = regexp.MustCompile("^([0-9]*) results$")
+
+// ResultCount returns the number of results a preamble's "N results" line
+// gives, and false if line isn't one.
+func ResultCount(line string) (int, bool) {
+	match := resultsLine.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(match[1])
+	return n, err == nil
+}
+
+// CheckLines reads a line from scanner for each of patterns and checks that
+// it matches.
+func CheckLines(patterns []*regexp.Regexp, scanner *bufio.Scanner) error {
+	for _, pattern := range patterns {
+		if !scanner.Scan() {
+			return fmt.Errorf("scan error while looking for %v: %w", pattern, scanner.Err())
+		}
+		if text := scanner.Text(); !pattern.MatchString(text) {
+			return fmt.Errorf("expected %v, got %q", pattern, text)
+		}
+	}
+	return nil
+}
+
+// maxPreambleLines bounds how far CheckPreamble looks for the end of a
+// lenient preamble, so that a file without one fails instead of being read
+// to the end.
+const maxPreambleLines = 100
+
+// CheckPreamble checks the preamble lines matching patterns, as CheckLines
+// does.  If lenient is set, lines that don't match, like an upstream warning
+// count and the warnings themselves, are logged rather than failing the
+// check, and it reads up to the line matching the last of patterns.
+func CheckPreamble(patterns []*regexp.Regexp, scanner *bufio.Scanner, lenient bool) error {
+	if !lenient {
+		return CheckLines(patterns, scanner)
+	}
+	last := patterns[len(patterns)-1]
+	for range maxPreambleLines {
+		if !scanner.Scan() {
+			return fmt.Errorf("scan error while looking for %v: %w", last, scanner.Err())
+		}
+		text := scanner.Text()
+		if last.MatchString(text) {
+			return nil
+		}
+		matched := false
+		for _, pattern := range patterns {
+			matched = matched || pattern.MatchString(text)
+		}
+		if !matched {
+			slog.Warn("unexpected preamble line", "line", text)
+		}
+	}
+	return fmt.Errorf("no line matching %v in the first %d lines", last, maxPreambleLines)
+}
+
+// ReadHeader checks the preamble for source and returns the names of the
+// columns in the header line that follows it.  It fails if any of required
+// is missing from the header.  lenient is as for CheckPreamble.
+func ReadHeader(scanner *bufio.Scanner, source string, lenient bool, required ...string) ([]string, error) {
+	if err := CheckPreamble(Preamble(source), scanner, lenient); err != nil {
+		return nil, err
+	}
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("scan error while looking for header: %w", scanner.Err())
+	}
+	columns := strings.Split(scanner.Text(), ",")
+	present := map[string]bool{}
+	for _, name := range columns {
+		present[name] = true
+	}
+	for _, name := range required {
+		if !present[name] {
+			return nil, fmt.Errorf("header %q is missing column %q", scanner.Text(), name)
+		}
+	}
+	return columns, nil
+}
+
+// ColumnIndex maps each column name to its index.  A repeated name maps to
+// its first occurrence.
+func ColumnIndex(columns []string) map[string]int {
+	index := map[string]int{}
+	for i, name := range columns {
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+	return index
+}
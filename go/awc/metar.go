@@ -0,0 +1,52 @@
+package awc
+
+import (
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetarCacheURL is the cache file of every current METAR, refreshed every
+// minute or so.
+const MetarCacheURL = "https://aviationweather.gov/data/cache/metars.cache.csv.gz"
+
+// MetarAPIURL is AWC's data API, which serves reports from further back than
+// the cache file.
+const MetarAPIURL = "https://aviationweather.gov/api/data/metar"
+
+// Query is a request to AWC's data API at MetarAPIURL.  Unset fields are
+// left out, so the API's defaults apply.
+type Query struct {
+	// Format is raw, csv, json or xml.
+	Format string
+	// IDs are the stations to return, or all stations if empty.
+	IDs []string
+	// BBox limits the stations to "min_lat,min_lon,max_lat,max_lon".
+	BBox string
+	// Hours is how far back from Date to return reports.
+	Hours int
+	// Date is the end of the range, or now if zero.
+	Date time.Time
+}
+
+// URL returns the query's url at base, usually MetarAPIURL.
+func (q Query) URL(base string) string {
+	query := neturl.Values{}
+	if q.Format != "" {
+		query.Set("format", q.Format)
+	}
+	if len(q.IDs) > 0 {
+		query.Set("ids", strings.Join(q.IDs, ","))
+	}
+	if q.BBox != "" {
+		query.Set("bbox", q.BBox)
+	}
+	if q.Hours > 0 {
+		query.Set("hours", strconv.Itoa(q.Hours))
+	}
+	if !q.Date.IsZero() {
+		query.Set("date", q.Date.UTC().Format(time.RFC3339))
+	}
+	return base + "?" + query.Encode()
+}
@@ -0,0 +1,171 @@
+package awc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// downloadOnce downloads url, as served, to filename.partial, continuing a
+// previous attempt's if d.Resume is set, then installs it in filename.
+func (d *Downloader) downloadOnce(ctx context.Context, url, filename string) error {
+	partial := filename + ".partial"
+	var offset int64
+	var previous *validators
+	if d.Resume {
+		offset, previous = resumable(url, partial)
+	}
+	ifRange := ""
+	if previous != nil {
+		ifRange = previous.ETag
+		if ifRange == "" {
+			ifRange = previous.LastModified
+		}
+	}
+	resp, body, err := d.get(ctx, url, offset, ifRange)
+	if errors.Is(err, errBadRange) {
+		removePartial(partial)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	current := responseValidators(url, resp)
+	if resp.StatusCode == http.StatusPartialContent {
+		var start int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+			removePartial(partial)
+			return fmt.Errorf("%w: asked for bytes from %d, got %q", errBadRange, offset, resp.Header.Get("Content-Range"))
+		}
+		slog.Info("resuming download", "file", partial, "offset", offset)
+		flags = os.O_WRONLY | os.O_APPEND
+		current = previous
+	} else if d.Resume {
+		if offset > 0 {
+			slog.Info("file changed since the partial download, starting over", "file", partial)
+		}
+		if err := savePartialValidators(partial, current); err != nil {
+			return errPermanent{err}
+		}
+	}
+	out, err := os.OpenFile(partial, flags, 0666)
+	if err != nil {
+		return errPermanent{fmt.Errorf("error creating file %q: %w", partial, err)}
+	}
+	_, err = io.Copy(out, body)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if !d.Resume {
+			// don't leave a partial file behind for the next attempt to trip over
+			removePartial(partial)
+		}
+		return fmt.Errorf("error writing to file: %w", err)
+	}
+	if err := d.install(ctx, url, partial, filename); err != nil {
+		return err
+	}
+	d.pending = current
+	return nil
+}
+
+// resumable returns the size of a previous download of url to partial, and
+// the validators of the version of the file it's the start of, or 0 and nil if
+// there isn't one that can be resumed.
+func resumable(url, partial string) (int64, *validators) {
+	info, err := os.Stat(partial)
+	if err != nil || info.Size() == 0 {
+		return 0, nil
+	}
+	data, err := os.ReadFile(partial + ".json")
+	if err != nil {
+		return 0, nil
+	}
+	v := &validators{}
+	if err := json.Unmarshal(data, v); err != nil || v.URL != url || v.ETag == "" && v.LastModified == "" {
+		return 0, nil
+	}
+	return info.Size(), v
+}
+
+// savePartialValidators records which version of the file partial is the
+// start of, for resumable, in partial.json.
+func savePartialValidators(partial string, v *validators) error {
+	if v.ETag == "" && v.LastModified == "" {
+		// without either, a resumed download could mix two versions
+		os.Remove(partial + ".json")
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(partial+".json", data, 0666)
+}
+
+func removePartial(partial string) {
+	os.Remove(partial)
+	os.Remove(partial + ".json")
+}
+
+// install decompresses the completed download in partial into filename, by
+// way of a temporary file next to it so that a stale or half-written filename
+// is replaced in one rename, and removes partial.
+func (d *Downloader) install(ctx context.Context, url, partial, filename string) error {
+	in, err := os.Open(partial)
+	if err != nil {
+		return errPermanent{err}
+	}
+	defer in.Close()
+	var raw io.Reader = in
+	if d.ArchiveURL != "" {
+		if raw, err = d.spool(ctx, url, in); err != nil {
+			return err
+		}
+	}
+	reader, err := Decompress(url, raw)
+	if err != nil {
+		removePartial(partial)
+		return err
+	}
+	if c, ok := reader.(io.Closer); ok {
+		defer c.Close()
+	}
+	out, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return errPermanent{fmt.Errorf("error creating file for %q: %w", filename, err)}
+	}
+	// a no-op once it's been renamed
+	defer os.Remove(out.Name())
+	_, err = io.Copy(out, reader)
+	if err == nil {
+		err = out.Chmod(0644)
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// most likely a corrupt download, which resuming won't fix
+		removePartial(partial)
+		return fmt.Errorf("error writing to file: %w", err)
+	}
+	if err := os.Rename(out.Name(), filename); err != nil {
+		return errPermanent{fmt.Errorf("error replacing %q: %w", filename, err)}
+	}
+	removePartial(partial)
+	return nil
+}
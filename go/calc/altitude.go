@@ -0,0 +1,39 @@
+// Package calc derives values pilots use from observed conditions.
+package calc
+
+import (
+	"math"
+
+	"mattdee123.com/aviationweather/units"
+)
+
+// standardAltimeterInHg is the altimeter setting of the ISA sea level
+// pressure.
+const standardAltimeterInHg = 29.92
+
+// PressureAltitudeFt returns the pressure altitude, in feet, of a station at
+// elevationFt with the given altimeter setting.  It uses the usual 1000 ft
+// per inHg approximation, which is good to a few tens of feet near the
+// surface.
+func PressureAltitudeFt(elevationFt, altimeterInHg float64) float64 {
+	return elevationFt + (standardAltimeterInHg-altimeterInHg)*1000
+}
+
+// DensityAltitudeFt returns the density altitude, in feet, for a pressure
+// altitude and outside air temperature, using the 120 ft per degree C
+// deviation from ISA rule of thumb.
+func DensityAltitudeFt(pressureAltitudeFt, tempC float64) float64 {
+	isaTempC := 15 - 2*pressureAltitudeFt/1000
+	return pressureAltitudeFt + 120*(tempC-isaTempC)
+}
+
+// MetersToFeet converts an elevation in metres, as given by the data server,
+// to feet.
+func MetersToFeet(m float64) float64 {
+	return units.MetersToFeet(m)
+}
+
+// RoundFt rounds an altitude to the nearest foot.
+func RoundFt(ft float64) int {
+	return int(math.Round(ft))
+}
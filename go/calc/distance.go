@@ -0,0 +1,37 @@
+package calc
+
+import "math"
+
+// earthRadiusNM is the mean radius of the earth in nautical miles.
+const earthRadiusNM = 3440.065
+
+// MetersPerNM is the length of a nautical mile in meters.
+const MetersPerNM = 1852
+
+// DistanceNM returns the great circle distance in nautical miles between two
+// points given in degrees, using the haversine formula.
+func DistanceNM(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusNM * math.Asin(math.Sqrt(math.Min(1, a)))
+}
+
+// BoundingBox returns the latitude and longitude ranges, in degrees, that
+// contain every point within radiusNM of lat, lon.  wraps is set if the
+// longitude range crosses the antimeridian or covers a pole, in which case
+// any longitude might be in range.
+func BoundingBox(lat, lon, radiusNM float64) (minLat, maxLat, minLon, maxLon float64, wraps bool) {
+	// a degree of latitude is 60 nm
+	dLat := radiusNM / 60
+	minLat, maxLat = lat-dLat, lat+dLat
+	if minLat <= -90 || maxLat >= 90 {
+		return math.Max(minLat, -90), math.Min(maxLat, 90), -180, 180, true
+	}
+	dLon := dLat / math.Cos(lat*math.Pi/180)
+	minLon, maxLon = lon-dLon, lon+dLon
+	wraps = minLon < -180 || maxLon > 180
+	return minLat, maxLat, minLon, maxLon, wraps
+}
@@ -0,0 +1,31 @@
+package calc
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// WindComponents splits a wind from windDirDeg into its components along and
+// across a runway with the given heading.  headwind is negative for a
+// tailwind, and crosswind is positive for a wind from the right and negative
+// for one from the left.  Both are in the wind speed's unit.
+func WindComponents(windDirDeg, windSpeed, runwayHeadingDeg float64) (headwind, crosswind float64) {
+	angle := (windDirDeg - runwayHeadingDeg) * math.Pi / 180
+	return windSpeed * math.Cos(angle), windSpeed * math.Sin(angle)
+}
+
+var runwayPattern = regexp.MustCompile(`^(0?[1-9]|[12][0-9]|3[0-6])[LCR]?$`)
+
+// RunwayHeading returns the heading a runway designator like 22L implies,
+// its number times ten degrees.  That's the magnetic heading rounded to the
+// nearest ten, so it's only approximate, and it's off by the magnetic
+// variation from the true direction winds are reported in.
+func RunwayHeading(designator string) (float64, bool) {
+	m := runwayPattern.FindStringSubmatch(designator)
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	return float64(n * 10), true
+}
@@ -0,0 +1,151 @@
+// Package client is a typed client of AWC's data API, for programs that want
+// recent reports as Go values rather than a database of them.  Requests are
+// retried as by awc.Downloader and spaced out to stay within the API's rate
+// limit.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// DefaultInterval keeps a Client within the data API's limit of 100
+// requests a minute.
+const DefaultInterval = time.Minute / 100
+
+// Client sends requests to the data API.  It's safe for concurrent use, but
+// requests are sent one at a time, at least Interval apart.
+type Client struct {
+	// MetarURL is the data API's METAR endpoint, or awc.MetarAPIURL if empty.
+	MetarURL string
+	// Downloader sends the requests and retries failed ones, waiting as long
+	// as a 429 response's Retry-After asks, within its MaxBackoff.  If nil, a
+	// default one tries 3 times with a one minute timeout.  Its cache
+	// validators and archiving aren't meant for the API and should be unset.
+	Downloader *awc.Downloader
+	// Interval is the least time between starting one request and the next,
+	// or DefaultInterval if 0.  A negative Interval sends them as fast as
+	// they come.
+	Interval time.Duration
+
+	mu sync.Mutex
+	// next is when the next request may start.
+	next time.Time
+	// defaultDownloader is used when Downloader is nil.
+	defaultDownloader *awc.Downloader
+}
+
+// Options selects the reports a request returns.  Unset fields are left to
+// the API's defaults, which for Metars means the latest report of each
+// station.
+type Options struct {
+	// IDs are ICAO station identifiers, like KSFO.
+	IDs []string
+	// BBox limits the stations to "min_lat,min_lon,max_lat,max_lon".
+	BBox string
+	// Hours is how far back from Date to return reports.
+	Hours int
+	// Date is the end of the range, or now if zero.
+	Date time.Time
+	// Format is what the API is asked to send: json, the default, csv or xml.
+	// The results are the same whichever it is.
+	Format string
+}
+
+// Metars returns the METARs and SPECIs opts selects, in the order the API
+// returns them, which is newest first for each station.  A query with no
+// reports returns none and no error.
+func (c *Client) Metars(ctx context.Context, opts Options) ([]*Metar, error) {
+	format := opts.Format
+	switch format {
+	case "":
+		format = "json"
+	case "json", "csv", "xml":
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	url := c.MetarURL
+	if url == "" {
+		url = awc.MetarAPIURL
+	}
+	query := awc.Query{Format: format, IDs: opts.IDs, BBox: opts.BBox, Hours: opts.Hours, Date: opts.Date}
+	var metars []*Metar
+	err := c.get(ctx, query.URL(url), format, func(obs *metarcsv.Observation) {
+		metars = append(metars, newMetar(obs))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching metars: %w", err)
+	}
+	return metars, nil
+}
+
+// get requests url, in format, and calls each with every observation in the
+// response.
+func (c *Client) get(ctx context.Context, url, format string, each func(obs *metarcsv.Observation)) error {
+	body, err := c.open(ctx, url)
+	if errors.Is(err, awc.ErrNoContent) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer body.Close()
+	records, err := metarcsv.Open(body, format, nil)
+	if err != nil {
+		return err
+	}
+	for records.Next() {
+		text, obs, err := records.Record()
+		if err != nil {
+			return fmt.Errorf("parsing %q: %w", text, err)
+		}
+		if obs != nil {
+			each(obs)
+		}
+	}
+	return records.Err()
+}
+
+// open waits until the next request may start, and starts it.
+func (c *Client) open(ctx context.Context, url string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if wait := time.Until(c.next); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	interval := c.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	defer func() {
+		// the retries in between are the Downloader's to pace
+		c.next = time.Now().Add(interval)
+	}()
+	return c.downloader().Open(ctx, url)
+}
+
+func (c *Client) downloader() *awc.Downloader {
+	if c.Downloader != nil {
+		return c.Downloader
+	}
+	if c.defaultDownloader == nil {
+		c.defaultDownloader = &awc.Downloader{
+			MaxAttempts: 3,
+			Backoff:     2 * time.Second,
+			MaxBackoff:  time.Minute,
+			Client:      &http.Client{Timeout: time.Minute},
+		}
+	}
+	return c.defaultDownloader
+}
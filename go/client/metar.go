@@ -0,0 +1,83 @@
+package client
+
+import (
+	"time"
+
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// Metar is a METAR or SPECI as the data API reports it.  Values the report
+// doesn't have are nil.
+type Metar struct {
+	Station         string
+	ObservationTime time.Time
+	RawText         string
+	// Type is METAR or SPECI.
+	Type       string
+	Latitude   *float64
+	Longitude  *float64
+	ElevationM *float64
+	TempC      *float64
+	DewpointC  *float64
+	// WindDirDegrees is nil for a variable wind, and 0 for a calm one.
+	WindDirDegrees *int
+	WindSpeedKt    *int
+	WindGustKt     *int
+	// VisibilityMi is the visibility in statute miles, with "10+" as 10.
+	VisibilityMi *float64
+	AltimInHg    *float64
+	// FlightCategory is VFR, MVFR, IFR or LIFR, as AWC gives it or, when it
+	// doesn't, as computed from RawText.  It's "" if neither says.
+	FlightCategory string
+	// Decoded is RawText decoded, or nil if it couldn't be.
+	Decoded *metar.METAR
+	// Observation is the report as read, for the columns not above, like
+	// Observation.Field(metarcsv.ColumnIndex["wx_string"]).
+	Observation *metarcsv.Observation
+}
+
+func newMetar(obs *metarcsv.Observation) *Metar {
+	m := &Metar{
+		Station:         obs.Station,
+		ObservationTime: obs.ObservationTime,
+		RawText:         obs.Field(metarcsv.ColRawText),
+		Type:            obs.Field(metarcsv.ColMetarType),
+		Latitude:        number(obs, metarcsv.ColLatitude),
+		Longitude:       number(obs, metarcsv.ColLongitude),
+		ElevationM:      number(obs, metarcsv.ColElevationM),
+		TempC:           number(obs, metarcsv.ColTempC),
+		DewpointC:       number(obs, metarcsv.ColDewpointC),
+		WindDirDegrees:  integer(obs, metarcsv.ColWindDirDegrees),
+		WindSpeedKt:     integer(obs, metarcsv.ColWindSpeedKt),
+		WindGustKt:      integer(obs, metarcsv.ColWindGustKt),
+		VisibilityMi:    number(obs, metarcsv.ColVisibilityMi),
+		AltimInHg:       number(obs, metarcsv.ColAltimInHg),
+		FlightCategory:  obs.Field(metarcsv.ColumnIndex["flight_category"]),
+		Observation:     obs,
+	}
+	if m.FlightCategory == "" {
+		m.FlightCategory = obs.FlightCategory()
+	}
+	if decoded, err := metar.Decode(m.RawText); err == nil {
+		m.Decoded = decoded
+	}
+	return m
+}
+
+func number(obs *metarcsv.Observation, i int) *float64 {
+	value, ok := obs.Number(i)
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+func integer(obs *metarcsv.Observation, i int) *int {
+	value, ok := obs.Number(i)
+	if !ok {
+		return nil
+	}
+	n := int(value)
+	return &n
+}
@@ -0,0 +1,88 @@
+package metar
+
+import "mattdee123.com/aviationweather/units"
+
+// Flight categories, from the FAA ceiling and visibility thresholds.
+const (
+	CategoryVFR  = "VFR"
+	CategoryMVFR = "MVFR"
+	CategoryIFR  = "IFR"
+	CategoryLIFR = "LIFR"
+)
+
+// FlightCategory returns the flight category for a ceiling in feet and a
+// visibility in statute miles, whichever is worse.  A nil ceiling is
+// unlimited.  It returns "" when both are unknown.
+func FlightCategory(ceilingFt *int, visibilityMi *float64) string {
+	if ceilingFt == nil && visibilityMi == nil {
+		return ""
+	}
+	ceiling := 100000
+	if ceilingFt != nil {
+		ceiling = *ceilingFt
+	}
+	visibility := 100.0
+	if visibilityMi != nil {
+		visibility = *visibilityMi
+	}
+	switch {
+	case ceiling < 500 || visibility < 1:
+		return CategoryLIFR
+	case ceiling < 1000 || visibility < 3:
+		return CategoryIFR
+	case ceiling <= 3000 || visibility <= 5:
+		return CategoryMVFR
+	}
+	return CategoryVFR
+}
+
+// CeilingFt returns the height of the lowest broken or overcast layer or
+// vertical visibility, or nil if there is none.
+func (c *Conditions) CeilingFt() *int {
+	var ceiling *int
+	for _, layer := range c.Clouds {
+		if layer.Cover != "BKN" && layer.Cover != "OVC" && layer.Cover != "VV" || layer.BaseFt == nil {
+			continue
+		}
+		if ceiling == nil || *layer.BaseFt < *ceiling {
+			ceiling = layer.BaseFt
+		}
+	}
+	return ceiling
+}
+
+// Miles returns the visibility in statute miles, converting from metres if
+// need be.  CAVOK counts as 10km.
+func (c *Conditions) Miles() *float64 {
+	if c.Visibility != nil && c.Visibility.StatuteMiles != nil {
+		return c.Visibility.StatuteMiles
+	}
+	meters := 0
+	switch {
+	case c.Visibility != nil && c.Visibility.Meters != nil:
+		meters = *c.Visibility.Meters
+	case c.ClearSky == SkyCAVOK:
+		meters = 10000
+	default:
+		return nil
+	}
+	miles := round(units.MetersToMiles(float64(meters)), 2)
+	return &miles
+}
+
+// FlightCategory returns the flight category of the conditions, or "" if
+// neither ceiling nor visibility is known.  A clear sky counts as an
+// unlimited ceiling.
+func (c *Conditions) FlightCategory() string {
+	miles := c.Miles()
+	ceiling := c.CeilingFt()
+	if miles == nil && ceiling == nil && c.ClearSky == "" && len(c.Clouds) == 0 {
+		return ""
+	}
+	if miles == nil && ceiling == nil {
+		// a known unlimited ceiling, with unknown visibility
+		unlimited := 100000
+		ceiling = &unlimited
+	}
+	return FlightCategory(ceiling, miles)
+}
@@ -0,0 +1,355 @@
+package metar
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// METAR is a decoded METAR or SPECI report.  Fields that weren't reported are
+// nil or empty, and groups in the body that aren't understood are kept in
+// Unparsed.
+type METAR struct {
+	// Type is METAR or SPECI, or "" if the report didn't say.
+	Type    string `json:"type,omitempty"`
+	Station string `json:"station"`
+	// Day, Hour and Minute are the UTC observation time; the report doesn't
+	// carry a month or year.
+	Day       int  `json:"day"`
+	Hour      int  `json:"hour"`
+	Minute    int  `json:"minute"`
+	Auto      bool `json:"auto,omitempty"`
+	Corrected bool `json:"corrected,omitempty"`
+	Conditions
+	RVR        []RVR          `json:"rvr,omitempty"`
+	TempC      *int           `json:"temp_c,omitempty"`
+	DewpointC  *int           `json:"dewpoint_c,omitempty"`
+	Pressure   *Pressure      `json:"pressure,omitempty"`
+	Supplement *Supplementary `json:"supplementary,omitempty"`
+	HasRemarks bool           `json:"has_remarks,omitempty"`
+	Remarks    *Remarks       `json:"remarks,omitempty"`
+	Unparsed   []string       `json:"unparsed,omitempty"`
+	// Guessed are the groups, as reported, that were only decoded after
+	// correcting them, with BestEffort.
+	Guessed []string `json:"guessed,omitempty"`
+}
+
+// Conditions are the groups a METAR shares with a TAF forecast period: wind,
+// visibility, weather and sky.
+type Conditions struct {
+	Wind       *Wind        `json:"wind,omitempty"`
+	Visibility *Visibility  `json:"visibility,omitempty"`
+	Weather    []Weather    `json:"weather,omitempty"`
+	Clouds     []CloudLayer `json:"clouds,omitempty"`
+	ClearSky   string       `json:"clear_sky,omitempty"`
+}
+
+// Wind is the surface wind.  Direction is nil for variable (VRB) wind, and
+// VariableFrom and VariableTo give the range of a dddVddd group.
+type Wind struct {
+	DirectionDegrees *int `json:"direction_degrees,omitempty"`
+	Speed            int  `json:"speed"`
+	Gust             *int `json:"gust,omitempty"`
+	// Unit is KT, MPS or KMH, as reported.
+	Unit         string `json:"unit"`
+	VariableFrom *int   `json:"variable_from,omitempty"`
+	VariableTo   *int   `json:"variable_to,omitempty"`
+}
+
+// Visibility is the prevailing visibility, in statute miles for US-style
+// reports and metres otherwise.  LessThan and MoreThan are set for M and P
+// prefixes (and 9999, which means 10km or more).
+type Visibility struct {
+	StatuteMiles *float64 `json:"statute_miles,omitempty"`
+	Meters       *int     `json:"meters,omitempty"`
+	LessThan     bool     `json:"less_than,omitempty"`
+	MoreThan     bool     `json:"more_than,omitempty"`
+}
+
+// RVR is a runway visual range group.  High is set for a variable range.
+// Unit is FT or M.  Trend is U (up), D (down), N (no change) or "".
+type RVR struct {
+	Runway string `json:"runway"`
+	Low    int    `json:"low"`
+	High   *int   `json:"high,omitempty"`
+	Unit   string `json:"unit"`
+	// LessThan and MoreThan are set for M and P prefixes on Low.
+	LessThan bool   `json:"less_than,omitempty"`
+	MoreThan bool   `json:"more_than,omitempty"`
+	Trend    string `json:"trend,omitempty"`
+}
+
+// Weather is a present weather group, e.g. -SHRA is intensity -, descriptor
+// SH and phenomena [RA].
+type Weather struct {
+	// Intensity is -, +, VC (in the vicinity) or "" for moderate.
+	Intensity  string   `json:"intensity,omitempty"`
+	Descriptor string   `json:"descriptor,omitempty"`
+	Phenomena  []string `json:"phenomena,omitempty"`
+}
+
+// CloudLayer is a sky condition group.  Cover is FEW, SCT, BKN, OVC or VV
+// (vertical visibility).  BaseFt is nil when the height is ///.
+type CloudLayer struct {
+	Cover  string `json:"cover"`
+	BaseFt *int   `json:"base_ft,omitempty"`
+	// Type is CB or TCU, or "".
+	Type string `json:"type,omitempty"`
+}
+
+var (
+	timePattern        = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	windPattern        = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(?:G(\d{2,3}))?(KT|MPS|KMH)$`)
+	windVaryPattern    = regexp.MustCompile(`^(\d{3})V(\d{3})$`)
+	visSMPattern       = regexp.MustCompile(`^([MP])?(\d+(?:/\d+)?)SM$`)
+	visWholePattern    = regexp.MustCompile(`^\d$`)
+	visMetersPattern   = regexp.MustCompile(`^(\d{4})(?:NDV)?$`)
+	rvrPattern         = regexp.MustCompile(`^R(\d{2}[LCR]?)/([MP])?(\d{4})(?:V[MP]?(\d{4}))?(FT)?/?([UDN])?$`)
+	weatherPattern     = regexp.MustCompile(`^(-|\+|VC)?(MI|PR|BC|DR|BL|SH|TS|FZ)?((?:DZ|RA|SN|SG|IC|PL|GR|GS|UP|BR|FG|FU|VA|DU|SA|HZ|PY|PO|SQ|FC|SS|DS)*)$`)
+	cloudPattern       = regexp.MustCompile(`^(FEW|SCT|BKN|OVC|VV)(\d{3}|///)(CB|TCU|///)?$`)
+	temperaturePattern = regexp.MustCompile(`^(M?\d{2})/(M?\d{2})?$`)
+)
+
+// ErrNotMETAR is returned by Decode for text without a station and
+// observation time.
+var ErrNotMETAR = errors.New("not a METAR: missing station or time")
+
+// Decode decodes the raw METAR text raw.  Only the station and time are
+// required; everything else is decoded where present.
+func Decode(raw string) (*METAR, error) {
+	return DecodeWith(raw, Lenient)
+}
+
+// DecodeWith decodes raw like Decode, treating groups it doesn't recognize
+// as strictness says.
+func DecodeWith(raw string, strictness Strictness) (*METAR, error) {
+	groups := strings.Fields(raw)
+	m := &METAR{}
+	if len(groups) > 0 && (groups[0] == "METAR" || groups[0] == "SPECI") {
+		m.Type = groups[0]
+		groups = groups[1:]
+	}
+	if len(groups) < 2 || !timePattern.MatchString(groups[1]) {
+		return nil, ErrNotMETAR
+	}
+	m.Station = groups[0]
+	t := timePattern.FindStringSubmatch(groups[1])
+	m.Day, m.Hour, m.Minute = atoi(t[1]), atoi(t[2]), atoi(t[3])
+
+	body := groups[2:]
+	// unrecognized are the Unparsed groups outside the trend
+	var unrecognized []string
+	// guessedAt is the index in body of the group last corrected by guess,
+	// and guessedFrom what it was
+	guessedAt, guessedFrom := -1, ""
+	for i := 0; i < len(body); i++ {
+		group := body[i]
+		if group == "RMK" {
+			m.HasRemarks = true
+			break
+		}
+		if group == "BECMG" || group == "TEMPO" || group == "NOSIG" {
+			// trend forecasts aren't decoded, and their groups mustn't be
+			// mistaken for the observation's
+			for ; i < len(body) && body[i] != "RMK"; i++ {
+				m.Unparsed = append(m.Unparsed, body[i])
+			}
+			i--
+			continue
+		}
+		if n := m.Conditions.decode(body[i:], false); n > 0 {
+			i += n - 1
+			continue
+		}
+		if n := supplementaryLength(body[i:]); n > 0 {
+			// decoded by DecodeSupplementary below
+			i += n - 1
+			continue
+		}
+		switch {
+		case group == "AUTO":
+			m.Auto = true
+		case group == "COR":
+			m.Corrected = true
+		case rvrPattern.MatchString(group):
+			m.RVR = append(m.RVR, parseRVR(rvrPattern.FindStringSubmatch(group)))
+		case m.TempC == nil && temperaturePattern.MatchString(group):
+			tt := temperaturePattern.FindStringSubmatch(group)
+			m.TempC = parseTemp(tt[1])
+			m.DewpointC = parseTemp(tt[2])
+		case qnhHPaPattern.MatchString(group) || qnhInHgPattern.MatchString(group):
+			// decoded by DecodePressure below
+		default:
+			if i == guessedAt {
+				// the correction didn't decode either
+				m.Guessed = m.Guessed[:len(m.Guessed)-1]
+				group = guessedFrom
+			} else if fixed, ok := guess(group); ok && strictness == BestEffort {
+				m.Guessed = append(m.Guessed, group)
+				guessedAt, guessedFrom = i, group
+				body[i] = fixed
+				i--
+				continue
+			}
+			m.Unparsed = append(m.Unparsed, group)
+			unrecognized = append(unrecognized, group)
+		}
+	}
+	if strictness == Strict && len(unrecognized) > 0 {
+		return nil, &UnparsedError{unrecognized}
+	}
+	m.Pressure = DecodePressure(raw)
+	m.Supplement = DecodeSupplementary(raw)
+	m.Remarks = DecodeRemarks(raw)
+	return m, nil
+}
+
+// DecodeConditions decodes the wind, visibility, weather and sky groups in
+// groups, returning the groups it didn't understand.  A metric visibility is
+// only taken after a wind, as in a report's body, unless change is set for
+// the groups of a forecast change (like a TAF's TEMPO), which often has a
+// visibility but no wind.
+func DecodeConditions(groups []string, change bool) (Conditions, []string) {
+	var c Conditions
+	var unparsed []string
+	for i := 0; i < len(groups); i++ {
+		if n := c.decode(groups[i:], change); n > 0 {
+			i += n - 1
+			continue
+		}
+		unparsed = append(unparsed, groups[i])
+	}
+	return c, unparsed
+}
+
+// decode decodes the condition at the start of groups, returning how many
+// groups it used, or 0 if the first group isn't a condition.  change is as
+// for DecodeConditions.
+func (c *Conditions) decode(groups []string, change bool) int {
+	group := groups[0]
+	switch {
+	case c.Wind == nil && windPattern.MatchString(group):
+		c.Wind = parseWind(windPattern.FindStringSubmatch(group))
+	case c.Wind != nil && windVaryPattern.MatchString(group):
+		v := windVaryPattern.FindStringSubmatch(group)
+		from, to := atoi(v[1]), atoi(v[2])
+		c.Wind.VariableFrom, c.Wind.VariableTo = &from, &to
+	case c.Visibility == nil && visWholePattern.MatchString(group) && len(groups) > 1 && visSMPattern.MatchString(groups[1]):
+		// a mixed number, e.g. 1 1/2SM
+		c.Visibility = parseVisibilitySM(visSMPattern.FindStringSubmatch(groups[1]))
+		if c.Visibility != nil {
+			*c.Visibility.StatuteMiles += float64(atoi(group))
+		}
+		return 2
+	case c.Visibility == nil && visSMPattern.MatchString(group):
+		c.Visibility = parseVisibilitySM(visSMPattern.FindStringSubmatch(group))
+	case c.Visibility == nil && (c.Wind != nil || change) && visMetersPattern.MatchString(group):
+		meters := atoi(visMetersPattern.FindStringSubmatch(group)[1])
+		c.Visibility = &Visibility{Meters: &meters, MoreThan: meters == 9999}
+	case cloudPattern.MatchString(group):
+		c.Clouds = append(c.Clouds, parseCloud(cloudPattern.FindStringSubmatch(group)))
+	case group == SkyCLR || group == SkySKC || group == SkyNSC || group == SkyNCD || group == SkyCAVOK:
+		c.ClearSky = group
+	case group == "NSW":
+		// no significant weather, used in forecasts to end earlier weather
+		c.Weather = append(c.Weather, Weather{Phenomena: []string{group}})
+	case weatherPattern.MatchString(group) && group != "" && group != "-" && group != "+" && group != "VC":
+		c.Weather = append(c.Weather, parseWeather(weatherPattern.FindStringSubmatch(group)))
+	default:
+		return 0
+	}
+	return 1
+}
+
+func parseWind(w []string) *Wind {
+	wind := &Wind{Speed: atoi(w[2]), Unit: w[4]}
+	if w[1] != "VRB" {
+		dir := atoi(w[1])
+		wind.DirectionDegrees = &dir
+	}
+	if w[3] != "" {
+		gust := atoi(w[3])
+		wind.Gust = &gust
+	}
+	return wind
+}
+
+func parseVisibilitySM(v []string) *Visibility {
+	miles, err := parseFraction(v[2])
+	if err != nil {
+		return nil
+	}
+	return &Visibility{StatuteMiles: &miles, LessThan: v[1] == "M", MoreThan: v[1] == "P"}
+}
+
+// parseFraction parses a whole number or a fraction like 3/4.
+func parseFraction(s string) (float64, error) {
+	num, den, ok := strings.Cut(s, "/")
+	n, err := strconv.Atoi(num)
+	if err != nil || !ok {
+		return float64(n), err
+	}
+	d, err := strconv.Atoi(den)
+	if err != nil || d == 0 {
+		return 0, fmt.Errorf("bad fraction %q", s)
+	}
+	return float64(n) / float64(d), nil
+}
+
+func parseRVR(r []string) RVR {
+	rvr := RVR{
+		Runway:   r[1],
+		Low:      atoi(r[3]),
+		LessThan: r[2] == "M",
+		MoreThan: r[2] == "P",
+		Unit:     "M",
+		Trend:    r[6],
+	}
+	if r[4] != "" {
+		high := atoi(r[4])
+		rvr.High = &high
+	}
+	if r[5] != "" {
+		rvr.Unit = r[5]
+	}
+	return rvr
+}
+
+func parseWeather(w []string) Weather {
+	weather := Weather{Intensity: w[1], Descriptor: w[2]}
+	for i := 0; i+2 <= len(w[3]); i += 2 {
+		weather.Phenomena = append(weather.Phenomena, w[3][i:i+2])
+	}
+	return weather
+}
+
+func parseCloud(c []string) CloudLayer {
+	layer := CloudLayer{Cover: c[1]}
+	if c[2] != "///" {
+		base := atoi(c[2]) * 100
+		layer.BaseFt = &base
+	}
+	if c[3] != "///" {
+		layer.Type = c[3]
+	}
+	return layer
+}
+
+// parseTemp parses a temperature like 12 or M05, returning nil for "".
+func parseTemp(s string) *int {
+	if s == "" {
+		return nil
+	}
+	t := atoi(strings.TrimPrefix(s, "M"))
+	if strings.HasPrefix(s, "M") {
+		t = -t
+	}
+	return &t
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,21 @@
+package metar
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDecodeConditionsChange(t *testing.T) {
+	groups := []string{"4000", "-SHRA", "BKN012"}
+	c, unparsed := DecodeConditions(groups, false)
+	if c.Visibility != nil || !slices.Equal(unparsed, []string{"4000"}) {
+		t.Errorf("without change: visibility %+v, unparsed %q; want none and [4000]", c.Visibility, unparsed)
+	}
+	c, unparsed = DecodeConditions(groups, true)
+	if c.Visibility == nil || c.Visibility.Meters == nil || *c.Visibility.Meters != 4000 || len(unparsed) > 0 {
+		t.Errorf("with change: visibility %+v, unparsed %q; want 4000 m", c.Visibility, unparsed)
+	}
+	if len(c.Weather) != 1 || len(c.Clouds) != 1 {
+		t.Errorf("with change: weather %+v, clouds %+v", c.Weather, c.Clouds)
+	}
+}
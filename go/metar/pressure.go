@@ -0,0 +1,82 @@
+package metar
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mattdee123.com/aviationweather/units"
+)
+
+// Pressure is the reported QNH (altimeter setting) and, where present, QFE
+// (station-level pressure).  QNH is given in both hPa and inHg: a unit that
+// was reported is kept as-is and the other is converted from it.
+type Pressure struct {
+	QNHHPa  *float64 `json:"qnh_hpa,omitempty"`
+	QNHInHg *float64 `json:"qnh_inhg,omitempty"`
+	QFEHPa  *float64 `json:"qfe_hpa,omitempty"`
+}
+
+var (
+	qnhHPaPattern  = regexp.MustCompile(`^Q(\d{4})$`)
+	qnhInHgPattern = regexp.MustCompile(`^A(\d{4})$`)
+	// QFE is usually in mmHg (QFE750), sometimes with hPa after it
+	// (QFE750/1000), and occasionally in hPa alone (QFE1000).
+	qfePattern = regexp.MustCompile(`^QFE(\d{3,4})(?:/(\d{3,4}))?$`)
+)
+
+// DecodePressure decodes the pressure groups of the raw METAR text raw.  QNH
+// comes from the Qnnnn and Annnn groups in the body, and QFE from a QFE group
+// anywhere in the report.  It returns nil if there are none.
+func DecodePressure(raw string) *Pressure {
+	p := &Pressure{}
+	inRemarks := false
+	for _, group := range strings.Fields(raw) {
+		switch {
+		case group == "RMK":
+			inRemarks = true
+		case !inRemarks && qnhHPaPattern.MatchString(group):
+			hPa, _ := strconv.ParseFloat(qnhHPaPattern.FindStringSubmatch(group)[1], 64)
+			p.QNHHPa = &hPa
+		case !inRemarks && qnhInHgPattern.MatchString(group):
+			hundredths, _ := strconv.Atoi(qnhInHgPattern.FindStringSubmatch(group)[1])
+			inHg := float64(hundredths) / 100
+			p.QNHInHg = &inHg
+		case qfePattern.MatchString(group):
+			p.QFEHPa = parseQFE(qfePattern.FindStringSubmatch(group))
+		}
+	}
+	if p.QNHHPa == nil && p.QNHInHg == nil && p.QFEHPa == nil {
+		return nil
+	}
+	if p.QNHHPa == nil && p.QNHInHg != nil {
+		hPa := round(units.InHgToHPa(*p.QNHInHg), 1)
+		p.QNHHPa = &hPa
+	}
+	if p.QNHInHg == nil && p.QNHHPa != nil {
+		inHg := round(units.HPaToInHg(*p.QNHHPa), 2)
+		p.QNHInHg = &inHg
+	}
+	return p
+}
+
+// parseQFE returns the QFE in hPa from a match of qfePattern, preferring an
+// explicit hPa value over converting from mmHg.
+func parseQFE(m []string) *float64 {
+	if len(m[2]) == 4 {
+		hPa, _ := strconv.ParseFloat(m[2], 64)
+		return &hPa
+	}
+	value, _ := strconv.ParseFloat(m[1], 64)
+	if len(m[1]) == 4 {
+		return &value
+	}
+	hPa := round(value*units.HPaPerMmHg, 1)
+	return &hPa
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
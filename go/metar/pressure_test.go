@@ -0,0 +1,55 @@
+package metar
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodePressure(t *testing.T) {
+	for _, test := range []struct {
+		raw string
+		// want is the decoded pressure as JSON.
+		want string
+	}{
+		{"EGLL 141150Z 24010KT 9999 SCT030 12/05 Q1013",
+			`{"qnh_hpa":1013,"qnh_inhg":29.91}`},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992",
+			`{"qnh_hpa":1013.2,"qnh_inhg":29.92}`},
+		// both reported, so neither is converted
+		{"RKSI 141200Z 32008KT 9999 FEW030 15/06 Q1013 A2993",
+			`{"qnh_hpa":1013,"qnh_inhg":29.93}`},
+		{"EGLL 141150Z 24010KT 9999 SCT030 12/05 Q0998",
+			`{"qnh_hpa":998,"qnh_inhg":29.47}`},
+		// QFE in mmHg, with hPa, and alone in hPa
+		{"UUEE 141200Z 27005MPS 9999 BKN020 08/03 Q1012 R06L/290050 NOSIG RMK QFE750",
+			`{"qnh_hpa":1012,"qnh_inhg":29.88,"qfe_hpa":999.9}`},
+		{"UUEE 141200Z 27005MPS 9999 BKN020 08/03 Q1012 NOSIG RMK QFE750/0999",
+			`{"qnh_hpa":1012,"qnh_inhg":29.88,"qfe_hpa":999}`},
+		{"UTTT 141200Z 36004MPS CAVOK 18/02 Q1021 NOSIG RMK QFE0952",
+			`{"qnh_hpa":1021,"qnh_inhg":30.15,"qfe_hpa":952}`},
+		// remarks aren't QNH
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 RMK A2992", "null"},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05", "null"},
+	} {
+		got, err := json.Marshal(DecodePressure(test.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\n got %s\nwant %s", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestDecodePressureNotUnparsed(t *testing.T) {
+	m, err := Decode("RKSI 141200Z 32008KT 9999 FEW030 15/06 Q1013 A2993")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Unparsed) > 0 {
+		t.Errorf("unparsed %q", m.Unparsed)
+	}
+	if m.Pressure == nil || *m.Pressure.QNHHPa != 1013 || *m.Pressure.QNHInHg != 29.93 {
+		t.Errorf("Pressure = %+v", m.Pressure)
+	}
+}
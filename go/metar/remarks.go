@@ -0,0 +1,210 @@
+// Package metar decodes raw METAR reports.
+package metar
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Remarks holds the decoded groups of a METAR's RMK section.  Groups that
+// aren't understood are kept verbatim in Other, in the order they appeared.
+type Remarks struct {
+	SeaLevelPressureMB     *float64  `json:"sea_level_pressure_mb,omitempty"`
+	PeakWind               *PeakWind `json:"peak_wind,omitempty"`
+	PressureRisingRapidly  bool      `json:"pressure_rising_rapidly,omitempty"`
+	PressureFallingRapidly bool      `json:"pressure_falling_rapidly,omitempty"`
+	// Precip6HourIn is the 6-hourly precipitation in inches.  It is nil with
+	// Precip6HourIndeterminate set for a 6//// group.
+	Precip6HourIn            *float64 `json:"precip_6hr_in,omitempty"`
+	Precip6HourIndeterminate bool     `json:"precip_6hr_indeterminate,omitempty"`
+	// Precip1HourIn and Precip24HourIn are from the P and 7 groups, and are
+	// nil for an indeterminate (////) amount as well as a missing one.
+	Precip1HourIn  *float64 `json:"precip_1hr_in,omitempty"`
+	Precip24HourIn *float64 `json:"precip_24hr_in,omitempty"`
+	// TempC and DewpointC are the tenths-precision values from the T group.
+	TempC            *float64          `json:"temp_c,omitempty"`
+	DewpointC        *float64          `json:"dewpoint_c,omitempty"`
+	PressureTendency *PressureTendency `json:"pressure_tendency,omitempty"`
+	// SensorStatus lists sensor outage groups such as PWINO, TSNO, or
+	// VISNO RWY06 (with the location joined on).
+	SensorStatus []string `json:"sensor_status,omitempty"`
+	// MaintenanceNeeded is set by a $ group.
+	MaintenanceNeeded bool `json:"maintenance_needed,omitempty"`
+	// TowerVisibilityMi is the TWR VIS group, in statute miles.
+	TowerVisibilityMi *float64 `json:"tower_visibility_mi,omitempty"`
+	Other             []string `json:"other,omitempty"`
+}
+
+// PressureTendency is a 5appp group: the WMO code 0200 characteristic of the
+// pressure change over the last 3 hours, and the change in hPa, negative for
+// a fall.
+type PressureTendency struct {
+	Code      int     `json:"code"`
+	ChangeHPa float64 `json:"change_hpa"`
+}
+
+// PeakWind is a PK WND group.  Hour is nil when the report only gave minutes
+// past the hour of the observation.
+type PeakWind struct {
+	DirectionDegrees int  `json:"direction_degrees"`
+	SpeedKt          int  `json:"speed_kt"`
+	Hour             *int `json:"hour,omitempty"`
+	Minute           int  `json:"minute"`
+}
+
+var (
+	slpPattern      = regexp.MustCompile(`^SLP(\d{3})$`)
+	peakWindPattern = regexp.MustCompile(`^(\d{3})(\d{2,3})/(\d{2})?(\d{2})$`)
+	precip6Pattern  = regexp.MustCompile(`^6(\d{4}|////)$`)
+	precip1Pattern  = regexp.MustCompile(`^P(\d{4}|////)$`)
+	precip24Pattern = regexp.MustCompile(`^7(\d{4}|////)$`)
+	tempPattern     = regexp.MustCompile(`^T([01])(\d{3})(?:([01])(\d{3}))?$`)
+	tendencyPattern = regexp.MustCompile(`^5([0-8])(\d{3})$`)
+	fractionPattern = regexp.MustCompile(`^\d+(?:/\d+)?$`)
+)
+
+// sensorStatus are the sensor outage groups; those in sensorLocated may be
+// followed by the location of the missing sensor.
+var (
+	sensorStatus  = map[string]bool{"RVRNO": true, "PWINO": true, "PNO": true, "FZRANO": true, "TSNO": true, "VISNO": true, "CHINO": true}
+	sensorLocated = map[string]bool{"VISNO": true, "CHINO": true}
+)
+
+// RemarksSection returns the text after RMK in raw, or "" if there is none.
+func RemarksSection(raw string) string {
+	fields := strings.Fields(raw)
+	for i, field := range fields {
+		if field == "RMK" {
+			return strings.Join(fields[i+1:], " ")
+		}
+	}
+	return ""
+}
+
+// DecodeRemarks decodes the RMK section of the raw METAR text raw.  It returns
+// nil if there is no RMK section.
+func DecodeRemarks(raw string) *Remarks {
+	section := RemarksSection(raw)
+	if section == "" {
+		return nil
+	}
+	r := &Remarks{}
+	groups := strings.Fields(section)
+	for i := 0; i < len(groups); i++ {
+		group := groups[i]
+		switch {
+		case group == "PRESRR":
+			r.PressureRisingRapidly = true
+		case group == "PRESFR":
+			r.PressureFallingRapidly = true
+		case group == "PK" && i+2 < len(groups) && groups[i+1] == "WND":
+			if pk := parsePeakWind(groups[i+2]); pk != nil {
+				r.PeakWind = pk
+				i += 2
+				continue
+			}
+			r.Other = append(r.Other, group)
+		case slpPattern.MatchString(group):
+			r.SeaLevelPressureMB = parseSLP(slpPattern.FindStringSubmatch(group)[1])
+		case precip6Pattern.MatchString(group):
+			digits := precip6Pattern.FindStringSubmatch(group)[1]
+			if digits == "////" {
+				r.Precip6HourIndeterminate = true
+				continue
+			}
+			r.Precip6HourIn = parseHundredths(digits)
+		case precip1Pattern.MatchString(group):
+			r.Precip1HourIn = parseHundredths(precip1Pattern.FindStringSubmatch(group)[1])
+		case precip24Pattern.MatchString(group):
+			r.Precip24HourIn = parseHundredths(precip24Pattern.FindStringSubmatch(group)[1])
+		case tempPattern.MatchString(group):
+			m := tempPattern.FindStringSubmatch(group)
+			r.TempC = parseTenths(m[1], m[2])
+			if m[3] != "" {
+				r.DewpointC = parseTenths(m[3], m[4])
+			}
+		case tendencyPattern.MatchString(group):
+			m := tendencyPattern.FindStringSubmatch(group)
+			code, _ := strconv.Atoi(m[1])
+			tenths, _ := strconv.Atoi(m[2])
+			change := float64(tenths) / 10
+			if code >= 5 {
+				change = -change
+			}
+			r.PressureTendency = &PressureTendency{Code: code, ChangeHPa: change}
+		case sensorStatus[group]:
+			if sensorLocated[group] && i+1 < len(groups) && !sensorStatus[groups[i+1]] && groups[i+1] != "$" {
+				group += " " + groups[i+1]
+				i++
+			}
+			r.SensorStatus = append(r.SensorStatus, group)
+		case group == "$":
+			r.MaintenanceNeeded = true
+		case group == "TWR" && i+2 < len(groups) && groups[i+1] == "VIS" && fractionPattern.MatchString(groups[i+2]):
+			miles, _ := parseFraction(groups[i+2])
+			i += 2
+			// a mixed number, e.g. TWR VIS 1 1/2
+			if i+1 < len(groups) && strings.Contains(groups[i+1], "/") && fractionPattern.MatchString(groups[i+1]) {
+				part, _ := parseFraction(groups[i+1])
+				miles += part
+				i++
+			}
+			r.TowerVisibilityMi = &miles
+		default:
+			r.Other = append(r.Other, group)
+		}
+	}
+	return r
+}
+
+// parseHundredths decodes a precipitation amount in hundredths of an inch,
+// returning nil for ////.
+func parseHundredths(digits string) *float64 {
+	if digits == "////" {
+		return nil
+	}
+	hundredths, _ := strconv.Atoi(digits)
+	inches := float64(hundredths) / 100
+	return &inches
+}
+
+// parseTenths decodes a T group temperature: a sign digit (1 for negative)
+// and tenths of a degree.
+func parseTenths(sign, digits string) *float64 {
+	tenths, _ := strconv.Atoi(digits)
+	c := float64(tenths) / 10
+	if sign == "1" {
+		c = -c
+	}
+	return &c
+}
+
+// parseSLP decodes the three digits of an SLP group, which are the tenths,
+// units and tens of the pressure in millibars.
+func parseSLP(digits string) *float64 {
+	tenths, _ := strconv.Atoi(digits)
+	mb := float64(tenths) / 10
+	if mb < 50 {
+		mb += 1000
+	} else {
+		mb += 900
+	}
+	return &mb
+}
+
+func parsePeakWind(group string) *PeakWind {
+	m := peakWindPattern.FindStringSubmatch(group)
+	if m == nil {
+		return nil
+	}
+	pk := &PeakWind{}
+	pk.DirectionDegrees, _ = strconv.Atoi(m[1])
+	pk.SpeedKt, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		hour, _ := strconv.Atoi(m[3])
+		pk.Hour = &hour
+	}
+	pk.Minute, _ = strconv.Atoi(m[4])
+	return pk
+}
@@ -0,0 +1,65 @@
+package metar
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeRemarks(t *testing.T) {
+	for _, test := range []struct {
+		raw string
+		// want is the decoded remarks as JSON.
+		want string
+	}{
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "null"},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK SLP132",
+			`{"sea_level_pressure_mb":1013.2}`},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK SLP982",
+			`{"sea_level_pressure_mb":998.2}`},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK T01220050",
+			`{"temp_c":12.2,"dewpoint_c":5}`},
+		{"KFAI 141153Z 00000KT 10SM CLR M05/M08 A3012 RMK T10501083",
+			`{"temp_c":-5,"dewpoint_c":-8.3}`},
+		{"KBOS 141154Z 31028G40KT 10SM FEW050 12/05 A2992 RMK PK WND 31045/1132",
+			`{"peak_wind":{"direction_degrees":310,"speed_kt":45,"hour":11,"minute":32}}`},
+		{"KBOS 141154Z 31028G40KT 10SM FEW050 12/05 A2992 RMK PK WND 310105/32",
+			`{"peak_wind":{"direction_degrees":310,"speed_kt":105,"minute":32}}`},
+		{"KBOS 141154Z 27010KT 3SM RA OVC010 12/11 A2992 RMK 60217",
+			`{"precip_6hr_in":2.17}`},
+		{"KBOS 141154Z 27010KT 3SM RA OVC010 12/11 A2992 RMK 6////",
+			`{"precip_6hr_indeterminate":true}`},
+		{"KBOS 141154Z 27010KT 3SM RA OVC010 12/11 A2992 RMK P0009 70125",
+			`{"precip_1hr_in":0.09,"precip_24hr_in":1.25}`},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK PRESFR 58033",
+			`{"pressure_falling_rapidly":true,"pressure_tendency":{"code":8,"change_hpa":-3.3}}`},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK AO2 PRESRR",
+			`{"pressure_rising_rapidly":true,"other":["AO2"]}`},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK AO2 PWINO VISNO RWY06 $",
+			`{"sensor_status":["PWINO","VISNO RWY06"],"maintenance_needed":true,"other":["AO2"]}`},
+		{"KBOS 141154Z 27010KT 1SM BR OVC005 12/11 A2992 RMK TWR VIS 1 1/2",
+			`{"tower_visibility_mi":1.5}`},
+		// unknown groups are kept as they were, in order
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK AO2 RAB15 SLP132 FRQ LTGIC DSNT NE T01220050",
+			`{"sea_level_pressure_mb":1013.2,"temp_c":12.2,"dewpoint_c":5,"other":["AO2","RAB15","FRQ","LTGIC","DSNT","NE"]}`},
+		// a PK WND without a wind group isn't one
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK PK WND MISG",
+			`{"other":["PK","WND","MISG"]}`},
+	} {
+		got, err := json.Marshal(DecodeRemarks(test.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\n got %s\nwant %s", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestRemarksSection(t *testing.T) {
+	if got := RemarksSection("KBOS 141154Z 27010KT A2992 RMK AO2  SLP132"); got != "AO2 SLP132" {
+		t.Errorf("RemarksSection = %q", got)
+	}
+	if got := RemarksSection("KBOS 141154Z 27010KT A2992"); got != "" {
+		t.Errorf("no RMK: RemarksSection = %q", got)
+	}
+}
@@ -0,0 +1,29 @@
+package metar
+
+import "strings"
+
+// Clear-sky indicators.  They aren't interchangeable: CLR is an automated
+// station seeing nothing below 12,000ft, SKC is an observer seeing a clear
+// sky, NSC and NCD mean no significant or no detected cloud, and CAVOK also
+// implies visibility of 10km or more and no significant weather.
+const (
+	SkyCLR   = "CLR"
+	SkySKC   = "SKC"
+	SkyNSC   = "NSC"
+	SkyNCD   = "NCD"
+	SkyCAVOK = "CAVOK"
+)
+
+// ClearSky returns the clear-sky indicator in the body of the raw METAR text
+// raw, or "" if it reports none.
+func ClearSky(raw string) string {
+	for _, group := range strings.Fields(raw) {
+		switch group {
+		case "RMK":
+			return ""
+		case SkyCLR, SkySKC, SkyNSC, SkyNCD, SkyCAVOK:
+			return group
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package metar
+
+import "testing"
+
+func TestClearSky(t *testing.T) {
+	for _, test := range []struct {
+		raw      string
+		clear    string
+		miles    *float64
+		category string
+	}{
+		{"KBOS 141154Z 27010KT 10SM CLR 12/05 A2992", SkyCLR, ptr(10.0), CategoryVFR},
+		{"KBOS 141154Z 27010KT 2SM BR CLR 12/11 A2992", SkyCLR, ptr(2.0), CategoryIFR},
+		{"KBOS 141154Z 27010KT 1/2SM FG SKC 12/12 A2992", SkySKC, ptr(0.5), CategoryLIFR},
+		{"KOUN 141154Z 18008KT 4SM HZ SKC 22/15 A3001", SkySKC, ptr(4.0), CategoryMVFR},
+		{"EGLL 141150Z 24010KT 9999 NSC 12/05 Q1013", SkyNSC, ptr(6.21), CategoryVFR},
+		{"EGLL 141150Z 24010KT 2500 BR NSC 12/11 Q1013", SkyNSC, ptr(1.55), CategoryIFR},
+		{"EGPD 141150Z AUTO 24010KT 9999 NCD 12/05 Q1013", SkyNCD, ptr(6.21), CategoryVFR},
+		// CAVOK has no visibility group, but means 10km or more
+		{"LFPG 141200Z 24010KT CAVOK 18/09 Q1020", SkyCAVOK, ptr(6.21), CategoryVFR},
+		// a clear sky with no visibility is still known to be VFR
+		{"KBOS 141154Z 27010KT CLR 12/05 A2992", SkyCLR, nil, CategoryVFR},
+		// RMK groups aren't the sky condition
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK CLR BLO 120", "", ptr(10.0), CategoryVFR},
+	} {
+		if got := ClearSky(test.raw); got != test.clear {
+			t.Errorf("%s: ClearSky = %q, want %q", test.raw, got, test.clear)
+		}
+		m, err := Decode(test.raw)
+		if err != nil {
+			t.Fatalf("%s: %v", test.raw, err)
+		}
+		c := m.Conditions
+		if c.ClearSky != test.clear {
+			t.Errorf("%s: decoded ClearSky = %q, want %q", test.raw, c.ClearSky, test.clear)
+		}
+		if ceiling := c.CeilingFt(); ceiling != nil {
+			t.Errorf("%s: ceiling = %d, want none", test.raw, *ceiling)
+		}
+		if miles := c.Miles(); (miles == nil) != (test.miles == nil) || miles != nil && *miles != *test.miles {
+			t.Errorf("%s: miles = %v, want %v", test.raw, deref(miles), deref(test.miles))
+		}
+		if got := c.FlightCategory(); got != test.category {
+			t.Errorf("%s: category = %q, want %q", test.raw, got, test.category)
+		}
+	}
+}
+
+func TestFlightCategoryCeiling(t *testing.T) {
+	for _, test := range []struct {
+		raw, category string
+		ceiling       int
+	}{
+		{"KBOS 141154Z 27010KT 10SM BKN040 12/05 A2992", CategoryVFR, 4000},
+		{"KBOS 141154Z 27010KT 10SM FEW010 OVC025 12/05 A2992", CategoryMVFR, 2500},
+		{"KBOS 141154Z 27010KT 10SM SCT005 BKN008 12/05 A2992", CategoryIFR, 800},
+		{"KBOS 141154Z 27010KT 1/4SM FG VV002 12/12 A2992", CategoryLIFR, 200},
+	} {
+		m, err := Decode(test.raw)
+		if err != nil {
+			t.Fatalf("%s: %v", test.raw, err)
+		}
+		if ceiling := m.Conditions.CeilingFt(); ceiling == nil || *ceiling != test.ceiling {
+			t.Errorf("%s: ceiling = %v, want %d", test.raw, deref(ceiling), test.ceiling)
+		}
+		if got := m.Conditions.FlightCategory(); got != test.category {
+			t.Errorf("%s: category = %q, want %q", test.raw, got, test.category)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// deref returns what p points to, or nil, for printing.
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,66 @@
+package metar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Strictness is how DecodeWith treats groups it doesn't recognize.
+type Strictness string
+
+const (
+	// Lenient keeps unrecognized groups in Unparsed, as Decode does.  It's
+	// also what the zero Strictness means.
+	Lenient Strictness = "lenient"
+	// Strict fails with an *UnparsedError on any unrecognized group outside
+	// the remarks and trend, which aren't fully decoded anyway.
+	Strict Strictness = "strict"
+	// BestEffort is Lenient but first tries correcting each unrecognized
+	// group for a likely typo, like a wind missing its unit, keeping the
+	// group as reported in Guessed if the correction decodes.
+	BestEffort Strictness = "best-effort"
+)
+
+// ParseStrictness parses the name of a Strictness.
+func ParseStrictness(name string) (Strictness, error) {
+	switch s := Strictness(strings.ToLower(name)); s {
+	case Lenient, Strict, BestEffort:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown strictness %q; want strict, lenient or best-effort", name)
+}
+
+// UnparsedError is DecodeWith's error for a report with groups it doesn't
+// recognize, with Strict.
+type UnparsedError struct {
+	Groups []string
+}
+
+func (e *UnparsedError) Error() string {
+	return "unrecognized groups: " + strings.Join(e.Groups, " ")
+}
+
+var (
+	windNoUnitPattern = regexp.MustCompile(`^(\d{3}|VRB)\d{2,3}(?:G\d{2,3})?$`)
+	cloudShortPattern = regexp.MustCompile(`^(FEW|SCT|BKN|OVC|VV)(\d{2})(CB|TCU)?$`)
+	tempHyphenPattern = regexp.MustCompile(`^[-M]?\d{2}/[-M]?\d{2}$`)
+)
+
+// guess returns the group a malformed one was probably meant to be, for
+// BestEffort, or false if it doesn't look like a typo of any.
+func guess(group string) (string, bool) {
+	switch {
+	case strings.ToUpper(group) != group:
+		return strings.ToUpper(group), true
+	case windNoUnitPattern.MatchString(group):
+		// most reports without a unit are in knots
+		return group + "KT", true
+	case cloudShortPattern.MatchString(group):
+		c := cloudShortPattern.FindStringSubmatch(group)
+		return c[1] + "0" + c[2] + c[3], true
+	case tempHyphenPattern.MatchString(group) && strings.Contains(group, "-"):
+		return strings.ReplaceAll(group, "-", "M"), true
+	}
+	return "", false
+}
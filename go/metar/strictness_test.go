@@ -0,0 +1,110 @@
+package metar
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// nonstandard has a wind without its unit, a two-digit cloud base, a
+// hyphen for minus and a group that isn't anything.
+const nonstandard = "KBOS 141154Z 27010 10SM BKN30 -05/M08 A2992 XYZZY RMK AO2"
+
+func TestDecodeLenient(t *testing.T) {
+	for _, s := range []Strictness{Lenient, ""} {
+		m, err := DecodeWith(nonstandard, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"27010", "BKN30", "-05/M08", "XYZZY"}; !slices.Equal(m.Unparsed, want) {
+			t.Errorf("%q: unparsed %q, want %q", s, m.Unparsed, want)
+		}
+		if m.Wind != nil || len(m.Clouds) != 0 || m.TempC != nil || len(m.Guessed) != 0 {
+			t.Errorf("%q: decoded nonstandard groups: wind %+v, clouds %+v, temp %v, guessed %q", s, m.Wind, m.Clouds, m.TempC, m.Guessed)
+		}
+		if m.Visibility == nil || *m.Visibility.StatuteMiles != 10 || !m.HasRemarks {
+			t.Errorf("%q: visibility %+v, remarks %v", s, m.Visibility, m.HasRemarks)
+		}
+	}
+	plain, err := Decode(nonstandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(plain.Unparsed, []string{"27010", "BKN30", "-05/M08", "XYZZY"}) {
+		t.Errorf("Decode unparsed %q", plain.Unparsed)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	_, err := DecodeWith(nonstandard, Strict)
+	var unparsed *UnparsedError
+	if !errors.As(err, &unparsed) {
+		t.Fatalf("got %v, want an UnparsedError", err)
+	}
+	if want := []string{"27010", "BKN30", "-05/M08", "XYZZY"}; !slices.Equal(unparsed.Groups, want) {
+		t.Errorf("groups %q, want %q", unparsed.Groups, want)
+	}
+	// the trend and remarks aren't held against a report
+	for _, raw := range []string{
+		"KBOS 141154Z 27010KT 10SM BKN030 M05/M08 A2992 RMK AO2 SLP133 T10501083",
+		"EGLL 141150Z 24012KT 9999 FEW030 14/09 Q1012 NOSIG",
+		"EGLL 141150Z 24012KT 9999 FEW030 14/09 Q1012 TEMPO 4000 SHRA",
+	} {
+		if _, err := DecodeWith(raw, Strict); err != nil {
+			t.Errorf("%s: %v", raw, err)
+		}
+	}
+	if _, err := DecodeWith("not a metar", Strict); !errors.Is(err, ErrNotMETAR) {
+		t.Errorf("not a METAR: got %v", err)
+	}
+}
+
+func TestDecodeBestEffort(t *testing.T) {
+	m, err := DecodeWith(nonstandard, BestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.Unparsed, []string{"XYZZY"}) {
+		t.Errorf("unparsed %q, want [XYZZY]", m.Unparsed)
+	}
+	if want := []string{"27010", "BKN30", "-05/M08"}; !slices.Equal(m.Guessed, want) {
+		t.Errorf("guessed %q, want %q", m.Guessed, want)
+	}
+	if m.Wind == nil || deref(m.Wind.DirectionDegrees) != 270 || m.Wind.Speed != 10 || m.Wind.Unit != "KT" {
+		t.Errorf("wind %+v", m.Wind)
+	}
+	if len(m.Clouds) != 1 || m.Clouds[0].Cover != "BKN" || deref(m.Clouds[0].BaseFt) != 3000 {
+		t.Errorf("clouds %+v", m.Clouds)
+	}
+	if deref(m.TempC) != -5 || deref(m.DewpointC) != -8 {
+		t.Errorf("temp %v, dewpoint %v", m.TempC, m.DewpointC)
+	}
+
+	// a correction that doesn't decode in context is left as reported: the
+	// wind is already decoded, so the guessed one goes unparsed
+	m, err = DecodeWith("KBOS 141154Z 27010KT 28012 10SM A2992", BestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.Unparsed, []string{"28012"}) || len(m.Guessed) != 0 {
+		t.Errorf("unparsed %q, guessed %q; want [28012] and none", m.Unparsed, m.Guessed)
+	}
+	m, err = DecodeWith("KBOS 141154Z 27010kt 10sm A2992", BestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Wind == nil || m.Visibility == nil || !slices.Equal(m.Guessed, []string{"27010kt", "10sm"}) {
+		t.Errorf("lower case: wind %+v, visibility %+v, guessed %q", m.Wind, m.Visibility, m.Guessed)
+	}
+}
+
+func TestParseStrictness(t *testing.T) {
+	for _, name := range []string{"strict", "Lenient", "best-effort"} {
+		if _, err := ParseStrictness(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := ParseStrictness("fuzzy"); err == nil {
+		t.Error("fuzzy: got no error")
+	}
+}
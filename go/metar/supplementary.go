@@ -0,0 +1,82 @@
+package metar
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Supplementary holds the ICAO supplementary groups from the body of a
+// METAR: runway wind shear and, for offshore and coastal stations, sea
+// surface temperature and sea state or wave height.
+type Supplementary struct {
+	// WindShearRunways lists the runways reported with wind shear, or "ALL"
+	// for WS ALL RWY.
+	WindShearRunways []string `json:"wind_shear_runways,omitempty"`
+	SeaSurfaceTempC  *int     `json:"sea_surface_temp_c,omitempty"`
+	// SeaState is the WMO code 3700 state of the sea, 0 (glassy) to 9.
+	SeaState *int `json:"sea_state,omitempty"`
+	// WaveHeightDM is the significant wave height in decimetres.
+	WaveHeightDM *int `json:"wave_height_dm,omitempty"`
+}
+
+var (
+	windShearRunwayPattern = regexp.MustCompile(`^R(?:WY)?(\d{2}[LCR]?)$`)
+	seaPattern             = regexp.MustCompile(`^W(M?\d{2})/(?:S(\d)|H(\d{1,3}))$`)
+)
+
+// DecodeSupplementary decodes the supplementary groups before the RMK section
+// of the raw METAR text raw.  It returns nil if there are none.
+func DecodeSupplementary(raw string) *Supplementary {
+	s := &Supplementary{}
+	found := false
+	groups := strings.Fields(raw)
+	for i := 0; i < len(groups); i++ {
+		group := groups[i]
+		if group == "RMK" {
+			break
+		}
+		n := supplementaryLength(groups[i:])
+		switch {
+		case n == 3:
+			s.WindShearRunways = append(s.WindShearRunways, "ALL")
+		case n == 2:
+			runway := windShearRunwayPattern.FindStringSubmatch(groups[i+1])[1]
+			s.WindShearRunways = append(s.WindShearRunways, runway)
+		case n == 1:
+			m := seaPattern.FindStringSubmatch(group)
+			temp, _ := strconv.Atoi(strings.Replace(m[1], "M", "-", 1))
+			s.SeaSurfaceTempC = &temp
+			if m[2] != "" {
+				state, _ := strconv.Atoi(m[2])
+				s.SeaState = &state
+			} else {
+				height, _ := strconv.Atoi(m[3])
+				s.WaveHeightDM = &height
+			}
+		default:
+			continue
+		}
+		found = true
+		i += n - 1
+	}
+	if !found {
+		return nil
+	}
+	return s
+}
+
+// supplementaryLength returns how many groups at the start of groups make up
+// a supplementary group: 3 for WS ALL RWY, 2 for a runway's wind shear, 1 for
+// a sea group, and 0 if they don't start with one.
+func supplementaryLength(groups []string) int {
+	switch {
+	case groups[0] == "WS" && len(groups) > 2 && groups[1] == "ALL" && groups[2] == "RWY":
+		return 3
+	case groups[0] == "WS" && len(groups) > 1 && windShearRunwayPattern.MatchString(groups[1]):
+		return 2
+	case seaPattern.MatchString(groups[0]):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package metar
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeSupplementary(t *testing.T) {
+	for _, test := range []struct {
+		raw string
+		// want is the decoded groups as JSON.
+		want string
+	}{
+		// Ekofisk, in the North Sea
+		{"ENLE 141150Z 24018KT 9999 FEW018 10/07 Q1011 W10/S4",
+			`{"sea_surface_temp_c":10,"sea_state":4}`},
+		// Gullfaks, with a wave height rather than a sea state
+		{"ENGC 141150Z 27025KT 9999 SCT015 BKN025 09/06 Q0998 W09/H45",
+			`{"sea_surface_temp_c":9,"wave_height_dm":45}`},
+		{"ENSB 141150Z 09012KT 9999 FEW030 M03/M07 Q1002 WM01/S2",
+			`{"sea_surface_temp_c":-1,"sea_state":2}`},
+		// Madeira, which often has wind shear on approach
+		{"LPMA 141200Z 36015G25KT 9999 FEW020 21/15 Q1018 WS R05",
+			`{"wind_shear_runways":["05"]}`},
+		{"LPMA 141230Z 36022G35KT 9999 FEW020 21/15 Q1018 WS ALL RWY",
+			`{"wind_shear_runways":["ALL"]}`},
+		{"VHHH 141200Z 23015KT 9999 FEW020 28/24 Q1008 WS R07L WS RWY25R",
+			`{"wind_shear_runways":["07L","25R"]}`},
+		{"LGKR 141150Z 31015KT CAVOK 24/14 Q1014 WS R35 W21/S3",
+			`{"wind_shear_runways":["35"],"sea_surface_temp_c":21,"sea_state":3}`},
+		// only the body's groups count
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992 RMK WS R04R", "null"},
+		{"KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "null"},
+	} {
+		got, err := json.Marshal(DecodeSupplementary(test.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s:\n got %s\nwant %s", test.raw, got, test.want)
+		}
+		m, err := Decode(test.raw)
+		if err != nil {
+			t.Fatalf("%s: %v", test.raw, err)
+		}
+		if len(m.Unparsed) > 0 {
+			t.Errorf("%s: unparsed %q", test.raw, m.Unparsed)
+		}
+		if got, _ := json.Marshal(m.Supplement); string(got) != test.want {
+			t.Errorf("%s: Decode's Supplement %s, want %s", test.raw, got, test.want)
+		}
+	}
+}
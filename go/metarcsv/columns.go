@@ -0,0 +1,145 @@
+package metarcsv
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Header is the header line of the metars file.  Files with other
+// columns or another order are mapped onto it by columnMap.
+const Header = "raw_text,station_id,observation_time,latitude,longitude,temp_c,dewpoint_c,wind_dir_degrees,wind_speed_kt,wind_gust_kt,visibility_statute_mi,altim_in_hg,sea_level_pressure_mb,corrected,auto,auto_station,maintenance_indicator_on,no_signal,lightning_sensor_off,freezing_rain_sensor_off,present_weather_sensor_off,wx_string,sky_cover,cloud_base_ft_agl,sky_cover,cloud_base_ft_agl,sky_cover,cloud_base_ft_agl,sky_cover,cloud_base_ft_agl,flight_category,three_hr_pressure_tendency_mb,maxT_c,minT_c,maxT24hr_c,minT24hr_c,precip_in,pcp3hr_in,pcp6hr_in,pcp24hr_in,snow_in,vert_vis_ft,metar_type,elevation_m"
+
+// indexes in Columns of the columns the scrapers look at
+const (
+	ColRawText         = 0
+	ColStation         = 1
+	ColObservationTime = 2
+	ColLatitude        = 3
+	ColLongitude       = 4
+	ColTempC           = 5
+	ColDewpointC       = 6
+	ColWindDirDegrees  = 7
+	ColWindSpeedKt     = 8
+	ColWindGustKt      = 9
+	ColVisibilityMi    = 10
+	ColAltimInHg       = 11
+	ColMetarType       = 42
+	ColElevationM      = 43
+)
+
+// Columns names each column of the metars file, from Header.
+// Repeated names (the four sky_cover/cloud_base_ft_agl pairs) get a _2, _3,
+// ... suffix so every column has a distinct name.
+var Columns = columnNames(Header)
+
+func columnNames(header string) []string {
+	seen := map[string]int{}
+	var names []string
+	for _, name := range strings.Split(header, ",") {
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// ColumnIndex maps the names in Columns to their index.
+var ColumnIndex = func() map[string]int {
+	index := map[string]int{}
+	for i, name := range Columns {
+		index[name] = i
+	}
+	return index
+}()
+
+// columnMap reorders the columns of a metars file into Columns order,
+// so that the Col* indexes and csv_parts positions stay the same when AWC
+// adds or reorders columns.  Columns the file lacks are left empty, and
+// unknown columns are kept after the known ones in the order they appear.
+type columnMap struct {
+	// source is the file's index of each of Columns, or -1 if it's
+	// missing.
+	source []int
+	// extra is the file's index of each unknown column.
+	extra []int
+	// identity is set if the file's header is Header exactly.
+	identity bool
+	// minWidth is how many fields a line needs to reach raw_text, station_id
+	// and observation_time; shorter lines are cut off.
+	minWidth int
+}
+
+// requiredColumns are the columns a metars file can't be ingested without.
+var requiredColumns = []string{"raw_text", "station_id", "observation_time"}
+
+func newColumnMap(header string) (*columnMap, error) {
+	m := &columnMap{identity: header == Header}
+	names := columnNames(header)
+	index := map[string]int{}
+	for i, name := range names {
+		index[name] = i
+	}
+	for _, name := range requiredColumns {
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("header %q is missing column %q", header, name)
+		}
+		m.minWidth = max(m.minWidth, i+1)
+	}
+	var missing, unknown []string
+	for _, name := range Columns {
+		i, ok := index[name]
+		if !ok {
+			i = -1
+			missing = append(missing, name)
+		}
+		m.source = append(m.source, i)
+	}
+	for i, name := range names {
+		if _, ok := ColumnIndex[name]; !ok {
+			m.extra = append(m.extra, i)
+			unknown = append(unknown, name)
+		}
+	}
+	if !m.identity {
+		slog.Warn("header differs from the expected one", "missing", missing, "unknown", unknown)
+	}
+	return m, nil
+}
+
+// readColumnMap reads the header line from scanner.
+func readColumnMap(scanner *bufio.Scanner) (*columnMap, error) {
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("scan error while looking for header: %w", scanner.Err())
+	}
+	return newColumnMap(strings.TrimSpace(scanner.Text()))
+}
+
+// reorder returns parts in Columns order, or nil if parts is too short
+// to have the required columns.
+func (m *columnMap) reorder(parts []string) []string {
+	if len(parts) < m.minWidth {
+		return nil
+	}
+	if m.identity {
+		return parts
+	}
+	out := make([]string, len(m.source), len(m.source)+len(m.extra))
+	for j, i := range m.source {
+		if i >= 0 && i < len(parts) {
+			out[j] = parts[i]
+		}
+	}
+	for _, i := range m.extra {
+		value := ""
+		if i < len(parts) {
+			value = parts[i]
+		}
+		out = append(out, value)
+	}
+	return out
+}
@@ -0,0 +1,174 @@
+package metarcsv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// apiMETAR is an Observation in the data API's JSON.  Numbers that are
+// sometimes strings, like a wind direction of "VRB" or visibility of "10+",
+// are kept raw.
+type apiMETAR struct {
+	IcaoID    string          `json:"icaoId"`
+	ObsTime   int64           `json:"obsTime"`
+	RawOb     string          `json:"rawOb"`
+	Lat       json.RawMessage `json:"lat"`
+	Lon       json.RawMessage `json:"lon"`
+	Elev      json.RawMessage `json:"elev"`
+	Temp      json.RawMessage `json:"temp"`
+	Dewp      json.RawMessage `json:"dewp"`
+	Wdir      json.RawMessage `json:"wdir"`
+	Wspd      json.RawMessage `json:"wspd"`
+	Wgst      json.RawMessage `json:"wgst"`
+	Visib     json.RawMessage `json:"visib"`
+	Altim     *float64        `json:"altim"`
+	Slp       json.RawMessage `json:"slp"`
+	QCField   int             `json:"qcField"`
+	WxString  string          `json:"wxString"`
+	PresTend  json.RawMessage `json:"presTend"`
+	MaxT      json.RawMessage `json:"maxT"`
+	MinT      json.RawMessage `json:"minT"`
+	MaxT24    json.RawMessage `json:"maxT24"`
+	MinT24    json.RawMessage `json:"minT24"`
+	Precip    json.RawMessage `json:"precip"`
+	Pcp3hr    json.RawMessage `json:"pcp3hr"`
+	Pcp6hr    json.RawMessage `json:"pcp6hr"`
+	Pcp24hr   json.RawMessage `json:"pcp24hr"`
+	Snow      json.RawMessage `json:"snow"`
+	VertVis   json.RawMessage `json:"vertVis"`
+	MetarType string          `json:"metarType"`
+	FltCat    string          `json:"fltCat"`
+	Clouds    []struct {
+		Cover string          `json:"cover"`
+		Base  json.RawMessage `json:"base"`
+	} `json:"clouds"`
+}
+
+// qcFlags are the qcField bits, in the order of the CSV's flag columns from
+// corrected on.
+var qcFlags = []string{"corrected", "auto", "auto_station", "maintenance_indicator_on", "no_signal", "lightning_sensor_off", "freezing_rain_sensor_off", "present_weather_sensor_off"}
+
+// hPaToInHg converts the API's altimeter setting to the CSV's unit.
+const hPaToInHg = 0.0295300
+
+// jsonText returns a raw JSON scalar as the CSV would have it: strings
+// unquoted, and null or missing as empty.
+func jsonText(raw json.RawMessage) string {
+	text := string(bytes.TrimSpace(raw))
+	if text == "null" {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		return unquoted
+	}
+	return text
+}
+
+// parts returns m as csv_parts in Columns order, so observations from
+// JSON are stored just like ones from the CSV.
+func (m *apiMETAR) parts() []string {
+	parts := make([]string, len(Columns))
+	set := func(column, value string) {
+		if i, ok := ColumnIndex[column]; ok {
+			parts[i] = value
+		}
+	}
+	set("raw_text", m.RawOb)
+	set("station_id", m.IcaoID)
+	if m.ObsTime != 0 {
+		set("observation_time", time.Unix(m.ObsTime, 0).UTC().Format(time.RFC3339))
+	}
+	for column, raw := range map[string]json.RawMessage{
+		"latitude": m.Lat, "longitude": m.Lon, "elevation_m": m.Elev,
+		"temp_c": m.Temp, "dewpoint_c": m.Dewp,
+		"wind_dir_degrees": m.Wdir, "wind_speed_kt": m.Wspd, "wind_gust_kt": m.Wgst,
+		"visibility_statute_mi": m.Visib, "sea_level_pressure_mb": m.Slp,
+		"three_hr_pressure_tendency_mb": m.PresTend,
+		"maxT_c":                        m.MaxT, "minT_c": m.MinT, "maxT24hr_c": m.MaxT24, "minT24hr_c": m.MinT24,
+		"precip_in": m.Precip, "pcp3hr_in": m.Pcp3hr, "pcp6hr_in": m.Pcp6hr, "pcp24hr_in": m.Pcp24hr,
+		"snow_in": m.Snow, "vert_vis_ft": m.VertVis,
+	} {
+		set(column, jsonText(raw))
+	}
+	if m.Altim != nil {
+		set("altim_in_hg", strconv.FormatFloat(*m.Altim*hPaToInHg, 'f', 2, 64))
+	}
+	for bit, column := range qcFlags {
+		if m.QCField&(1<<bit) != 0 {
+			set(column, "TRUE")
+		}
+	}
+	set("wx_string", m.WxString)
+	set("metar_type", m.MetarType)
+	set("flight_category", m.FltCat)
+	// the CSV only has room for four layers
+	for i, cloud := range m.Clouds {
+		if i == 4 {
+			break
+		}
+		suffix := ""
+		if i > 0 {
+			suffix = fmt.Sprintf("_%d", i+1)
+		}
+		set("sky_cover"+suffix, cloud.Cover)
+		set("cloud_base_ft_agl"+suffix, jsonText(cloud.Base))
+	}
+	return parts
+}
+
+// jsonRecords reads the data API's JSON, an array of apiMETAR objects.
+type jsonRecords struct {
+	decoder *json.Decoder
+	opts    *Options
+	raw     json.RawMessage
+	readErr error
+}
+
+func newJSONRecords(r io.Reader, opts *Options) (*jsonRecords, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("reading json: %w", err)
+	} else if token != json.Delim('[') {
+		return nil, fmt.Errorf("expected a json array, got %v", token)
+	}
+	return &jsonRecords{decoder: decoder, opts: opts}, nil
+}
+
+func (j *jsonRecords) Next() bool {
+	if !j.decoder.More() {
+		if _, err := j.decoder.Token(); err != nil {
+			j.readErr = fmt.Errorf("reading json: %w", err)
+		}
+		return false
+	}
+	j.raw = nil
+	if err := j.decoder.Decode(&j.raw); err != nil {
+		j.readErr = fmt.Errorf("reading json: %w", err)
+		return false
+	}
+	return true
+}
+
+func (j *jsonRecords) Record() (string, *Observation, error) {
+	text := string(j.raw)
+	var m apiMETAR
+	if err := json.Unmarshal(j.raw, &m); err != nil {
+		return text, nil, fmt.Errorf("parsing json: %w", err)
+	}
+	parts := m.parts()
+	if len(parts[ColRawText]) < 5 {
+		slog.Debug("invalid record", "text", text)
+		return text, nil, nil
+	}
+	obs, err := NewObservation(parts, j.opts)
+	return text, obs, err
+}
+
+func (j *jsonRecords) Err() error {
+	return j.readErr
+}
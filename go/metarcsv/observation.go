@@ -0,0 +1,113 @@
+// Package metarcsv reads METARs as AWC serves them in bulk: the metars cache
+// file and the data API's CSV, JSON and XML.  Each report becomes an
+// Observation holding its fields in the cache file's column order, whatever
+// the source, so that they can be stored and compared alike.
+package metarcsv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"mattdee123.com/aviationweather/metar"
+)
+
+// Observation is a single parsed line of the metars file.
+type Observation struct {
+	Station         string
+	ObservationTime time.Time
+	// Parts are the line's fields in Columns order, followed by any columns
+	// Columns doesn't know about.
+	Parts []string
+}
+
+// Field returns the column at index i, like ColRawText, or "" for a short
+// line.
+func (o *Observation) Field(i int) string {
+	if i >= len(o.Parts) {
+		return ""
+	}
+	return o.Parts[i]
+}
+
+// Number parses the column at index i as a number.  ok is false if the column
+// is missing, empty or not a number.  A trailing "+" (as in "10+" visibility)
+// is ignored.
+func (o *Observation) Number(i int) (value float64, ok bool) {
+	text := strings.TrimSuffix(o.Field(i), "+")
+	if text == "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	return value, err == nil
+}
+
+// FlightCategory computes o's flight category from its raw text, falling
+// back to the CSV visibility if the text has none.
+func (o *Observation) FlightCategory() string {
+	decoded, err := metar.Decode(o.Field(ColRawText))
+	if err != nil {
+		return ""
+	}
+	conditions := decoded.Conditions
+	if conditions.Miles() == nil {
+		if miles, ok := o.Number(ColVisibilityMi); ok {
+			conditions.Visibility = &metar.Visibility{StatuteMiles: &miles}
+		}
+	}
+	return conditions.FlightCategory()
+}
+
+// Options changes how records are read.  The zero value reads them as they
+// are.
+type Options struct {
+	// Aliases renames stations as they're read.
+	Aliases map[string]string
+	// RoundTime, if positive, rounds ObservationTime, which Parts keeps as
+	// reported.
+	RoundTime time.Duration
+	// LenientPreamble logs unexpected preamble lines instead of failing.
+	LenientPreamble bool
+	// LenientCount logs a cache file whose line count differs from its
+	// preamble's instead of failing.
+	LenientCount bool
+}
+
+// parseLine parses a data line of the metars file.  It returns nil if the
+// line should be skipped as invalid.
+func parseLine(text string, columns *columnMap, opts *Options) (*Observation, error) {
+	parts, err := csv.NewReader(strings.NewReader(text)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("parsing csv: %w", err)
+	}
+	parts = columns.reorder(parts)
+	// sometimes there's a cut-off line.  some rough heuristics to catch this
+	if parts == nil || len(parts[ColRawText]) < 5 {
+		slog.Debug("invalid line", "text", text)
+		return nil, nil
+	}
+	return NewObservation(parts, opts)
+}
+
+// NewObservation returns the observation for parts, in Columns order, after
+// applying opts' aliases and rounding.
+func NewObservation(parts []string, opts *Options) (*Observation, error) {
+	if alias, ok := opts.Aliases[parts[ColStation]]; ok {
+		parts[ColStation] = alias
+	}
+	observationTime, err := time.Parse(time.RFC3339, parts[ColObservationTime])
+	if err != nil {
+		return nil, fmt.Errorf("bad time %q: %w", parts[ColObservationTime], err)
+	}
+	if opts.RoundTime > 0 {
+		observationTime = observationTime.Round(opts.RoundTime)
+	}
+	return &Observation{
+		Station:         parts[ColStation],
+		ObservationTime: observationTime,
+		Parts:           parts,
+	}, nil
+}
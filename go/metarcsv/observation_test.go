@@ -0,0 +1,78 @@
+package metarcsv
+
+import (
+	"testing"
+	"time"
+)
+
+func testParts(station, at string) []string {
+	parts := make([]string, len(Columns))
+	parts[ColRawText] = station + " 141153Z 27010KT 10SM CLR 12/05 A2992"
+	parts[ColStation] = station
+	parts[ColObservationTime] = at
+	return parts
+}
+
+func TestNewObservationAliases(t *testing.T) {
+	opts := &Options{Aliases: map[string]string{"PAFA": "PAFB"}}
+	for _, test := range []struct {
+		station, want string
+	}{
+		{"PAFA", "PAFB"},
+		{"PAFB", "PAFB"},
+		{"KBOS", "KBOS"},
+	} {
+		obs, err := NewObservation(testParts(test.station, "2026-10-14T11:53:00Z"), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obs.Station != test.want || obs.Field(ColStation) != test.want {
+			t.Errorf("%s stored as %s (column %s), want %s", test.station, obs.Station, obs.Field(ColStation), test.want)
+		}
+		// the raw text is left as reported
+		if obs.Field(ColRawText) != test.station+" 141153Z 27010KT 10SM CLR 12/05 A2992" {
+			t.Errorf("%s: raw text changed to %q", test.station, obs.Field(ColRawText))
+		}
+	}
+}
+
+func TestNewObservationBadTime(t *testing.T) {
+	if _, err := NewObservation(testParts("KBOS", "2026-10-14 11:53"), &Options{}); err == nil {
+		t.Error("bad time: got no error")
+	}
+	obs, err := NewObservation(testParts("KBOS", "2026-10-14T11:53:00Z"), &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 14, 11, 53, 0, 0, time.UTC); !obs.ObservationTime.Equal(want) {
+		t.Errorf("time = %v, want %v", obs.ObservationTime, want)
+	}
+}
+
+func TestNewObservationRoundTime(t *testing.T) {
+	for _, test := range []struct {
+		at    string
+		round time.Duration
+		want  string
+	}{
+		{"2026-10-14T11:53:29Z", 0, "2026-10-14T11:53:29Z"},
+		{"2026-10-14T11:53:29Z", time.Minute, "2026-10-14T11:53:00Z"},
+		{"2026-10-14T11:53:30Z", time.Minute, "2026-10-14T11:54:00Z"},
+		{"2026-10-14T11:52:29Z", 5 * time.Minute, "2026-10-14T11:50:00Z"},
+		{"2026-10-14T11:52:30Z", 5 * time.Minute, "2026-10-14T11:55:00Z"},
+		{"2026-10-14T23:58:00Z", 5 * time.Minute, "2026-10-15T00:00:00Z"},
+		{"2026-10-14T11:40:00Z", time.Hour, "2026-10-14T12:00:00Z"},
+	} {
+		obs, err := NewObservation(testParts("KBOS", test.at), &Options{RoundTime: test.round})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := obs.ObservationTime.Format(time.RFC3339); got != test.want {
+			t.Errorf("%s rounded to %v: %s, want %s", test.at, test.round, got, test.want)
+		}
+		// the column keeps the time as reported
+		if got := obs.Field(ColObservationTime); got != test.at {
+			t.Errorf("%s rounded to %v: column changed to %s", test.at, test.round, got)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package metarcsv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"mattdee123.com/aviationweather/awc"
+)
+
+// preamble is the cache file's, before its header.
+var preamble = awc.Preamble("metars")
+
+// Records reads the observations in an input file one at a time, like a
+// bufio.Scanner.
+type Records interface {
+	// Next advances to the next record, returning false at the end of the
+	// input or on an error reading it, which Err then returns.
+	Next() bool
+	// Record returns the current record's text, for logs, and its
+	// observation, which is nil for a cut-off line.  err is set if the
+	// record couldn't be parsed.
+	Record() (text string, obs *Observation, err error)
+	Err() error
+}
+
+// Open reads the header of r, if it has one, and returns its records.
+// format is csv, json, xml, or auto to tell them apart by the first byte.
+// A nil opts is the zero Options.
+func Open(r io.Reader, format string, opts *Options) (Records, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	buffered := bufio.NewReader(r)
+	if format == "auto" {
+		format = sniffFormat(buffered)
+	}
+	switch format {
+	case "csv":
+		return newCSVRecords(buffered, opts)
+	case "json":
+		return newJSONRecords(buffered, opts)
+	case "xml":
+		return newXMLRecords(buffered, opts)
+	}
+	return nil, fmt.Errorf("unknown input format %q", format)
+}
+
+// sniffFormat guesses the format of r from its first non-space byte, without
+// consuming it.  The data API's JSON is an array of objects, and XML starts
+// with a declaration or the response element.
+func sniffFormat(r *bufio.Reader) string {
+	for n := 1; ; n++ {
+		peeked, _ := r.Peek(n)
+		if len(peeked) < n {
+			// an empty input or read error is left for the reader to report
+			return "csv"
+		}
+		switch peeked[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[', '{':
+			return "json"
+		case '<':
+			return "xml"
+		default:
+			return "csv"
+		}
+	}
+}
+
+// csvRecords reads a cache file or the data API's CSV.
+type csvRecords struct {
+	scanner *bufio.Scanner
+	columns *columnMap
+	opts    *Options
+	lines   int
+	// results is the number of lines the preambles give, or -1 for the
+	// data API's CSV, which doesn't have one.
+	results int
+	text    string
+	readErr error
+}
+
+func newCSVRecords(r io.Reader, opts *Options) (*csvRecords, error) {
+	scanner := bufio.NewScanner(r)
+	columns, results, err := readHeader(scanner, opts.LenientPreamble)
+	if err != nil {
+		return nil, fmt.Errorf("bad headers: %w", err)
+	}
+	return &csvRecords{scanner: scanner, columns: columns, opts: opts, results: results}, nil
+}
+
+func (c *csvRecords) Next() bool {
+	for c.scanner.Scan() {
+		c.text = strings.ReplaceAll(c.scanner.Text(), "\x00", "")
+		// files that have passed through a caching proxy sometimes have the
+		// whole preamble and header repeated partway through
+		if preamble[0].MatchString(c.text) {
+			results, err := checkPreamble(preamble[1:], c.scanner, c.opts.LenientPreamble)
+			if err != nil {
+				c.readErr = fmt.Errorf("bad repeated headers: %w", err)
+				return false
+			}
+			if c.results >= 0 {
+				c.results += results
+			}
+			columns, err := readColumnMap(c.scanner)
+			if err != nil {
+				c.readErr = fmt.Errorf("bad repeated headers: %w", err)
+				return false
+			}
+			c.columns = columns
+			slog.Warn("skipping repeated header block", "line", c.lines)
+			continue
+		}
+		c.lines++
+		return true
+	}
+	if err := c.scanner.Err(); err != nil {
+		c.readErr = fmt.Errorf("reading file: %w", err)
+	} else if c.results >= 0 && c.lines != c.results {
+		// most likely a download cut off at a line break
+		err := fmt.Errorf("the preamble gives %d results, but there are %d lines", c.results, c.lines)
+		if !c.opts.LenientCount {
+			c.readErr = err
+		} else {
+			slog.Warn("result count mismatch", "results", c.results, "lines", c.lines)
+		}
+	}
+	return false
+}
+
+func (c *csvRecords) Record() (string, *Observation, error) {
+	obs, err := parseLine(c.text, c.columns, c.opts)
+	return c.text, obs, err
+}
+
+func (c *csvRecords) Err() error {
+	return c.readErr
+}
+
+// readHeader reads up to and including the header line, and returns the
+// header's column map and the number of results the preamble gives.  Cache
+// files have a preamble before the header, which is checked as for
+// awc.CheckPreamble; the data API's CSV starts straight at the header,
+// and its result count is -1.
+func readHeader(scanner *bufio.Scanner, lenient bool) (*columnMap, int, error) {
+	if !scanner.Scan() {
+		return nil, 0, fmt.Errorf("scan error while looking for preamble: %w", scanner.Err())
+	}
+	first := strings.TrimSpace(scanner.Text())
+	if strings.Contains(","+first+",", ",raw_text,") {
+		columns, err := newColumnMap(first)
+		return columns, -1, err
+	}
+	patterns := preamble
+	if preamble[0].MatchString(first) {
+		patterns = preamble[1:]
+	} else if !lenient {
+		return nil, 0, fmt.Errorf("expected %v, got %q", preamble[0], first)
+	} else {
+		slog.Warn("unexpected preamble line", "line", first)
+	}
+	results, err := checkPreamble(patterns, scanner, lenient)
+	if err != nil {
+		return nil, 0, err
+	}
+	columns, err := readColumnMap(scanner)
+	return columns, results, err
+}
+
+// checkPreamble checks the rest of a preamble as for
+// awc.CheckPreamble, and returns the number of results it gives.
+func checkPreamble(patterns []*regexp.Regexp, scanner *bufio.Scanner, lenient bool) (int, error) {
+	if err := awc.CheckPreamble(patterns, scanner, lenient); err != nil {
+		return 0, err
+	}
+	// the last line checked is the result count
+	results, ok := awc.ResultCount(scanner.Text())
+	if !ok {
+		return 0, fmt.Errorf("bad result count %q", scanner.Text())
+	}
+	return results, nil
+}
@@ -0,0 +1,78 @@
+package metarcsv
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// stations reads file and returns each record's station, failing t if any
+// doesn't parse, along with the reader's error.
+func stations(t *testing.T, file string) ([]string, error) {
+	t.Helper()
+	records, err := Open(strings.NewReader(file), "csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for records.Next() {
+		_, obs, err := records.Record()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, obs.Station)
+	}
+	return got, records.Err()
+}
+
+// logged returns what's logged while f runs.
+func logged(t *testing.T, f func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	f()
+	return buf.String()
+}
+
+func TestRepeatedHeader(t *testing.T) {
+	file, err := os.ReadFile("testdata/doubled_header.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	logs := logged(t, func() { got, err = stations(t, string(file)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every row on both sides of the repeat, which only counts its own
+	if want := []string{"KJFK", "EGLL", "KBOS"}; !slices.Equal(got, want) {
+		t.Errorf("stations %v, want %v", got, want)
+	}
+	if !strings.Contains(logs, "level=WARN") || !strings.Contains(logs, "skipping repeated header block") {
+		t.Errorf("no warning logged: %q", logs)
+	}
+
+	lines := strings.SplitAfter(string(file), "\n")
+	// the repeated block is lines 8 to 13
+	repeated := func(block ...string) string {
+		return strings.Join(lines[:8], "") + strings.Join(block, "") + strings.Join(lines[14:], "")
+	}
+	for _, test := range []struct {
+		name  string
+		block []string
+	}{
+		{"cut off before the header", lines[8:11]},
+		{"without its result count", append(slices.Clone(lines[8:12]), lines[13])},
+		{"for another source", append([]string{"No errors\n", "No warnings\n", "4 ms\n", "data source=tafs\n"}, lines[12:14]...)},
+		{"with no header", lines[8:13]},
+	} {
+		logged(t, func() { _, err = stations(t, repeated(test.block...)) })
+		if err == nil || !strings.Contains(err.Error(), "bad repeated headers") {
+			t.Errorf("%s: got %v, want bad repeated headers", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package metarcsv
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// xmlElement is any element of a dataserver XML response, kept generically
+// since METAR's children are named after the CSV's columns.
+type xmlElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Value    string       `xml:",chardata"`
+	Children []xmlElement `xml:",any"`
+}
+
+// parts returns a METAR element as csv_parts in Columns order.  Its
+// children are named like the columns, except that the flags are grouped
+// under quality_control_flags and each cloud layer is a sky_condition with
+// sky_cover and cloud_base_ft_agl attributes.
+func (e *xmlElement) parts() []string {
+	parts := make([]string, len(Columns))
+	set := func(column, value string) {
+		if i, ok := ColumnIndex[column]; ok {
+			parts[i] = strings.TrimSpace(value)
+		}
+	}
+	layers := 0
+	for _, child := range e.Children {
+		switch child.XMLName.Local {
+		case "quality_control_flags":
+			for _, flag := range child.Children {
+				set(flag.XMLName.Local, flag.Value)
+			}
+		case "sky_condition":
+			// the CSV only has room for four layers
+			layers++
+			if layers > 4 {
+				continue
+			}
+			suffix := ""
+			if layers > 1 {
+				suffix = fmt.Sprintf("_%d", layers)
+			}
+			for _, attr := range child.Attrs {
+				set(attr.Name.Local+suffix, attr.Value)
+			}
+		default:
+			set(child.XMLName.Local, child.Value)
+		}
+	}
+	return parts
+}
+
+// xmlRecords reads the METAR elements of a dataserver XML response, as the
+// retired ADDS dataserver served them.
+type xmlRecords struct {
+	decoder *xml.Decoder
+	opts    *Options
+	metar   xmlElement
+	readErr error
+}
+
+func newXMLRecords(r io.Reader, opts *Options) (*xmlRecords, error) {
+	return &xmlRecords{decoder: xml.NewDecoder(r), opts: opts}, nil
+}
+
+func (x *xmlRecords) Next() bool {
+	for {
+		token, err := x.decoder.Token()
+		if err == io.EOF {
+			return false
+		} else if err != nil {
+			x.readErr = fmt.Errorf("reading xml: %w", err)
+			return false
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "errors":
+			var response struct {
+				Errors []string `xml:"error"`
+			}
+			if err := x.decoder.DecodeElement(&response, &start); err != nil {
+				x.readErr = fmt.Errorf("reading xml: %w", err)
+				return false
+			}
+			if len(response.Errors) > 0 {
+				x.readErr = errors.New("response has errors: " + strings.Join(response.Errors, "; "))
+				return false
+			}
+		case "METAR":
+			x.metar = xmlElement{}
+			if err := x.decoder.DecodeElement(&x.metar, &start); err != nil {
+				x.readErr = fmt.Errorf("reading xml: %w", err)
+				return false
+			}
+			return true
+		}
+	}
+}
+
+func (x *xmlRecords) Record() (string, *Observation, error) {
+	raw, err := xml.Marshal(&x.metar)
+	if err != nil {
+		return "", nil, fmt.Errorf("encoding xml: %w", err)
+	}
+	text := string(raw)
+	parts := x.metar.parts()
+	if len(parts[ColRawText]) < 5 {
+		slog.Debug("invalid record", "text", text)
+		return text, nil, nil
+	}
+	obs, err := NewObservation(parts, x.opts)
+	return text, obs, err
+}
+
+func (x *xmlRecords) Err() error {
+	return x.readErr
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	pq "github.com/lib/pq"
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/scraping"
+)
+
+const airsigmetURL = "https://www.aviationweather.gov/adds/dataserver_current/current/airsigmets.cache.csv.gz"
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// requiredColumns are the columns the scraper reads by name.  The rest of the
+// header isn't checked, so upstream adding or reordering columns is fine.
+var requiredColumns = []string{"raw_text", "valid_time_from", "valid_time_to", pointsColumn, "min_ft_msl", "max_ft_msl", "hazard", "severity", "airsigmet_type"}
+
+// pointsColumn holds the polygon of the advisory area, as lon:lat pairs
+// separated by semicolons.
+const pointsColumn = "lon:lat points"
+
+type Flags struct {
+	dbURL           string
+	url             string
+	filename        string
+	download        bool
+	deleteFile      bool
+	downloader      awc.Downloader
+	lenientPreamble bool
+	logging         scraping.Logging
+}
+
+func (f *Flags) Parse(args []string) {
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.url, "url", airsigmetURL, "url to download from; .gz and .zst are both supported")
+	fs.StringVar(&f.filename, "filename", "", "file to download to and read from; if unset with -download, the download is streamed straight into the database")
+	fs.BoolVar(&f.download, "download", true, "if set, file will be downloaded")
+	fs.BoolVar(&f.deleteFile, "delete", true, "if set, file will be deleted on success")
+	f.downloader.AddFlags(fs)
+	fs.BoolVar(&f.lenientPreamble, "lenient-preamble", false, "if set, log unexpected lines before the header, like upstream warnings, instead of failing the run")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// a second signal kills the process as usual
+		<-ctx.Done()
+		stop()
+	}()
+	flags := &Flags{}
+	flags.Parse(os.Args[1:])
+	if err := run(ctx, flags); err != nil {
+		scraping.Exit(err)
+	}
+}
+
+func run(ctx context.Context, flags *Flags) error {
+	input, err := flags.downloader.Input(ctx, flags.download, flags.url, flags.filename)
+	if errors.Is(err, awc.ErrNotModified) {
+		slog.Info("unchanged since the last run, skipping", "url", flags.url)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	db, err := sql.Open("postgres", flags.dbURL)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	if err := readToDB(ctx, db, input, flags.lenientPreamble); err != nil {
+		if ctx.Err() != nil && flags.download && flags.deleteFile && flags.filename != "" {
+			// it would have been removed on success
+			os.Remove(flags.filename)
+		}
+		return fmt.Errorf("storing in database: %w", err)
+	}
+	if err := flags.downloader.Archive(ctx); err != nil {
+		return err
+	}
+	if err := flags.downloader.SaveValidators(); err != nil {
+		return fmt.Errorf("saving validators: %w", err)
+	}
+	if flags.deleteFile && flags.filename != "" {
+		if err := os.Remove(flags.filename); err != nil {
+			return fmt.Errorf("removing file: %w", err)
+		}
+	}
+	return nil
+}
+
+func readToDB(ctx context.Context, db *sql.DB, r io.Reader, lenientPreamble bool) error {
+	scanner := bufio.NewScanner(r)
+	columns, err := awc.ReadHeader(scanner, "airsigmets", lenientPreamble, requiredColumns...)
+	if err != nil {
+		return fmt.Errorf("bad headers: %w", err)
+	}
+	index := awc.ColumnIndex(columns)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	count := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("shutting down, rolling back %d airsigmets: %w", count, err)
+		}
+		text := strings.ReplaceAll(scanner.Text(), "\x00", "")
+		written, err := writeLine(tx, index, text)
+		if err != nil {
+			return fmt.Errorf("writing line %q: %w", text, err)
+		}
+		if written {
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	slog.Info("wrote airsigmets", "rows", count)
+	return nil
+}
+
+// parsePoints parses the polygon in value into [lon, lat] pairs.
+func parsePoints(value string) ([][2]float64, error) {
+	points := [][2]float64{}
+	for _, pair := range strings.Split(value, ";") {
+		if pair == "" {
+			continue
+		}
+		lon, lat, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("bad point %q", pair)
+		}
+		var point [2]float64
+		for i, s := range []string{lon, lat} {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad point %q: %w", pair, err)
+			}
+			point[i] = f
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// nullableNumber parses value as a number, returning nil for an empty one.
+func nullableNumber(value string) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// writeLine upserts the advisory in text.  It returns false if the line was
+// skipped as invalid.
+func writeLine(tx *sql.Tx, index map[string]int, text string) (bool, error) {
+	parts, err := csv.NewReader(strings.NewReader(text)).Read()
+	if err != nil {
+		return false, fmt.Errorf("parsing line: %w", err)
+	}
+	field := func(name string) string {
+		if i := index[name]; i < len(parts) {
+			return parts[i]
+		}
+		return ""
+	}
+	// sometimes there's a cut-off line.  some rough heuristics to catch this
+	if len(parts) < len(index) || len(field("raw_text")) < 5 {
+		slog.Debug("invalid line", "text", text)
+		return false, nil
+	}
+	row := map[string]interface{}{
+		"raw_text":       field("raw_text"),
+		"airsigmet_type": field("airsigmet_type"),
+		"hazard":         field("hazard"),
+		"severity":       field("severity"),
+		"csv_parts":      pq.StringArray(parts),
+	}
+	for _, col := range []string{"valid_time_from", "valid_time_to"} {
+		t, err := time.Parse(time.RFC3339, field(col))
+		if err != nil {
+			return false, fmt.Errorf("bad %s %q: %w", col, field(col), err)
+		}
+		row[col] = t
+	}
+	for _, col := range []string{"min_ft_msl", "max_ft_msl"} {
+		value, err := nullableNumber(field(col))
+		if err != nil {
+			return false, fmt.Errorf("bad %s %q: %w", col, field(col), err)
+		}
+		row[col] = value
+	}
+	points, err := parsePoints(field(pointsColumn))
+	if err != nil {
+		return false, err
+	}
+	encoded, err := json.Marshal(points)
+	if err != nil {
+		return false, fmt.Errorf("encoding points: %w", err)
+	}
+	row["points"] = string(encoded)
+	// advisories have no identifier of their own, and amendments come with
+	// new text, so the text and start of validity are the key
+	_, err = psql.Insert("airsigmets").SetMap(row).
+		Suffix("ON CONFLICT (valid_time_from, raw_text) DO UPDATE set valid_time_to=EXCLUDED.valid_time_to, airsigmet_type=EXCLUDED.airsigmet_type, hazard=EXCLUDED.hazard, severity=EXCLUDED.severity, min_ft_msl=EXCLUDED.min_ft_msl, max_ft_msl=EXCLUDED.max_ft_msl, points=EXCLUDED.points, csv_parts=EXCLUDED.csv_parts").
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// alertConfig is the -alert-rules file, like
+//
+//	smtp:
+//	  addr: smtp.example.com:587
+//	  from: metar@example.com
+//	  username: metar
+//	  password_file: /run/secrets/smtp
+//	rules:
+//	  - name: KBED below minimums
+//	    stations: [KBED]
+//	    when: ceiling < 1000 ft or visibility < 3 sm
+//	    notify:
+//	      - slack: https://hooks.slack.com/services/...
+//	      - email: pilot@example.com
+//	      - webhook: https://example.com/alerts
+type alertConfig struct {
+	SMTP  *smtpConfig  `yaml:"smtp"`
+	Rules []*alertRule `yaml:"rules"`
+}
+
+type smtpConfig struct {
+	Addr         string `yaml:"addr"`
+	From         string `yaml:"from"`
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+type alertRule struct {
+	Name     string         `yaml:"name"`
+	Stations []string       `yaml:"stations"`
+	When     string         `yaml:"when"`
+	Notify   []*alertTarget `yaml:"notify"`
+	expr     alertExpr
+}
+
+// alertTarget is one place to notify; exactly one field is set.
+type alertTarget struct {
+	Slack   string `yaml:"slack"`
+	Email   string `yaml:"email"`
+	Webhook string `yaml:"webhook"`
+}
+
+// readAlertConfig reads and checks the rules in fname.
+func readAlertConfig(fname string) (*alertConfig, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	config := &alertConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	for i, r := range config.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if len(r.Stations) == 0 {
+			return nil, fmt.Errorf("%s: no stations", r.Name)
+		}
+		r.Stations = splitList(r.Stations)
+		if r.expr, err = parseAlertExpr(r.When); err != nil {
+			return nil, fmt.Errorf("%s: bad when: %w", r.Name, err)
+		}
+		for _, t := range r.Notify {
+			if t.Email != "" && config.SMTP == nil {
+				return nil, fmt.Errorf("%s: email needs smtp settings", r.Name)
+			}
+		}
+	}
+	return config, nil
+}
+
+// alertNotification is what a webhook target is posted.
+type alertNotification struct {
+	Rule    string `json:"rule"`
+	Station string `json:"station"`
+	When    string `json:"when"`
+	// State is "triggered" when the rule starts matching a station's
+	// observations and "cleared" when it stops.
+	State       string          `json:"state"`
+	Observation *apiObservation `json:"observation"`
+}
+
+func (n *alertNotification) text() string {
+	return fmt.Sprintf("%s %s at %s (%s): %s", n.Rule, n.State, n.Station, n.When, n.Observation.Columns["raw_text"])
+}
+
+// alertStore evaluates the alert rules once a file commits, against each
+// watched station's newest observation in it, notifying when a rule's
+// result differs from the one for the station's newest stored observation
+// before the file.  So a rule notifies once as conditions go bad and once as
+// they clear, without keeping any state between runs.  Failed notifications
+// are logged rather than failing the run.
+type alertStore struct {
+	db       *sql.DB
+	dialect  *store.Dialect
+	config   *alertConfig
+	previous map[string]*metarcsv.Observation
+	newest   map[string]*metarcsv.Observation
+}
+
+func (s *alertStore) stations() []string {
+	var stations []string
+	for _, r := range s.config.Rules {
+		stations = append(stations, r.Stations...)
+	}
+	return stations
+}
+
+func (s *alertStore) Begin(ctx context.Context) error {
+	s.newest = map[string]*metarcsv.Observation{}
+	previous, err := newestObservations(ctx, s.db, s.dialect, s.stations())
+	if err != nil {
+		return fmt.Errorf("reading latest observations for alerts: %w", err)
+	}
+	s.previous = previous
+	return nil
+}
+
+func (s *alertStore) Write(obs *metarcsv.Observation) error {
+	prev := s.newest[obs.Station]
+	if prev == nil {
+		prev = s.previous[obs.Station]
+	}
+	if prev == nil || obs.ObservationTime.After(prev.ObservationTime) {
+		s.newest[obs.Station] = obs
+	}
+	return nil
+}
+
+func (s *alertStore) Commit() error {
+	newest := s.newest
+	s.newest = nil
+	for _, r := range s.config.Rules {
+		for _, station := range r.Stations {
+			obs := newest[station]
+			if obs == nil {
+				continue
+			}
+			now := r.expr.match(obs)
+			before := false
+			if prev := s.previous[station]; prev != nil {
+				before = r.expr.match(prev)
+			}
+			if now == before {
+				continue
+			}
+			n := &alertNotification{Rule: r.Name, Station: station, When: r.When, State: "cleared", Observation: newAPIObservation(obs)}
+			if now {
+				n.State = "triggered"
+			}
+			slog.Info("alert", "rule", r.Name, "station", station, "state", n.State)
+			for _, t := range r.Notify {
+				if err := s.notify(t, n); err != nil {
+					slog.Warn("sending alert", "rule", r.Name, "station", station, "err", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *alertStore) Rollback() {
+	s.newest = nil
+}
+
+func (s *alertStore) notify(t *alertTarget, n *alertNotification) error {
+	switch {
+	case t.Slack != "":
+		return postJSON(t.Slack, map[string]string{"text": n.text()})
+	case t.Webhook != "":
+		return postJSON(t.Webhook, n)
+	case t.Email != "":
+		return sendEmail(s.config.SMTP, t.Email, n)
+	}
+	return errors.New("notify target with no slack, email or webhook")
+}
+
+func sendEmail(config *smtpConfig, to string, n *alertNotification) error {
+	var auth smtp.Auth
+	if config.Username != "" {
+		password := ""
+		if config.PasswordFile != "" {
+			data, err := os.ReadFile(config.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("reading smtp password: %w", err)
+			}
+			password = strings.TrimSpace(string(data))
+		}
+		host, _, _ := strings.Cut(config.Addr, ":")
+		auth = smtp.PlainAuth("", config.Username, password, host)
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s %s at %s\r\n\r\n%s\r\n",
+		config.From, to, n.Rule, n.State, n.Station, n.text())
+	return smtp.SendMail(config.Addr, auth, config.From, []string{to}, []byte(message))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/store"
+)
+
+func TestAlertStationsCaseInsensitive(t *testing.T) {
+	var mu sync.Mutex
+	var got []alertNotification
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n alertNotification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		got = append(got, n)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	fname := filepath.Join(t.TempDir(), "alerts.yaml")
+	yaml := `rules:
+  - name: low
+    stations: [kbed, "kbos, kjfk"]
+    when: ceiling < 1000 ft
+    notify:
+      - webhook: ` + hook.URL + "\n"
+	if err := os.WriteFile(fname, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := readAlertConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"KBED", "KBOS", "KJFK"}; !slices.Equal(config.Rules[0].Stations, want) {
+		t.Fatalf("stations = %q, want %q", config.Rules[0].Stations, want)
+	}
+
+	s := &alertStore{db: testDB(t), dialect: store.SQLite, config: config}
+	at := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	writeAll(t, s,
+		testObservation(t, "KBED", at, "KBED 141200Z 27005KT 2SM BR OVC004 10/09 A3001", nil),
+		testObservation(t, "KBOS", at, "KBOS 141200Z 27005KT 10SM FEW250 10/02 A3001", nil))
+	if len(got) != 1 || got[0].Station != "KBED" || got[0].State != "triggered" {
+		t.Errorf("notifications = %+v, want KBED triggered", got)
+	}
+}
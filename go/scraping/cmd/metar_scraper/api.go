@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"mattdee123.com/aviationweather/awc"
+)
+
+// sourceURL returns the url to scrape: -url if it's set, otherwise the data
+// API query given by the -api-* flags or the cache file, as -source says.
+func (f *Flags) sourceURL() (string, error) {
+	if f.url != "" {
+		return f.url, nil
+	}
+	switch f.source {
+	case "api":
+		q := awc.Query{Format: "csv", IDs: splitList(f.apiIDs), BBox: f.apiBBox, Hours: f.apiHours}
+		if f.inputFormat == "json" {
+			q.Format = "json"
+		}
+		return q.URL(f.apiURL), nil
+	case "cache":
+		return awc.MetarCacheURL, nil
+	}
+	return "", fmt.Errorf("unknown -source %q; use api or cache", f.source)
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+)
+
+// iemURL is the Iowa Environmental Mesonet's ASOS archive, which has raw
+// METARs going back decades.
+const iemURL = "https://mesonet.agron.iastate.edu/cgi-bin/request/asos.py"
+
+type backfillFlags struct {
+	dbURL     string
+	driver    string
+	stations  stringsFlag
+	from      string
+	to        string
+	sourceURL string
+	chunkDays int
+	batchSize int
+	overwrite bool
+	logging   scraping.Logging
+}
+
+func (f *backfillFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.driver, "driver", "postgres", "database driver for -dburl, as for the scraper")
+	fs.Var(&f.stations, "station", "ICAO station to backfill; may be repeated or comma-separated")
+	fs.StringVar(&f.from, "from", "", "start of the range, inclusive, as 2006-01-02 or RFC 3339")
+	fs.StringVar(&f.to, "to", "", "end of the range, exclusive, as 2006-01-02 or RFC 3339")
+	fs.StringVar(&f.sourceURL, "source-url", iemURL, "url of the IEM ASOS archive service")
+	fs.IntVar(&f.chunkDays, "chunk-days", 31, "days fetched per request, and committed per transaction")
+	fs.IntVar(&f.batchSize, "batch-size", 500, "rows per INSERT statement")
+	fs.BoolVar(&f.overwrite, "overwrite", false, "if set, replace observations already stored, which are usually from the cache file and have more columns filled in")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+// iemStation returns the IEM id of an ICAO station, which drops the K from
+// contiguous US stations.
+func iemStation(icao string) string {
+	if len(icao) == 4 && icao[0] == 'K' {
+		return icao[1:]
+	}
+	return icao
+}
+
+func archiveURL(base, station string, from, to time.Time) string {
+	query := url.Values{
+		"station":     {iemStation(station)},
+		"data":        {"metar"},
+		"sts":         {from.UTC().Format("2006-01-02T15:04Z")},
+		"ets":         {to.UTC().Format("2006-01-02T15:04Z")},
+		"tz":          {"Etc/UTC"},
+		"format":      {"onlycomma"},
+		"latlon":      {"no"},
+		"missing":     {"empty"},
+		"direct":      {"yes"},
+		"report_type": {"3", "4"}, // routine and specials
+	}
+	return base + "?" + query.Encode()
+}
+
+// readArchive reads IEM's station,valid,metar rows into observations for
+// station, dropping any outside [from, to).
+func readArchive(r io.Reader, station string, from, to time.Time) ([]*metarcsv.Observation, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	index := awc.ColumnIndex(header)
+	validCol, validOK := index["valid"]
+	metarCol, metarOK := index["metar"]
+	if !validOK || !metarOK {
+		return nil, fmt.Errorf("header %q is missing valid or metar", strings.Join(header, ","))
+	}
+	var observations []*metarcsv.Observation
+	for {
+		parts, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return observations, nil
+		} else if err != nil {
+			return nil, err
+		}
+		valid, err := time.Parse("2006-01-02 15:04", parts[validCol])
+		if err != nil {
+			return nil, fmt.Errorf("bad time %q: %w", parts[validCol], err)
+		}
+		raw := strings.TrimSpace(parts[metarCol])
+		if raw == "" || valid.Before(from) || !valid.Before(to) {
+			continue
+		}
+		observations = append(observations, archiveObservation(station, valid, raw))
+	}
+}
+
+// archiveObservation builds the csv_parts of a cache file row from the raw
+// report raw, so that rows from elsewhere look like any other.  Only what the raw text carries is
+// filled in.
+func archiveObservation(station string, valid time.Time, raw string) *metarcsv.Observation {
+	parts := make([]string, len(metarcsv.Columns))
+	set := func(col string, value string) {
+		parts[metarcsv.ColumnIndex[col]] = value
+	}
+	set("raw_text", raw)
+	set("station_id", station)
+	set("observation_time", valid.Format(time.RFC3339))
+	set("metar_type", "METAR")
+	obs := &metarcsv.Observation{Station: station, ObservationTime: valid, Parts: parts}
+	decoded, err := metar.Decode(raw)
+	if err != nil {
+		return obs
+	}
+	if decoded.Type != "" {
+		// the cache file has the type in its own column only
+		set("metar_type", decoded.Type)
+		set("raw_text", strings.TrimPrefix(raw, decoded.Type+" "))
+	}
+	if decoded.Auto {
+		set("auto", "TRUE")
+	}
+	if decoded.Corrected {
+		set("corrected", "TRUE")
+	}
+	formatTenths := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+	tempC, dewpointC := decoded.TempC, decoded.DewpointC
+	if r := decoded.Remarks; r != nil && r.TempC != nil {
+		set("temp_c", formatTenths(*r.TempC))
+	} else if tempC != nil {
+		set("temp_c", formatTenths(float64(*tempC)))
+	}
+	if r := decoded.Remarks; r != nil && r.DewpointC != nil {
+		set("dewpoint_c", formatTenths(*r.DewpointC))
+	} else if dewpointC != nil {
+		set("dewpoint_c", formatTenths(float64(*dewpointC)))
+	}
+	if r := decoded.Remarks; r != nil && r.SeaLevelPressureMB != nil {
+		set("sea_level_pressure_mb", formatTenths(*r.SeaLevelPressureMB))
+	}
+	if w := decoded.Wind; w != nil {
+		if w.DirectionDegrees != nil {
+			set("wind_dir_degrees", strconv.Itoa(*w.DirectionDegrees))
+		} else {
+			set("wind_dir_degrees", "VRB")
+		}
+		set("wind_speed_kt", strconv.Itoa(toKnots(w.Speed, w.Unit)))
+		if w.Gust != nil {
+			set("wind_gust_kt", strconv.Itoa(toKnots(*w.Gust, w.Unit)))
+		}
+	}
+	if miles := decoded.Conditions.Miles(); miles != nil {
+		value := strconv.FormatFloat(math.Round(*miles*100)/100, 'f', -1, 64)
+		if decoded.Visibility.MoreThan {
+			value += "+"
+		}
+		set("visibility_statute_mi", value)
+	}
+	if p := decoded.Pressure; p != nil && p.QNHInHg != nil {
+		set("altim_in_hg", strconv.FormatFloat(*p.QNHInHg, 'f', 2, 64))
+	}
+	var wx []string
+	for _, w := range decoded.Weather {
+		wx = append(wx, w.Intensity+w.Descriptor+strings.Join(w.Phenomena, ""))
+	}
+	set("wx_string", strings.Join(wx, " "))
+	layer := 0
+	if decoded.ClearSky != "" {
+		set("sky_cover", decoded.ClearSky)
+		layer++
+	}
+	for _, c := range decoded.Clouds {
+		if c.Cover == "VV" {
+			if c.BaseFt != nil {
+				set("vert_vis_ft", strconv.Itoa(*c.BaseFt))
+			}
+			c.Cover = "OVX"
+		}
+		if layer >= 4 {
+			break
+		}
+		suffix := ""
+		if layer > 0 {
+			suffix = fmt.Sprintf("_%d", layer+1)
+		}
+		set("sky_cover"+suffix, c.Cover)
+		if c.BaseFt != nil {
+			set("cloud_base_ft_agl"+suffix, strconv.Itoa(*c.BaseFt))
+		}
+		layer++
+	}
+	return obs
+}
+
+func toKnots(speed int, unit string) int {
+	switch unit {
+	case "MPS":
+		return int(math.Round(float64(speed) * 1.943844))
+	case "KMH":
+		return int(math.Round(float64(speed) / 1.852))
+	}
+	return speed
+}
+
+// storedTimes returns the observation times already stored for station in
+// [from, to).
+func storedTimes(ctx context.Context, db *sql.DB, d *store.Dialect, station string, from, to time.Time) (map[time.Time]bool, error) {
+	rows, err := d.Builder.Select("observation_time").From("metars").
+		Where(sq.Eq{"station": station}).
+		Where(sq.GtOrEq{"observation_time": from}).
+		Where(sq.Lt{"observation_time": to}).
+		RunWith(db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stored := map[time.Time]bool{}
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		stored[t.UTC()] = true
+	}
+	return stored, rows.Err()
+}
+
+func runBackfill(ctx context.Context, flags *backfillFlags) error {
+	from, err := parseTimeFlag(flags.from)
+	if err != nil {
+		return fmt.Errorf("bad -from: %w", err)
+	}
+	to, err := parseTimeFlag(flags.to)
+	if err != nil {
+		return fmt.Errorf("bad -to: %w", err)
+	}
+	var stations []string
+	for _, s := range flags.stations {
+		stations = append(stations, strings.Split(s, ",")...)
+	}
+	if len(stations) == 0 {
+		return errors.New("no -station given")
+	}
+	db, d, err := store.Open(flags.dbURL, flags.driver)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	opts := &ingestOptions{rows: store.Options{BatchSize: flags.batchSize}}
+	if d == store.ClickHouse {
+		return errors.New("backfill isn't supported for clickhouse")
+	}
+	if err := store.CreateSchema(ctx, db, d, opts.rows.ConflictKey()); err != nil {
+		return err
+	}
+	b := &backfiller{
+		downloader: &awc.Downloader{MaxAttempts: 3, Backoff: 5 * time.Second, MaxBackoff: time.Minute, Timeout: 5 * time.Minute, HeaderTimeout: 30 * time.Second},
+		out:        store.New(db, d, &opts.rows),
+		db:         db,
+		dialect:    d,
+		opts:       opts,
+		overwrite:  flags.overwrite,
+	}
+	chunk := time.Duration(flags.chunkDays) * 24 * time.Hour
+	for _, station := range stations {
+		for start := from; start.Before(to); start = start.Add(chunk) {
+			end := start.Add(chunk)
+			if end.After(to) {
+				end = to
+			}
+			written, fetched, err := b.chunk(ctx, flags.sourceURL, station, start, end)
+			if err != nil {
+				return fmt.Errorf("%s from %v: %w", station, start.Format(time.RFC3339), err)
+			}
+			slog.Info("backfilled", "station", station, "start", start, "end", end, "fetched", fetched, "written", written)
+		}
+	}
+	return nil
+}
+
+// backfiller writes observations fetched from somewhere other than the
+// cache file.
+type backfiller struct {
+	downloader *awc.Downloader
+	out        store.Store
+	db         *sql.DB
+	dialect    *store.Dialect
+	opts       *ingestOptions
+	// overwrite replaces stored observations rather than skipping them.
+	overwrite bool
+}
+
+// chunk backfills station over [from, to) from the archive at sourceURL, in
+// one transaction.
+func (b *backfiller) chunk(ctx context.Context, sourceURL, station string, from, to time.Time) (written, fetched int, err error) {
+	body, err := b.downloader.Open(ctx, archiveURL(sourceURL, station, from, to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("fetching: %w", err)
+	}
+	defer body.Close()
+	observations, err := readArchive(body, station, from, to)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading: %w", err)
+	}
+	written, err = b.store(ctx, station, from, to, observations)
+	return written, len(observations), err
+}
+
+// store writes observations of station in [from, to) in one transaction,
+// and returns how many were written.
+func (b *backfiller) store(ctx context.Context, station string, from, to time.Time, observations []*metarcsv.Observation) (int, error) {
+	stored := map[time.Time]bool{}
+	if !b.overwrite {
+		var err error
+		if stored, err = storedTimes(ctx, b.db, b.dialect, station, from, to); err != nil {
+			return 0, fmt.Errorf("finding stored observations: %w", err)
+		}
+	}
+	if err := b.out.Begin(ctx); err != nil {
+		return 0, err
+	}
+	defer b.out.Rollback()
+	written := 0
+	for _, obs := range observations {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if stored[obs.ObservationTime] {
+			continue
+		}
+		// archives can have a report twice
+		stored[obs.ObservationTime] = true
+		if err := b.out.Write(obs); err != nil {
+			return 0, fmt.Errorf("writing %v: %w", obs.ObservationTime, err)
+		}
+		written++
+	}
+	if err := b.out.Commit(); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
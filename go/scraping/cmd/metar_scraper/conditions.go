@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	sq "github.com/Masterminds/squirrel"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// conditionGauges are the columns served as metar_<name>{station="..."}
+// gauges by /metrics.
+var conditionGauges = []struct {
+	name, help string
+	index      int
+}{
+	{"temp_c", "Air temperature in degrees Celsius.", metarcsv.ColTempC},
+	{"dewpoint_c", "Dewpoint in degrees Celsius.", metarcsv.ColDewpointC},
+	{"wind_dir_degrees", "Wind direction in degrees true.", metarcsv.ColWindDirDegrees},
+	{"wind_speed_kt", "Wind speed in knots.", metarcsv.ColWindSpeedKt},
+	{"wind_gust_kt", "Wind gust in knots; absent without gusts.", metarcsv.ColWindGustKt},
+	{"visibility_statute_mi", "Visibility in statute miles.", metarcsv.ColVisibilityMi},
+	{"altim_in_hg", "Altimeter setting in inches of mercury.", metarcsv.ColAltimInHg},
+}
+
+// flightCategories are the values of metar_flight_category's category label.
+var flightCategories = []string{"VFR", "MVFR", "IFR", "LIFR"}
+
+// writeConditions writes the current conditions at each station in
+// Prometheus's text format.  The flight category is one series per category,
+// set to 1 for the current one, so it can be alerted on by label.
+func writeConditions(w io.Writer, observations []*metarcsv.Observation) {
+	header := func(name, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	for _, g := range conditionGauges {
+		header("metar_"+g.name, g.help)
+		for _, obs := range observations {
+			if value, ok := obs.Number(g.index); ok {
+				fmt.Fprintf(w, "metar_%s{station=%q} %s\n", g.name, obs.Station, strconv.FormatFloat(value, 'f', -1, 64))
+			}
+		}
+	}
+	header("metar_flight_category", "1 for the station's current flight category, 0 for the others.")
+	for _, obs := range observations {
+		current := obs.Field(metarcsv.ColumnIndex["flight_category"])
+		if current == "" {
+			continue
+		}
+		for _, category := range flightCategories {
+			value := 0
+			if category == current {
+				value = 1
+			}
+			fmt.Fprintf(w, "metar_flight_category{station=%q,category=%q} %d\n", obs.Station, category, value)
+		}
+	}
+	header("metar_observation_timestamp_seconds", "When the station's latest observation was made.")
+	for _, obs := range observations {
+		fmt.Fprintf(w, "metar_observation_timestamp_seconds{station=%q} %d\n", obs.Station, obs.ObservationTime.Unix())
+	}
+}
+
+// handleMetrics serves the current conditions at s.metricsStations from
+// metars_latest.
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.dialect.Builder.Select("station", "observation_time", "csv_parts").
+		From("metars_latest").
+		Where(sq.Eq{"station": s.metricsStations}).
+		OrderBy("station").
+		RunWith(s.db).QueryContext(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer rows.Close()
+	var observations []*metarcsv.Observation
+	for rows.Next() {
+		obs := &metarcsv.Observation{}
+		if err := rows.Scan(&obs.Station, &obs.ObservationTime, s.dialect.ScanArray(&obs.Parts)); err != nil {
+			serverError(w, err)
+			return
+		}
+		observations = append(observations, obs)
+	}
+	if err := rows.Err(); err != nil {
+		serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeConditions(w, observations)
+}
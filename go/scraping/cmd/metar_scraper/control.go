@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// daemonControl lets an operator pause the daemon, say during database
+// maintenance, without killing it.  A paused daemon keeps its schedule but
+// skips each cycle until it's resumed.
+type daemonControl struct {
+	mu     sync.Mutex
+	paused bool
+	// since is when the daemon was last paused or resumed.
+	since time.Time
+}
+
+// register serves the control commands and health on mux:
+//
+//	POST /control/pause   stop scraping from the next cycle
+//	POST /control/resume  scrape again from the next cycle
+//	GET /control/status   "paused" or "running", and since when
+//	GET /healthz          "ok", or "paused" while paused
+//
+// A cycle already running when the daemon is paused finishes.
+func (c *daemonControl) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /control/pause", func(w http.ResponseWriter, r *http.Request) {
+		c.set(true)
+		c.writeStatus(w)
+	})
+	mux.HandleFunc("POST /control/resume", func(w http.ResponseWriter, r *http.Request) {
+		c.set(false)
+		c.writeStatus(w)
+	})
+	mux.HandleFunc("GET /control/status", func(w http.ResponseWriter, r *http.Request) {
+		c.writeStatus(w)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		// paused on purpose isn't unhealthy, so it's still a 200
+		if c.isPaused() {
+			fmt.Fprintln(w, "paused")
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+func (c *daemonControl) set(paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused == paused {
+		return
+	}
+	c.paused = paused
+	c.since = time.Now()
+	if paused {
+		slog.Info("paused")
+	} else {
+		slog.Info("resumed")
+	}
+}
+
+func (c *daemonControl) isPaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+func (c *daemonControl) writeStatus(w http.ResponseWriter) {
+	c.mu.Lock()
+	paused, since := c.paused, c.since
+	c.mu.Unlock()
+	status := "running"
+	if paused {
+		status = "paused"
+	}
+	if since.IsZero() {
+		fmt.Fprintln(w, status)
+		return
+	}
+	fmt.Fprintf(w, "%s since %s\n", status, since.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDaemonControl(t *testing.T) {
+	c := &daemonControl{}
+	mux := http.NewServeMux()
+	c.register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	request := func(method, path string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, strings.TrimSpace(string(body))
+	}
+
+	for _, step := range []struct {
+		method, path string
+		// status is /control/status's first word afterwards, and health
+		// /healthz's body
+		status, health string
+		paused         bool
+	}{
+		{"GET", "/control/status", "running", "ok", false},
+		{"POST", "/control/pause", "paused", "paused", true},
+		// pausing twice is harmless
+		{"POST", "/control/pause", "paused", "paused", true},
+		{"POST", "/control/resume", "running", "ok", false},
+		{"POST", "/control/resume", "running", "ok", false},
+		{"POST", "/control/pause", "paused", "paused", true},
+	} {
+		code, body := request(step.method, step.path)
+		if code != http.StatusOK || !strings.HasPrefix(body, step.status) {
+			t.Errorf("%s %s: %d %q, want %q", step.method, step.path, code, body, step.status)
+		}
+		if _, status := request("GET", "/control/status"); !strings.HasPrefix(status, step.status) {
+			t.Errorf("after %s %s: status %q, want %q", step.method, step.path, status, step.status)
+		}
+		if code, health := request("GET", "/healthz"); code != http.StatusOK || health != step.health {
+			t.Errorf("after %s %s: health %d %q, want %q", step.method, step.path, code, health, step.health)
+		}
+		if c.isPaused() != step.paused {
+			t.Errorf("after %s %s: paused %v, want %v", step.method, step.path, c.isPaused(), step.paused)
+		}
+	}
+
+	// the commands change state, so they aren't GETs
+	if code, _ := request("GET", "/control/pause"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /control/pause: %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+}
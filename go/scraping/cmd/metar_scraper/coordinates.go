@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log/slog"
+
+	"mattdee123.com/aviationweather/calc"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// defaultCoordinateThresholdNM is how far an observation can be from its
+// station's coordinates before it's a mismatch.  The stations table and the
+// metars feed round positions differently, so a few miles apart is normal.
+const defaultCoordinateThresholdNM = 10
+
+// coordinateCheck compares each observation's position with its station's
+// in the stations table.  One far off was probably reported under the wrong
+// station upstream.  Like a missing expected field, a mismatch is logged and
+// counted but the row is still written.  Stations missing from the table,
+// and observations without a position, aren't checked.
+type coordinateCheck struct {
+	stations    map[string]*store.Station
+	thresholdNM float64
+	// mismatches is the number of observations farther than thresholdNM
+	// from their station.
+	mismatches int
+}
+
+func (c *coordinateCheck) check(obs *metarcsv.Observation) {
+	station := c.stations[obs.Station]
+	if station == nil || station.Latitude == nil || station.Longitude == nil {
+		return
+	}
+	lat, latOK := obs.Number(metarcsv.ColLatitude)
+	lon, lonOK := obs.Number(metarcsv.ColLongitude)
+	if !latOK || !lonOK {
+		return
+	}
+	if distance := calc.DistanceNM(lat, lon, *station.Latitude, *station.Longitude); distance > c.thresholdNM {
+		c.mismatches++
+		slog.Warn("observation far from its station", "station", obs.Station, "observation_time", obs.ObservationTime,
+			"distance_nm", int(distance), "latitude", lat, "longitude", lon)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// stationsDB returns testDB with a stations table, as sql/012.sql creates
+// for postgres, holding KBOS and a KJFK with no position.
+func stationsDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testDB(t)
+	for _, stmt := range []string{
+		"CREATE TABLE stations (station text primary key, site text, latitude double precision, longitude double precision, elevation_m double precision, state text, country text, site_type text, csv_parts text)",
+		"INSERT INTO stations (station, site, latitude, longitude, elevation_m, state, country) VALUES ('KBOS', 'Boston/Logan Intl', 42.36, -71.01, 6, 'MA', 'US')",
+		"INSERT INTO stations (station, site, country) VALUES ('KJFK', 'New York/JF Kennedy Intl', 'US')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// positioned returns an observation of station at lat, lon.
+func positioned(t *testing.T, station, lat, lon string) *metarcsv.Observation {
+	t.Helper()
+	return testObservation(t, station, time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC), station+" 141154Z 27010KT 10SM FEW050 12/05 A2992",
+		map[int]string{metarcsv.ColLatitude: lat, metarcsv.ColLongitude: lon})
+}
+
+func TestCoordinateCheck(t *testing.T) {
+	db := stationsDB(t)
+	stations, err := store.LoadStations(context.Background(), db, store.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name        string
+		obs         *metarcsv.Observation
+		thresholdNM float64
+		mismatch    bool
+	}{
+		{"at the station", positioned(t, "KBOS", "42.36", "-71.01"), defaultCoordinateThresholdNM, false},
+		{"rounded differently", positioned(t, "KBOS", "42.3", "-71.0"), defaultCoordinateThresholdNM, false},
+		// JFK's position, about 160 nm away
+		{"misattributed", positioned(t, "KBOS", "40.64", "-73.78"), defaultCoordinateThresholdNM, true},
+		{"within a larger threshold", positioned(t, "KBOS", "40.64", "-73.78"), 200, false},
+		{"just past a smaller threshold", positioned(t, "KBOS", "42.3", "-71.0"), 3, true},
+		{"no position reported", positioned(t, "KBOS", "", ""), defaultCoordinateThresholdNM, false},
+		{"station has no position", positioned(t, "KJFK", "42.36", "-71.01"), defaultCoordinateThresholdNM, false},
+		{"station not in the table", positioned(t, "KXXX", "0", "0"), defaultCoordinateThresholdNM, false},
+	} {
+		c := &coordinateCheck{stations: stations, thresholdNM: test.thresholdNM}
+		c.check(test.obs)
+		if got := c.mismatches == 1; got != test.mismatch {
+			t.Errorf("%s: mismatch %v, want %v", test.name, got, test.mismatch)
+		}
+	}
+}
+
+func TestReadToDBCoordinateMismatches(t *testing.T) {
+	db := stationsDB(t)
+	stations, err := store.LoadStations(context.Background(), db, store.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := testFile(
+		positioned(t, "KBOS", "42.36", "-71.01"),
+		positioned(t, "KBOS", "40.64", "-73.78"),
+		positioned(t, "KJFK", "42.36", "-71.01"),
+	)
+	opts := &ingestOptions{inputFormat: "csv", coordinates: &coordinateCheck{stations: stations, thresholdNM: defaultCoordinateThresholdNM}}
+	stats := &runStats{}
+	if err := readToDB(context.Background(), store.New(db, store.SQLite, nil), strings.NewReader(file), opts, stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.coordinateMismatches != 1 {
+		t.Errorf("%d mismatches, want 1", stats.coordinateMismatches)
+	}
+	// a mismatch is only reported
+	if stats.rowsWritten != 3 {
+		t.Errorf("wrote %d, want 3", stats.rowsWritten)
+	}
+	args := stats.logArgs()
+	i := slices.Index(args, any("coordinate_mismatches"))
+	if i < 0 || args[i+1] != 1 {
+		t.Errorf("summary %v has no coordinate_mismatches=1", args)
+	}
+}
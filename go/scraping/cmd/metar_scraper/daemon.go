@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"mattdee123.com/aviationweather/scraping"
+)
+
+// runDaemon scrapes every flags.interval, or when flags.schedule is due,
+// plus up to flags.jitter, until ctx is cancelled.  A failed scrape is
+// logged and retried on the next cycle rather than ending the daemon; only
+// bad settings and shutdown do.  With flags.metricsListen, the daemon can
+// also be paused and resumed there; see daemonControl.
+func runDaemon(ctx context.Context, flags *Flags) error {
+	var schedule *scraping.Schedule
+	if flags.schedule != "" {
+		var err error
+		if schedule, err = scraping.ParseSchedule(flags.schedule); err != nil {
+			return fmt.Errorf("bad -schedule: %w", err)
+		}
+		slog.Info("running on schedule", "schedule", schedule.String(), "jitter", flags.jitter)
+	} else {
+		slog.Info("running on interval", "interval", flags.interval, "jitter", flags.jitter)
+	}
+	var last *runStats
+	var mu sync.Mutex
+	control := &daemonControl{}
+	if flags.metricsListen != "" {
+		mux := http.NewServeMux()
+		control.register(mux)
+		mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			stats := last
+			mu.Unlock()
+			if stats == nil {
+				http.Error(w, "no run has finished yet", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			writeMetrics(w, stats)
+		})
+		listener, err := net.Listen("tcp", flags.metricsListen)
+		if err != nil {
+			return fmt.Errorf("serving metrics: %w", err)
+		}
+		srv := &http.Server{Handler: mux}
+		go func() {
+			if err := srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("serving metrics", "err", err)
+			}
+		}()
+		defer srv.Close()
+		slog.Info("serving metrics", "addr", flags.metricsListen)
+	}
+	for cycle := 1; ; cycle++ {
+		start := time.Now()
+		if control.isPaused() {
+			slog.Info("paused: skipping cycle", "cycle", cycle)
+		} else {
+			stats, _, err := runOnce(ctx, flags, last)
+			mu.Lock()
+			last = stats
+			mu.Unlock()
+			elapsed := stats.end.Sub(stats.start).Round(time.Millisecond)
+			switch {
+			case errors.Is(err, context.Canceled):
+				return err
+			case err != nil:
+				slog.Error("cycle failed", "cycle", cycle, "duration", elapsed, "err", err)
+				if flags.download && flags.deleteFile && flags.filename != "" {
+					// it would block the next cycle's download
+					os.Remove(flags.filename)
+				}
+			}
+		}
+		wait := flags.interval - time.Since(start)
+		if schedule != nil {
+			next := schedule.Next(time.Now())
+			if next.IsZero() {
+				return fmt.Errorf("-schedule %v never matches", schedule)
+			}
+			wait = time.Until(next)
+		}
+		if flags.jitter > 0 {
+			wait += rand.N(flags.jitter)
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("shutting down", "cycles", cycle)
+			return nil
+		case <-time.After(max(wait, 0)):
+		}
+	}
+}
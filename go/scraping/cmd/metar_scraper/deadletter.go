@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"mattdee123.com/aviationweather/store"
+)
+
+// deadLetter records lines that fail to parse or insert in metar_errors.
+// They're held until the ingest transaction is over and then written
+// straight to db, so they're kept even when the run is rolled back, and
+// SQLite's single writer isn't blocked by the transaction.
+type deadLetter struct {
+	db      *sql.DB
+	dialect *store.Dialect
+	// source is where the lines came from, the url or filename.
+	source  string
+	pending []metarError
+}
+
+type metarError struct {
+	recordedAt time.Time
+	lineNumber int
+	stage      string
+	line       string
+	err        string
+}
+
+func (d *deadLetter) record(lineNumber int, stage, line string, err error) {
+	d.pending = append(d.pending, metarError{time.Now().UTC(), lineNumber, stage, line, err.Error()})
+}
+
+// flush writes the recorded lines.  Failures are logged rather than
+// returned, since every line is in the log already.
+func (d *deadLetter) flush(ctx context.Context) {
+	if len(d.pending) == 0 {
+		return
+	}
+	insert := d.dialect.Builder.Insert("metar_errors").
+		Columns("recorded_at", "source", "line_number", "stage", "line", "error")
+	for _, e := range d.pending {
+		insert = insert.Values(e.recordedAt, d.source, e.lineNumber, e.stage, e.line, e.err)
+	}
+	// still recorded if the run was interrupted
+	if _, err := insert.RunWith(d.db).ExecContext(context.WithoutCancel(ctx)); err != nil {
+		slog.Warn("recording lines in metar_errors", "lines", len(d.pending), "err", err)
+		return
+	}
+	slog.Info("recorded lines in metar_errors", "lines", len(d.pending))
+	d.pending = nil
+}
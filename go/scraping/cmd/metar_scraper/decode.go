@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/taf"
+	"mattdee123.com/aviationweather/units"
+)
+
+type decodeFlags struct {
+	json       bool
+	units      units.System
+	strictness metar.Strictness
+	reports    []string
+	logging    scraping.Logging
+}
+
+func (f *decodeFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	fs.BoolVar(&f.json, "json", false, "if set, print the decoded reports as JSON instead")
+	unitsFlag(fs, &f.units)
+	strictnessFlag(fs, &f.strictness, "how to decode a METAR: lenient lists unrecognized groups as not understood, best-effort first tries correcting likely typos, and strict fails on any")
+	f.logging.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: metar_scraper decode [flags] [REPORT]\n"+
+			"decodes the METAR or TAF given as arguments, or else one per line of stdin\n")
+		fs.PrintDefaults()
+	}
+	scraping.ParseFlags(fs, args)
+	if fs.NArg() > 0 {
+		f.reports = []string{strings.Join(fs.Args(), " ")}
+	}
+}
+
+// strictnessFlag adds the -parse-strictness flag, defaulting *s to
+// metar.Lenient.
+func strictnessFlag(fs *flag.FlagSet, s *metar.Strictness, usage string) {
+	*s = metar.Lenient
+	fs.Func("parse-strictness", usage+" (default lenient)", func(value string) error {
+		var err error
+		*s, err = metar.ParseStrictness(value)
+		return err
+	})
+}
+
+// unitsFlag adds the -units flag, leaving *u "" unless it's set.
+func unitsFlag(fs *flag.FlagSet, u *units.System) {
+	fs.Func("units", "metric, imperial or aviation: the `units` to print values in; as reported if unset", func(value string) error {
+		var err error
+		*u, err = units.ParseSystem(value)
+		return err
+	})
+}
+
+func runDecode(ctx context.Context, flags *decodeFlags) error {
+	reports := flags.reports
+	if len(reports) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				reports = append(reports, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("reading stdin: %w", err)
+		}
+	}
+	var errs []error
+	for i, raw := range reports {
+		decoded, err := decodeReport(raw, flags.strictness)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: %w", raw, err))
+			continue
+		}
+		if flags.json {
+			data, err := json.MarshalIndent(decoded, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", data)
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(raw)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		switch d := decoded.(type) {
+		case *metar.METAR:
+			describeMETAR(w, d, flags.units)
+		case *taf.TAF:
+			describeTAF(w, d, flags.units)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// decodeReport decodes raw as a TAF if it says it is one or only decodes as
+// one, and as a METAR with strictness otherwise.
+func decodeReport(raw string, strictness metar.Strictness) (any, error) {
+	if strings.HasPrefix(raw, "TAF ") {
+		return taf.Decode(raw)
+	}
+	m, err := metar.DecodeWith(raw, strictness)
+	if err == nil {
+		return m, nil
+	}
+	if t, tafErr := taf.Decode(raw); tafErr == nil {
+		return t, nil
+	}
+	return nil, err
+}
+
+// describer writes a line per decoded field of a report to a tabwriter,
+// converting values to units, or leaving them as reported if it's "".
+type describer struct {
+	w      io.Writer
+	units  units.System
+	indent string
+}
+
+func (d *describer) line(label, value string) {
+	if value != "" {
+		fmt.Fprintf(d.w, "  %s%s\t%s\n", d.indent, label, value)
+	}
+}
+
+// describeMETAR writes a line per decoded field of m, for a tabwriter.
+func describeMETAR(w io.Writer, m *metar.METAR, u units.System) {
+	d := &describer{w: w, units: u}
+	var kind []string
+	if m.Type != "" {
+		kind = append(kind, m.Type)
+	}
+	if m.Auto {
+		kind = append(kind, "automated")
+	}
+	if m.Corrected {
+		kind = append(kind, "corrected")
+	}
+	d.line("report", strings.Join(kind, ", "))
+	d.line("station", m.Station)
+	d.line("observed", fmt.Sprintf("day %d at %02d%02dZ", m.Day, m.Hour, m.Minute))
+	d.conditions(&m.Conditions)
+	var rvr []string
+	for _, r := range m.RVR {
+		rvr = append(rvr, d.rvr(r))
+	}
+	d.line("runway range", strings.Join(rvr, "; "))
+	if m.TempC != nil {
+		d.line("temperature", d.temperature(float64(*m.TempC), 0))
+	}
+	if m.DewpointC != nil {
+		d.line("dewpoint", d.temperature(float64(*m.DewpointC), 0))
+	}
+	if p := m.Pressure; p != nil {
+		if p.QNHInHg != nil && p.QNHHPa != nil {
+			altimeter := fmt.Sprintf("%.2f inHg (%g hPa)", *p.QNHInHg, *p.QNHHPa)
+			if d.units != "" {
+				altimeter = d.units.Pressure(*p.QNHInHg).String()
+			}
+			d.line("altimeter", altimeter)
+		}
+		if p.QFEHPa != nil {
+			d.line("station pressure", d.hPa(*p.QFEHPa))
+		}
+	}
+	if s := m.Supplement; s != nil {
+		d.line("wind shear", strings.Join(s.WindShearRunways, ", "))
+		if s.SeaSurfaceTempC != nil {
+			d.line("sea temperature", d.temperature(float64(*s.SeaSurfaceTempC), 0))
+		}
+		if s.SeaState != nil {
+			d.line("sea state", strconv.Itoa(*s.SeaState))
+		}
+		if s.WaveHeightDM != nil {
+			d.line("wave height", fmt.Sprintf("%.1f m", float64(*s.WaveHeightDM)/10))
+		}
+	}
+	d.line("category", m.Conditions.FlightCategory())
+	if r := m.Remarks; r != nil {
+		d.remarks(r)
+	}
+	d.line("not understood", strings.Join(m.Unparsed, " "))
+	d.line("corrected", strings.Join(m.Guessed, " "))
+}
+
+// describeTAF writes t's validity and then each of its periods.
+func describeTAF(w io.Writer, t *taf.TAF, u units.System) {
+	d := &describer{w: w, units: u}
+	var kind []string
+	if t.Amended {
+		kind = append(kind, "amended")
+	}
+	if t.Corrected {
+		kind = append(kind, "corrected")
+	}
+	d.line("report", strings.Join(append([]string{"TAF"}, kind...), ", "))
+	d.line("station", t.Station)
+	d.line("issued", tafTime(t.Issued))
+	d.line("valid", tafTime(t.ValidFrom)+" to "+tafTime(t.ValidTo))
+	for _, p := range t.Periods {
+		title := p.Kind
+		switch {
+		case p.Kind == taf.KindBase:
+			title = "base forecast"
+		case p.Kind == taf.KindPROB:
+			title = fmt.Sprintf("PROB%d", p.Probability)
+		case p.Probability > 0:
+			title = fmt.Sprintf("PROB%d %s", p.Probability, p.Kind)
+		}
+		fmt.Fprintf(w, "  %s, %s to %s\n", title, tafTime(p.From), tafTime(p.To))
+		d.indent = "  "
+		d.conditions(&p.Conditions)
+		d.line("category", p.Conditions.FlightCategory())
+		d.line("not understood", strings.Join(p.Unparsed, " "))
+		d.indent = ""
+	}
+	d.line("remarks", t.Remarks)
+}
+
+func tafTime(t taf.Time) string {
+	return fmt.Sprintf("day %d at %02d%02dZ", t.Day, t.Hour, t.Minute)
+}
+
+func (d *describer) conditions(c *metar.Conditions) {
+	if c.Wind != nil {
+		d.line("wind", d.wind(c.Wind))
+	}
+	if c.Visibility != nil {
+		d.line("visibility", d.visibility(c.Visibility))
+	}
+	var weather []string
+	for _, wx := range c.Weather {
+		weather = append(weather, weatherText(wx))
+	}
+	d.line("weather", strings.Join(weather, "; "))
+	var clouds []string
+	for _, layer := range c.Clouds {
+		clouds = append(clouds, d.cloud(layer))
+	}
+	if c.ClearSky != "" {
+		clouds = append(clouds, clearSkyNames[c.ClearSky])
+	}
+	d.line("sky", strings.Join(clouds, "; "))
+	if ceiling := c.CeilingFt(); ceiling != nil {
+		d.line("ceiling", d.height(float64(*ceiling)))
+	}
+}
+
+func (d *describer) remarks(r *metar.Remarks) {
+	if r.SeaLevelPressureMB != nil {
+		d.line("sea level pressure", d.hPa(*r.SeaLevelPressureMB))
+	}
+	if r.TempC != nil {
+		text := d.temperature(*r.TempC, 1)
+		if r.DewpointC != nil {
+			text += ", dewpoint " + d.temperature(*r.DewpointC, 1)
+		}
+		d.line("precise temperature", text)
+	}
+	if pk := r.PeakWind; pk != nil {
+		at := fmt.Sprintf(":%02d", pk.Minute)
+		if pk.Hour != nil {
+			at = fmt.Sprintf("%02d%02dZ", *pk.Hour, pk.Minute)
+		}
+		d.line("peak wind", fmt.Sprintf("%03d° at %s, at %s", pk.DirectionDegrees, d.speed(pk.SpeedKt, "KT"), at))
+	}
+	if pt := r.PressureTendency; pt != nil {
+		d.line("pressure tendency", fmt.Sprintf("%+.1f hPa over 3 hours (code %d)", pt.ChangeHPa, pt.Code))
+	}
+	if r.PressureRisingRapidly {
+		d.line("pressure", "rising rapidly")
+	}
+	if r.PressureFallingRapidly {
+		d.line("pressure", "falling rapidly")
+	}
+	if r.Precip1HourIn != nil {
+		d.line("precipitation, 1 hour", fmt.Sprintf("%.2f in", *r.Precip1HourIn))
+	}
+	if r.Precip6HourIn != nil {
+		d.line("precipitation, 6 hours", fmt.Sprintf("%.2f in", *r.Precip6HourIn))
+	} else if r.Precip6HourIndeterminate {
+		d.line("precipitation, 6 hours", "indeterminate")
+	}
+	if r.Precip24HourIn != nil {
+		d.line("precipitation, 24 hours", fmt.Sprintf("%.2f in", *r.Precip24HourIn))
+	}
+	if r.TowerVisibilityMi != nil {
+		d.line("tower visibility", d.miles(*r.TowerVisibilityMi))
+	}
+	d.line("sensors out", strings.Join(r.SensorStatus, ", "))
+	if r.MaintenanceNeeded {
+		d.line("maintenance", "needed")
+	}
+	d.line("other remarks", strings.Join(r.Other, " "))
+}
+
+// speed formats a speed reported in unit, KT, MPS or KMH.
+func (d *describer) speed(speed int, unit string) string {
+	if d.units == "" {
+		switch unit {
+		case "MPS":
+			return fmt.Sprintf("%d m/s", speed)
+		case "KMH":
+			return fmt.Sprintf("%d km/h", speed)
+		}
+		return fmt.Sprintf("%d kt", speed)
+	}
+	kt := float64(speed)
+	switch unit {
+	case "MPS":
+		kt = units.MPSToKnots(kt)
+	case "KMH":
+		kt = units.KMHToKnots(kt)
+	}
+	return d.units.Speed(kt).String()
+}
+
+// temperature formats a temperature reported to places decimal places.
+func (d *describer) temperature(c float64, places int) string {
+	if d.units == "" {
+		return fmt.Sprintf("%.*f°C", places, c)
+	}
+	return d.units.Temperature(c).String()
+}
+
+func (d *describer) hPa(hPa float64) string {
+	if d.units == "" {
+		return fmt.Sprintf("%g hPa", hPa)
+	}
+	return d.units.Pressure(units.HPaToInHg(hPa)).String()
+}
+
+func (d *describer) height(ft float64) string {
+	if d.units == "" {
+		return fmt.Sprintf("%g ft", ft)
+	}
+	return d.units.Height(ft).String()
+}
+
+func (d *describer) miles(mi float64) string {
+	if d.units == "" {
+		return fmt.Sprintf("%g SM", mi)
+	}
+	return d.units.Visibility(mi).String()
+}
+
+func (d *describer) wind(wind *metar.Wind) string {
+	if wind.Speed == 0 && wind.Gust == nil {
+		return "calm"
+	}
+	text := "variable"
+	if wind.DirectionDegrees != nil {
+		text = fmt.Sprintf("%03d°", *wind.DirectionDegrees)
+	}
+	text += " at " + d.speed(wind.Speed, wind.Unit)
+	if wind.Gust != nil {
+		text += ", gusting " + d.speed(*wind.Gust, wind.Unit)
+	}
+	if wind.VariableFrom != nil && wind.VariableTo != nil {
+		text += fmt.Sprintf(", varying %03d° to %03d°", *wind.VariableFrom, *wind.VariableTo)
+	}
+	return text
+}
+
+func (d *describer) visibility(v *metar.Visibility) string {
+	var text string
+	switch {
+	case v.StatuteMiles != nil:
+		text = d.miles(*v.StatuteMiles)
+	case v.Meters != nil && *v.Meters == 9999 && (d.units == "" || d.units == units.Metric):
+		return "10 km or more"
+	case v.Meters != nil && *v.Meters == 9999:
+		return "more than " + d.units.Visibility(units.MetersToMiles(10000)).String()
+	case v.Meters != nil && d.units == "":
+		text = fmt.Sprintf("%d m", *v.Meters)
+	case v.Meters != nil:
+		text = d.units.Visibility(units.MetersToMiles(float64(*v.Meters))).String()
+	}
+	switch {
+	case v.LessThan:
+		text = "less than " + text
+	case v.MoreThan:
+		text = "more than " + text
+	}
+	return text
+}
+
+var (
+	intensityNames  = map[string]string{"-": "light", "+": "heavy", "VC": "in the vicinity:"}
+	descriptorNames = map[string]string{
+		"MI": "shallow", "PR": "partial", "BC": "patches of", "DR": "low drifting",
+		"BL": "blowing", "SH": "showers of", "TS": "thunderstorm with", "FZ": "freezing",
+	}
+	phenomenonNames = map[string]string{
+		"DZ": "drizzle", "RA": "rain", "SN": "snow", "SG": "snow grains", "IC": "ice crystals",
+		"PL": "ice pellets", "GR": "hail", "GS": "small hail", "UP": "unknown precipitation",
+		"BR": "mist", "FG": "fog", "FU": "smoke", "VA": "volcanic ash", "DU": "dust",
+		"SA": "sand", "HZ": "haze", "PY": "spray", "PO": "dust whirls", "SQ": "squalls",
+		"FC": "funnel cloud", "SS": "sandstorm", "DS": "duststorm", "NSW": "no significant weather",
+	}
+	coverNames = map[string]string{
+		"FEW": "few", "SCT": "scattered", "BKN": "broken", "OVC": "overcast", "VV": "vertical visibility",
+	}
+	clearSkyNames = map[string]string{
+		metar.SkyCLR:   "clear below 12,000 ft",
+		metar.SkySKC:   "sky clear",
+		metar.SkyNSC:   "no significant cloud",
+		metar.SkyNCD:   "no cloud detected",
+		metar.SkyCAVOK: "ceiling and visibility OK",
+	}
+)
+
+func weatherText(wx metar.Weather) string {
+	var words []string
+	if wx.Intensity != "" {
+		words = append(words, intensityNames[wx.Intensity])
+	}
+	if wx.Descriptor != "" {
+		words = append(words, descriptorNames[wx.Descriptor])
+	}
+	var phenomena []string
+	for _, p := range wx.Phenomena {
+		phenomena = append(phenomena, phenomenonNames[p])
+	}
+	if len(phenomena) == 0 && wx.Descriptor == "TS" {
+		return strings.TrimSuffix(strings.Join(words, " "), " with")
+	}
+	return strings.Join(append(words, strings.Join(phenomena, " and ")), " ")
+}
+
+func (d *describer) cloud(layer metar.CloudLayer) string {
+	text := coverNames[layer.Cover]
+	if layer.BaseFt != nil {
+		text += " at " + d.height(float64(*layer.BaseFt))
+	} else {
+		text += " at unknown height"
+	}
+	switch layer.Type {
+	case "CB":
+		text += " (cumulonimbus)"
+	case "TCU":
+		text += " (towering cumulus)"
+	}
+	return text
+}
+
+func (d *describer) rvr(r metar.RVR) string {
+	distance := func(n int) string {
+		if d.units == "" {
+			return fmt.Sprintf("%d %s", n, strings.ToLower(r.Unit))
+		}
+		ft := float64(n)
+		if r.Unit == "M" {
+			ft = units.MetersToFeet(ft)
+		}
+		return d.units.Height(ft).String()
+	}
+	text := "runway " + r.Runway + " "
+	switch {
+	case r.LessThan:
+		text += "less than "
+	case r.MoreThan:
+		text += "more than "
+	}
+	text += distance(r.Low)
+	if r.High != nil {
+		text += " varying to " + distance(*r.High)
+	}
+	switch r.Trend {
+	case "U":
+		text += ", rising"
+	case "D":
+		text += ", falling"
+	case "N":
+		text += ", steady"
+	}
+	return text
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+)
+
+type dedupFlags struct {
+	dbURL   string
+	driver  string
+	window  time.Duration
+	dryRun  bool
+	logging scraping.Logging
+}
+
+func (f *dedupFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("dedup", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.driver, "driver", "postgres", "database driver for -dburl: postgres or sqlite")
+	fs.DurationVar(&f.window, "window", time.Hour, "rows with the same station and content_hash at most this far apart are duplicates")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "if set, only count the rows that would be deleted")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+// duplicatesWhere matches metars rows m that have an earlier row k with the
+// same station and content, within the window (in seconds) of the dialect's
+// windowConditions.  Deleting these keeps the earliest row of each run of
+// duplicates.  This happens when the same report was stored under different
+// keys, e.g. ingested with and without -round-time.
+const duplicatesWhere = `m.station = k.station
+  AND m.content_hash = k.content_hash
+  AND m.observation_time > k.observation_time
+  AND `
+
+// windowConditions are, by dialect, how far apart in seconds m and k's
+// observation times are.
+var windowConditions = map[*store.Dialect]string{
+	store.Postgres: "m.observation_time - k.observation_time <= ? * interval '1 second'",
+	store.SQLite:   "unixepoch(m.observation_time) - unixepoch(k.observation_time) <= ?",
+}
+
+func runDedup(ctx context.Context, flags *dedupFlags) error {
+	db, d, err := store.Open(flags.dbURL, flags.driver)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	count, err := dedupMetars(ctx, db, d, flags.window, flags.dryRun)
+	if err != nil {
+		return err
+	}
+	if flags.dryRun {
+		slog.Info("dry run: would delete duplicate rows", "rows", count)
+		return nil
+	}
+	slog.Info("deleted duplicate rows", "rows", count)
+	return nil
+}
+
+// dedupMetars deletes the metars rows duplicating an earlier one at most
+// window before, or with dryRun only counts them, and returns how many
+// there are.
+func dedupMetars(ctx context.Context, db *sql.DB, d *store.Dialect, window time.Duration, dryRun bool) (int64, error) {
+	condition, ok := windowConditions[d]
+	if !ok {
+		return 0, fmt.Errorf("dedup doesn't support %s", d.Driver)
+	}
+	duplicate := sq.Expr("EXISTS (SELECT 1 FROM metars k WHERE "+duplicatesWhere+condition+")", window.Seconds())
+	if dryRun {
+		var count int64
+		err := d.Builder.Select("count(*)").From("metars AS m").Where(duplicate).
+			RunWith(db).QueryRowContext(ctx).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf("counting duplicates: %w", err)
+		}
+		return count, nil
+	}
+	result, err := d.Builder.Delete("metars AS m").Where(duplicate).RunWith(db).ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("deleting duplicates: %w", err)
+	}
+	return result.RowsAffected()
+}
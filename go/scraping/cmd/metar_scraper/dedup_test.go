@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/store"
+)
+
+// metarsCount returns how many rows metars has.
+func metarsCount(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM metars").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDedup(t *testing.T) {
+	db := testDB(t)
+	at := time.Date(2026, 10, 14, 11, 53, 29, 0, time.UTC)
+	raw := "KBOS 141153Z 27010KT 10SM FEW050 12/05 A2992"
+	duplicate := func(station string, stored time.Time) {
+		t.Helper()
+		// the same report, stored at a different time, as if once with
+		// -round-time and once without
+		obs := testObservation(t, station, at, raw, nil)
+		obs.ObservationTime = stored
+		writeAll(t, store.New(db, store.SQLite, nil), obs)
+	}
+	duplicate("KBOS", at)
+	duplicate("KBOS", at.Round(time.Minute))
+	duplicate("KBOS", at.Round(5*time.Minute))
+	// too far from the others
+	duplicate("KBOS", at.Add(3*time.Hour))
+	// the same text for another station isn't a duplicate
+	duplicate("KJFK", at.Add(time.Minute))
+	// nor is a different report
+	writeAll(t, store.New(db, store.SQLite, nil),
+		testObservation(t, "KBOS", at.Add(time.Hour), "KBOS 141253Z 27010KT 10SM FEW050 12/05 A2992", nil))
+
+	n, err := dedupMetars(context.Background(), db, store.SQLite, time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("dry run counted %d, want 2", n)
+	}
+	if got := metarsCount(t, db); got != 6 {
+		t.Errorf("dry run left %d rows, want all 6", got)
+	}
+
+	n, err = dedupMetars(context.Background(), db, store.SQLite, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d, want 2", n)
+	}
+	var kept []time.Time
+	rows, err := db.Query("SELECT observation_time FROM metars WHERE station = 'KBOS' ORDER BY observation_time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stored time.Time
+		if err := rows.Scan(&stored); err != nil {
+			t.Fatal(err)
+		}
+		kept = append(kept, stored)
+	}
+	// the earliest of the duplicates is kept
+	want := []time.Time{at.Round(time.Minute), at.Add(time.Hour), at.Add(3 * time.Hour)}
+	if len(kept) != len(want) {
+		t.Fatalf("kept %v, want %v", kept, want)
+	}
+	for i := range want {
+		if !kept[i].Equal(want[i]) {
+			t.Errorf("kept %v, want %v", kept, want)
+			break
+		}
+	}
+
+	if n, err := dedupMetars(context.Background(), db, store.SQLite, time.Hour, true); err != nil || n != 0 {
+		t.Errorf("dry run after dedup: %d, %v; want none", n, err)
+	}
+}
+
+func TestDedupUnsupported(t *testing.T) {
+	if _, err := dedupMetars(context.Background(), nil, store.MySQL, time.Hour, true); err == nil {
+		t.Error("mysql: got no error")
+	}
+}
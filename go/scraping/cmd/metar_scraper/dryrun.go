@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// dryRunStore stands in for the databases with -dry-run.  Each observation
+// is still turned into the row d would write, so that encoding problems show
+// up, but nothing is sent anywhere; what would have been is logged on
+// commit.
+type dryRunStore struct {
+	dialect        *store.Dialect
+	opts           *store.Options
+	rows           int
+	oldest, newest time.Time
+}
+
+func (s *dryRunStore) Begin(ctx context.Context) error {
+	s.rows = 0
+	s.oldest, s.newest = time.Time{}, time.Time{}
+	return nil
+}
+
+func (s *dryRunStore) Write(obs *metarcsv.Observation) error {
+	if _, err := store.Row(s.dialect, obs, s.opts); err != nil {
+		return err
+	}
+	slog.Debug("dry run: would write", "station", obs.Station, "observation_time", obs.ObservationTime)
+	s.rows++
+	if s.oldest.IsZero() || obs.ObservationTime.Before(s.oldest) {
+		s.oldest = obs.ObservationTime
+	}
+	if obs.ObservationTime.After(s.newest) {
+		s.newest = obs.ObservationTime
+	}
+	return nil
+}
+
+func (s *dryRunStore) Commit() error {
+	slog.Info("dry run: would write", "rows", s.rows, "oldest", s.oldest, "newest", s.newest)
+	return nil
+}
+
+func (s *dryRunStore) Rollback() {}
+
+// dryRunDialect is the dialect rows are encoded for with -dry-run: the
+// first -dburl's, without connecting to it.
+func dryRunDialect(flags *Flags) *store.Dialect {
+	if len(flags.dbURLs) > 0 && store.IsClickHouse(flags.dbURLs[0]) {
+		return store.ClickHouse
+	}
+	if d := store.Drivers[flags.driver]; d != nil {
+		return d
+	}
+	return store.Postgres
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// defaultExpectedFields are the columns most stations report.
+const defaultExpectedFields = "temp_c,dewpoint_c,wind_dir_degrees,wind_speed_kt,visibility_statute_mi,altim_in_hg"
+
+// expectedFields tracks how often columns that are usually present are
+// missing.  A missing expected field is a warning (often a sensor being
+// down), unlike a malformed line, and never stops the row being written.
+type expectedFields struct {
+	names   []string
+	indexes []int
+	// checked is the number of observations checked, and missing counts the
+	// ones missing each field.
+	checked int
+	missing map[string]int
+}
+
+// newExpectedFields returns a checker for the comma-separated column names in
+// names, which must be in metarcsv.Columns.
+func newExpectedFields(names string) (*expectedFields, error) {
+	index := map[string]int{}
+	for i, name := range metarcsv.Columns {
+		index[name] = i
+	}
+	e := &expectedFields{missing: map[string]int{}}
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		e.names = append(e.names, name)
+		e.indexes = append(e.indexes, i)
+	}
+	return e, nil
+}
+
+func (e *expectedFields) check(obs *metarcsv.Observation) {
+	e.checked++
+	var missing []string
+	for i, name := range e.names {
+		if obs.Field(e.indexes[i]) == "" {
+			e.missing[name]++
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		slog.Debug("missing expected fields", "station", obs.Station, "observation_time", obs.ObservationTime, "fields", missing)
+	}
+}
+
+// summary describes how complete each field was, e.g. "temp_c 98.5% (3
+// missing)".
+func (e *expectedFields) summary() string {
+	var parts []string
+	for _, name := range e.names {
+		present := 100.0
+		if e.checked > 0 {
+			present = 100 * float64(e.checked-e.missing[name]) / float64(e.checked)
+		}
+		parts = append(parts, fmt.Sprintf("%s %.1f%% (%d missing)", name, present, e.missing[name]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// testFile is a metars cache file with a line for each of observations.
+func testFile(observations ...*metarcsv.Observation) string {
+	lines := []string{"No errors", "No warnings", "5 ms", "data source=metars", strconv.Itoa(len(observations)) + " results", metarcsv.Header}
+	for _, obs := range observations {
+		lines = append(lines, strings.Join(obs.Parts, ","))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// reported are the columns of a full report, as most stations send.
+var reported = map[int]string{
+	metarcsv.ColTempC:          "12.0",
+	metarcsv.ColDewpointC:      "5.0",
+	metarcsv.ColWindDirDegrees: "270",
+	metarcsv.ColWindSpeedKt:    "10",
+	metarcsv.ColVisibilityMi:   "10+",
+	metarcsv.ColAltimInHg:      "29.92",
+}
+
+func TestExpectedFields(t *testing.T) {
+	e, err := newExpectedFields(defaultExpectedFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC)
+	e.check(testObservation(t, "KBOS", at, "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", reported))
+	// the wind sensor is down
+	calm := maps.Clone(reported)
+	delete(calm, metarcsv.ColWindDirDegrees)
+	delete(calm, metarcsv.ColWindSpeedKt)
+	e.check(testObservation(t, "KJFK", at, "KJFK 141151Z 10SM FEW050 12/05 A2992", calm))
+
+	if want := map[string]int{"wind_dir_degrees": 1, "wind_speed_kt": 1}; !maps.Equal(e.missing, want) {
+		t.Errorf("missing = %v, want %v", e.missing, want)
+	}
+	want := "temp_c 100.0% (0 missing), dewpoint_c 100.0% (0 missing), wind_dir_degrees 50.0% (1 missing), " +
+		"wind_speed_kt 50.0% (1 missing), visibility_statute_mi 100.0% (0 missing), altim_in_hg 100.0% (0 missing)"
+	if got := e.summary(); got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+}
+
+func TestNewExpectedFields(t *testing.T) {
+	e, err := newExpectedFields(" temp_c, ,wind_gust_kt ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"temp_c", "wind_gust_kt"}; !slices.Equal(e.names, want) {
+		t.Errorf("names = %q, want %q", e.names, want)
+	}
+	if got := e.summary(); got != "temp_c 100.0% (0 missing), wind_gust_kt 100.0% (0 missing)" {
+		t.Errorf("summary before any check = %q", got)
+	}
+	if _, err := newExpectedFields("temp_c,temperature"); err == nil {
+		t.Error("unknown field: got no error")
+	}
+}
+
+func TestReadToDBMissingExpected(t *testing.T) {
+	db := testDB(t)
+	expected, err := newExpectedFields(defaultExpectedFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC)
+	noTemp := maps.Clone(reported)
+	delete(noTemp, metarcsv.ColTempC)
+	delete(noTemp, metarcsv.ColDewpointC)
+	file := testFile(
+		testObservation(t, "KBOS", at, "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", reported),
+		testObservation(t, "KJFK", at, "KJFK 141154Z 27010KT 10SM FEW050 A2992", noTemp),
+	)
+	opts := &ingestOptions{inputFormat: "csv", expected: expected}
+	stats := &runStats{}
+	if err := readToDB(context.Background(), store.New(db, store.SQLite, nil), strings.NewReader(file), opts, stats); err != nil {
+		t.Fatal(err)
+	}
+	// the row missing them is still written, and only counted
+	if stats.rowsWritten != 2 || stats.rowsSkipped() != 0 {
+		t.Errorf("wrote %d and skipped %d, want 2 and none", stats.rowsWritten, stats.rowsSkipped())
+	}
+	if got := metarsCount(t, db); got != 2 {
+		t.Errorf("stored %d rows, want 2", got)
+	}
+	if want := map[string]int{"temp_c": 1, "dewpoint_c": 1}; !maps.Equal(stats.expectedMissing, want) {
+		t.Errorf("expectedMissing = %v, want %v", stats.expectedMissing, want)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	pq "github.com/lib/pq"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+)
+
+type exportFlags struct {
+	dbURL    string
+	stations stringsFlag
+	from     string
+	to       string
+	out      string
+	format   string
+	logging  scraping.Logging
+}
+
+func (f *exportFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.Var(&f.stations, "station", "station to export; may be repeated or comma-separated.  all stations if unset")
+	fs.StringVar(&f.from, "from", "", "start of the range, inclusive, as 2006-01-02 or RFC 3339")
+	fs.StringVar(&f.to, "to", "", "end of the range, exclusive, as 2006-01-02 or RFC 3339")
+	fs.StringVar(&f.out, "out", "", "file to write to, or with -format parquet the directory to write a file per day under; stdout if unset")
+	fs.StringVar(&f.format, "format", "", "jsonl, csv or parquet; taken from -out's extension if unset")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+// parseTimeFlag parses a date or RFC 3339 time.
+func parseTimeFlag(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// outputSchemaVersion is the version of the field layout of the jsonl, csv
+// and parquet exports and the Influx line protocol, written with each as
+// schema_version.  It's bumped whenever a field is added, removed or renamed
+// or changes type or meaning, including when metarcsv.Header gains a
+// column, so that consumers can tell which layout they have.
+//
+// Version 1 is the layout of the first versioned release.
+const outputSchemaVersion = 1
+
+// exportWriter writes exported observations in some file format.
+type exportWriter interface {
+	write(obs *metarcsv.Observation) error
+	flush() error
+}
+
+// jsonlWriter writes one JSON object per line, with the station and
+// observation time plus every non-empty column by name, and schema_version.
+type jsonlWriter struct {
+	w *bufio.Writer
+}
+
+// observationRecord returns obs's station, observation time and non-empty
+// columns by name.
+func observationRecord(obs *metarcsv.Observation) map[string]interface{} {
+	record := map[string]interface{}{}
+	for i, value := range obs.Parts {
+		if value != "" && i < len(metarcsv.Columns) {
+			record[metarcsv.Columns[i]] = value
+		}
+	}
+	record["station"] = obs.Station
+	record["observation_time"] = obs.ObservationTime.UTC().Format(time.RFC3339)
+	return record
+}
+
+func (j *jsonlWriter) write(obs *metarcsv.Observation) error {
+	record := observationRecord(obs)
+	record["schema_version"] = outputSchemaVersion
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	j.w.Write(line)
+	return j.w.WriteByte('\n')
+}
+
+func (j *jsonlWriter) flush() error {
+	return j.w.Flush()
+}
+
+// csvWriter writes the columns as they came from the metars file, under the
+// same header line, and a schema_version column.  The header is preceded by
+// a "# schema_version=N" comment line, for readers to check before parsing
+// the rest.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := fmt.Fprintf(w, "# schema_version=%d\n", outputSchemaVersion); err != nil {
+		return nil, err
+	}
+	c := &csvWriter{csv.NewWriter(w)}
+	header := append(strings.Split(metarcsv.Header, ","), "schema_version")
+	if err := c.w.Write(header); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) write(obs *metarcsv.Observation) error {
+	// padded so that schema_version is always under its header
+	record := make([]string, len(metarcsv.Columns), len(metarcsv.Columns)+1)
+	copy(record, obs.Parts)
+	return c.w.Write(append(record, strconv.Itoa(outputSchemaVersion)))
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func runExport(ctx context.Context, flags *exportFlags) error {
+	from, err := parseTimeFlag(flags.from)
+	if err != nil {
+		return fmt.Errorf("bad -from: %w", err)
+	}
+	to, err := parseTimeFlag(flags.to)
+	if err != nil {
+		return fmt.Errorf("bad -to: %w", err)
+	}
+	var stations []string
+	for _, s := range flags.stations {
+		stations = append(stations, strings.Split(s, ",")...)
+	}
+	format := flags.format
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(flags.out), ".")
+	}
+
+	out := os.Stdout
+	if flags.out != "" && format != "parquet" {
+		if out, err = os.Create(flags.out); err != nil {
+			return fmt.Errorf("creating output: %w", err)
+		}
+		defer out.Close()
+	}
+	buffered := bufio.NewWriter(out)
+	var w exportWriter
+	// parquet is written a day at a time, so needs the rows in time order
+	order := []string{"station", "observation_time"}
+	switch format {
+	case "jsonl":
+		w = &jsonlWriter{buffered}
+	case "csv":
+		if w, err = newCSVWriter(buffered); err != nil {
+			return fmt.Errorf("writing header: %w", err)
+		}
+	case "parquet":
+		if flags.out == "" {
+			return errors.New("-format parquet needs an -out directory")
+		}
+		w = &parquetWriter{dir: flags.out}
+		order = []string{"observation_time", "station"}
+	default:
+		return fmt.Errorf("unknown format %q; use -format jsonl, csv or parquet", format)
+	}
+
+	db, err := sql.Open("postgres", flags.dbURL)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	query := store.Postgres.Builder.Select("station", "observation_time", "csv_parts").
+		From("metars").
+		Where(sq.GtOrEq{"observation_time": from}).
+		Where(sq.Lt{"observation_time": to}).
+		OrderBy(order...)
+	if len(stations) > 0 {
+		query = query.Where(sq.Eq{"station": stations})
+	}
+	sqlText, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	// rows are streamed straight to the writer so large ranges don't have to
+	// fit in memory
+	rows, err := db.QueryContext(ctx, sqlText, args...)
+	if err != nil {
+		return fmt.Errorf("querying: %w", err)
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		obs := &metarcsv.Observation{}
+		var parts pq.StringArray
+		if err := rows.Scan(&obs.Station, &obs.ObservationTime, &parts); err != nil {
+			return fmt.Errorf("scanning: %w", err)
+		}
+		obs.Parts = parts
+		if err := w.write(obs); err != nil {
+			return fmt.Errorf("writing: %w", err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("querying: %w", err)
+	}
+	if err := w.flush(); err != nil {
+		return fmt.Errorf("writing: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("writing: %w", err)
+	}
+	if p, ok := w.(*parquetWriter); ok {
+		slog.Info("exported observations", "rows", count, "files", p.files)
+		return nil
+	}
+	slog.Info("exported observations", "rows", count)
+	return nil
+}
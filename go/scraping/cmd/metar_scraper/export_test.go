@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// exportObservation is an observation with a few columns, for the writers.
+func exportObservation(t *testing.T) *metarcsv.Observation {
+	t.Helper()
+	return testObservation(t, "KBOS", time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC), "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992",
+		map[int]string{metarcsv.ColTempC: "12.0", metarcsv.ColWindSpeedKt: "10"})
+}
+
+func TestJSONLSchemaVersion(t *testing.T) {
+	var buf bytes.Buffer
+	w := &jsonlWriter{bufio.NewWriter(&buf)}
+	if err := w.write(exportObservation(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["schema_version"] != float64(outputSchemaVersion) || record["temp_c"] != "12.0" {
+		t.Errorf("record %v, want schema_version %d", record, outputSchemaVersion)
+	}
+}
+
+func TestCSVSchemaVersion(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newCSVWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs := exportObservation(t)
+	// as stored before metarcsv.Header's last columns were added
+	obs.Parts = obs.Parts[:20]
+	if err := w.write(obs); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	comment, rest, _ := strings.Cut(buf.String(), "\n")
+	if comment != "# schema_version=1" {
+		t.Errorf("first line %q", comment)
+	}
+	records, err := csv.NewReader(strings.NewReader(rest)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records, want a header and a row", len(records))
+	}
+	header, row := records[0], records[1]
+	if header[len(header)-1] != "schema_version" || len(row) != len(header) {
+		t.Errorf("header ends %q with %d columns, row has %d", header[len(header)-1], len(header), len(row))
+	}
+	if row[len(row)-1] != "1" || row[metarcsv.ColTempC] != "12.0" {
+		t.Errorf("row %q", row)
+	}
+}
+
+func TestParquetSchemaVersion(t *testing.T) {
+	row, err := newParquetRow(exportObservation(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.SchemaVersion != outputSchemaVersion {
+		t.Errorf("schema_version %d, want %d", row.SchemaVersion, outputSchemaVersion)
+	}
+}
+
+func TestInfluxSchemaVersion(t *testing.T) {
+	line, err := influxLine(exportObservation(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(line, ",schema_version=1i,") && !strings.Contains(line, ",schema_version=1i ") {
+		t.Errorf("line %q has no schema_version field", line)
+	}
+}
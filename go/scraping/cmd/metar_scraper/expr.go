@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// alertExpr is a parsed alert condition, like
+// "ceiling < 1000 ft or visibility < 3 sm".  Comparisons on a value the
+// observation doesn't have are false.
+type alertExpr interface {
+	match(obs *metarcsv.Observation) bool
+}
+
+type alertOr []alertExpr
+
+func (e alertOr) match(obs *metarcsv.Observation) bool {
+	for _, sub := range e {
+		if sub.match(obs) {
+			return true
+		}
+	}
+	return false
+}
+
+type alertAnd []alertExpr
+
+func (e alertAnd) match(obs *metarcsv.Observation) bool {
+	for _, sub := range e {
+		if !sub.match(obs) {
+			return false
+		}
+	}
+	return true
+}
+
+type alertComparison struct {
+	value  func(obs *metarcsv.Observation) (float64, bool)
+	op     string
+	number float64
+}
+
+func (e *alertComparison) match(obs *metarcsv.Observation) bool {
+	value, ok := e.value(obs)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return value < e.number
+	case "<=":
+		return value <= e.number
+	case ">":
+		return value > e.number
+	case ">=":
+		return value >= e.number
+	case "!=":
+		return value != e.number
+	}
+	return value == e.number
+}
+
+// alertCategory compares the flight category, which can't be ordered.
+type alertCategory struct {
+	equal    bool
+	category string
+}
+
+func (e *alertCategory) match(obs *metarcsv.Observation) bool {
+	category := obs.FlightCategory()
+	return category != "" && (category == e.category) == e.equal
+}
+
+// alertField is a number alert conditions can compare.  unit may follow a
+// number it's compared against, as a reminder of what it's in.
+type alertField struct {
+	unit  string
+	value func(obs *metarcsv.Observation) (float64, bool)
+}
+
+func columnValue(i int) func(obs *metarcsv.Observation) (float64, bool) {
+	return func(obs *metarcsv.Observation) (float64, bool) { return obs.Number(i) }
+}
+
+// alertFields are the fields by their short names; the column names work
+// too.
+var alertFields = map[string]alertField{
+	"ceiling":    {"ft", ceilingValue},
+	"visibility": {"sm", visibilityValue},
+	"temp":       {"c", columnValue(metarcsv.ColTempC)},
+	"dewpoint":   {"c", columnValue(metarcsv.ColDewpointC)},
+	"spread":     {"c", spreadValue},
+	"wind":       {"kt", columnValue(metarcsv.ColWindSpeedKt)},
+	"gust":       {"kt", columnValue(metarcsv.ColWindGustKt)},
+	"wind_dir":   {"deg", columnValue(metarcsv.ColWindDirDegrees)},
+	"altimeter":  {"inhg", columnValue(metarcsv.ColAltimInHg)},
+}
+
+var alertFieldAliases = map[string]string{
+	"ceiling_ft":            "ceiling",
+	"visibility_statute_mi": "visibility",
+	"temp_c":                "temp",
+	"dewpoint_c":            "dewpoint",
+	"wind_speed_kt":         "wind",
+	"wind_gust_kt":          "gust",
+	"wind_dir_degrees":      "wind_dir",
+	"altim_in_hg":           "altimeter",
+}
+
+// ceilingValue is the ceiling decoded from the raw text, infinite if there's
+// none.
+func ceilingValue(obs *metarcsv.Observation) (float64, bool) {
+	decoded, err := metar.Decode(obs.Field(metarcsv.ColRawText))
+	if err != nil {
+		return 0, false
+	}
+	ceiling := decoded.Conditions.CeilingFt()
+	if ceiling == nil {
+		return math.Inf(1), true
+	}
+	return float64(*ceiling), true
+}
+
+// visibilityValue is the CSV visibility, or else the raw text's.
+func visibilityValue(obs *metarcsv.Observation) (float64, bool) {
+	if miles, ok := obs.Number(metarcsv.ColVisibilityMi); ok {
+		return miles, true
+	}
+	decoded, err := metar.Decode(obs.Field(metarcsv.ColRawText))
+	if err != nil || decoded.Conditions.Miles() == nil {
+		return 0, false
+	}
+	return *decoded.Conditions.Miles(), true
+}
+
+// spreadValue is the temperature/dewpoint spread, small when fog is likely.
+func spreadValue(obs *metarcsv.Observation) (float64, bool) {
+	temp, ok := obs.Number(metarcsv.ColTempC)
+	dewpoint, ok2 := obs.Number(metarcsv.ColDewpointC)
+	return temp - dewpoint, ok && ok2
+}
+
+var alertToken = regexp.MustCompile(`^\s*(<=|>=|==|!=|<|>|=|\(|\)|[A-Za-z_]+|-?[0-9]+(\.[0-9]+)?)`)
+
+// parseAlertExpr parses text, which compares fields with <, <=, >, >=, = and
+// !=, combined with and, or (which binds more loosely) and parentheses.
+// flight_category (or category) can be compared with = and != against
+// VFR, MVFR, IFR or LIFR.
+func parseAlertExpr(text string) (alertExpr, error) {
+	var tokens []string
+	for rest := text; strings.TrimSpace(rest) != ""; {
+		match := alertToken.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, fmt.Errorf("unexpected %q", strings.TrimSpace(rest))
+		}
+		tokens = append(tokens, match[1])
+		rest = rest[len(match[0]):]
+	}
+	p := &alertParser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q", tokens[p.pos])
+	}
+	return expr, nil
+}
+
+type alertParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *alertParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *alertParser) next() (string, error) {
+	token := p.peek()
+	if token == "" {
+		return "", fmt.Errorf("unexpected end")
+	}
+	p.pos++
+	return token, nil
+}
+
+func (p *alertParser) or() (alertExpr, error) {
+	expr := alertOr{}
+	for {
+		sub, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		expr = append(expr, sub)
+		if !strings.EqualFold(p.peek(), "or") {
+			break
+		}
+		p.pos++
+	}
+	if len(expr) == 1 {
+		return expr[0], nil
+	}
+	return expr, nil
+}
+
+func (p *alertParser) and() (alertExpr, error) {
+	expr := alertAnd{}
+	for {
+		sub, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		expr = append(expr, sub)
+		if !strings.EqualFold(p.peek(), "and") {
+			break
+		}
+		p.pos++
+	}
+	if len(expr) == 1 {
+		return expr[0], nil
+	}
+	return expr, nil
+}
+
+func (p *alertParser) comparison() (alertExpr, error) {
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if name == "(" {
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing, err := p.next(); err != nil || closing != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return expr, nil
+	}
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "==":
+		op = "="
+	case "<", "<=", ">", ">=", "=", "!=":
+	default:
+		return nil, fmt.Errorf("expected a comparison after %s, got %q", name, op)
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+	if name == "flight_category" || name == "category" {
+		category := strings.ToUpper(value)
+		if category != metar.CategoryVFR && category != metar.CategoryMVFR && category != metar.CategoryIFR && category != metar.CategoryLIFR {
+			return nil, fmt.Errorf("unknown flight category %q", value)
+		}
+		if op != "=" && op != "!=" {
+			return nil, fmt.Errorf("flight categories can only be compared with = and !=")
+		}
+		return &alertCategory{equal: op == "=", category: category}, nil
+	}
+	if alias, ok := alertFieldAliases[name]; ok {
+		name = alias
+	}
+	field, ok := alertFields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", name)
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s compared with %q, not a number", name, value)
+	}
+	if unit := p.peek(); strings.EqualFold(unit, field.unit) {
+		p.pos++
+	}
+	return &alertComparison{value: field.value, op: op, number: number}, nil
+}
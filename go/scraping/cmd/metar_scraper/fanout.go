@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// stringsFlag is a flag that may be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// fanTarget is one store being fanned out to.
+type fanTarget struct {
+	// name identifies the target in logs without leaking its connection
+	// string.
+	name  string
+	store store.Store
+	// failed is set once a tolerated secondary has failed, after which it is
+	// skipped for the rest of the run.
+	failed bool
+}
+
+// fanout is a store that writes the same observations to a primary store and
+// any number of secondaries.  The primary failing always fails the run;
+// secondary failures do too unless tolerate is set, in which case the
+// secondary is rolled back, logged and dropped.
+type fanout struct {
+	targets  []*fanTarget
+	tolerate bool
+	// secondaryErrs collects the errors of tolerated secondary failures.
+	secondaryErrs []error
+}
+
+// newFanout returns a fanout over stores, the first being the primary.
+func newFanout(stores []store.Store, tolerate bool) *fanout {
+	f := &fanout{tolerate: tolerate}
+	for i, s := range stores {
+		name := "primary"
+		if i > 0 {
+			name = fmt.Sprintf("secondary %d", i)
+		}
+		f.targets = append(f.targets, &fanTarget{name: name, store: s})
+	}
+	return f
+}
+
+// fail records err for t.  It returns the error that should fail the run, or
+// nil if the failure is tolerated.
+func (f *fanout) fail(t *fanTarget, err error) error {
+	err = fmt.Errorf("%s: %w", t.name, err)
+	if t == f.targets[0] || !f.tolerate {
+		return err
+	}
+	slog.Warn("dropping failed database", "err", err)
+	t.store.Rollback()
+	t.failed = true
+	f.secondaryErrs = append(f.secondaryErrs, err)
+	return nil
+}
+
+// each calls fn for every target that hasn't failed.
+func (f *fanout) each(fn func(s store.Store) error) error {
+	for _, t := range f.targets {
+		if t.failed {
+			continue
+		}
+		if err := fn(t.store); err != nil {
+			if err := f.fail(t, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fanout) Begin(ctx context.Context) error {
+	return f.each(func(s store.Store) error { return s.Begin(ctx) })
+}
+
+func (f *fanout) Write(obs *metarcsv.Observation) error {
+	return f.each(func(s store.Store) error { return s.Write(obs) })
+}
+
+// Commit commits the primary first, so a secondary is never ahead of it.
+func (f *fanout) Commit() error {
+	return f.each(func(s store.Store) error { return s.Commit() })
+}
+
+func (f *fanout) Rollback() {
+	for _, t := range f.targets {
+		t.store.Rollback()
+	}
+}
+
+// secondaryErr returns the tolerated secondary failures joined together, or
+// nil if there were none.
+func (f *fanout) secondaryErr() error {
+	return errors.Join(f.secondaryErrs...)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// fakeStore keeps the observations committed to it, and fails whichever
+// calls have errors set.
+type fakeStore struct {
+	beginErr, writeErr, commitErr error
+	pending, committed            []*metarcsv.Observation
+	rolledBack                    bool
+}
+
+func (s *fakeStore) Begin(ctx context.Context) error {
+	s.pending = nil
+	return s.beginErr
+}
+
+func (s *fakeStore) Write(obs *metarcsv.Observation) error {
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+	s.pending = append(s.pending, obs)
+	return nil
+}
+
+func (s *fakeStore) Commit() error {
+	if s.commitErr != nil {
+		return s.commitErr
+	}
+	s.committed = append(s.committed, s.pending...)
+	s.pending = nil
+	return nil
+}
+
+func (s *fakeStore) Rollback() {
+	if s.pending != nil {
+		s.rolledBack = true
+	}
+	s.pending = nil
+}
+
+// fanOut writes observations through f in one transaction, returning the
+// error that fails it.
+func fanOut(f *fanout, observations ...*metarcsv.Observation) error {
+	if err := f.Begin(context.Background()); err != nil {
+		return err
+	}
+	defer f.Rollback()
+	for _, obs := range observations {
+		if err := f.Write(obs); err != nil {
+			return err
+		}
+	}
+	return f.Commit()
+}
+
+func TestFanoutSecondaryCommitFails(t *testing.T) {
+	errCommit := errors.New("connection reset")
+	obs := testObservation(t, "KBOS", time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), "KBOS 141200Z 27010KT 10SM FEW050 12/05 A2992", nil)
+	for _, tolerate := range []bool{false, true} {
+		primary, failing, healthy := &fakeStore{}, &fakeStore{commitErr: errCommit}, &fakeStore{}
+		f := newFanout([]store.Store{primary, failing, healthy}, tolerate)
+		err := fanOut(f, obs)
+		if !tolerate {
+			if !errors.Is(err, errCommit) {
+				t.Errorf("not tolerated: err = %v, want %v", err, errCommit)
+			}
+			if f.secondaryErr() != nil {
+				t.Errorf("not tolerated: secondaryErr = %v", f.secondaryErr())
+			}
+			// the primary commits first, and the secondary after the failed
+			// one is rolled back
+			if len(primary.committed) != 1 || len(healthy.committed) != 0 || !healthy.rolledBack {
+				t.Errorf("not tolerated: committed %d to primary, %d to the healthy secondary (rolled back %v)", len(primary.committed), len(healthy.committed), healthy.rolledBack)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("tolerated: err = %v", err)
+		}
+		if !errors.Is(f.secondaryErr(), errCommit) {
+			t.Errorf("tolerated: secondaryErr = %v, want %v", f.secondaryErr(), errCommit)
+		}
+		if len(primary.committed) != 1 || len(healthy.committed) != 1 || len(failing.committed) != 0 {
+			t.Errorf("tolerated: committed %d to primary, %d to the healthy secondary, %d to the failing one", len(primary.committed), len(healthy.committed), len(failing.committed))
+		}
+		// the failed secondary is dropped for the rest of the run
+		failing.commitErr = nil
+		if err := fanOut(f, obs); err != nil {
+			t.Errorf("tolerated, second file: %v", err)
+		}
+		if len(failing.committed) != 0 || len(healthy.committed) != 2 {
+			t.Errorf("tolerated, second file: committed %d to the failed secondary, %d to the healthy one", len(failing.committed), len(healthy.committed))
+		}
+	}
+}
+
+func TestFanoutPrimaryFails(t *testing.T) {
+	errWrite := errors.New("disk full")
+	obs := testObservation(t, "KBOS", time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), "KBOS 141200Z 27010KT 10SM FEW050 12/05 A2992", nil)
+	primary, secondary := &fakeStore{writeErr: errWrite}, &fakeStore{}
+	f := newFanout([]store.Store{primary, secondary}, true)
+	if err := fanOut(f, obs); !errors.Is(err, errWrite) {
+		t.Errorf("err = %v, want %v even when tolerating", err, errWrite)
+	}
+	if len(secondary.committed) != 0 {
+		t.Errorf("committed %d to the secondary after the primary failed", len(secondary.committed))
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"mattdee123.com/aviationweather/store"
+)
+
+// exitFreshnessBreach is the exit status when the newest stored observation
+// is older than -freshness-sla.
+const exitFreshnessBreach = 3
+
+// freshnessAlert is the JSON payload posted to -alert-webhook.
+type freshnessAlert struct {
+	SLASeconds float64 `json:"sla_seconds"`
+	// LagSeconds and NewestObservation are omitted if there are no
+	// observations at all.
+	LagSeconds        float64    `json:"lag_seconds,omitempty"`
+	NewestObservation *time.Time `json:"newest_observation,omitempty"`
+	RunSuccess        bool       `json:"run_success"`
+	RunError          string     `json:"run_error,omitempty"`
+	RowsWritten       int        `json:"rows_written"`
+	CheckedAt         time.Time  `json:"checked_at"`
+}
+
+// newestObservation returns the most recent observation_time in metars, or
+// nil if the table is empty.
+func newestObservation(ctx context.Context, db *sql.DB) (*time.Time, error) {
+	var newest sql.NullTime
+	// not max(), so that SQLite knows the result is a timestamp
+	err := db.QueryRowContext(ctx, "SELECT observation_time FROM metars ORDER BY observation_time DESC LIMIT 1").Scan(&newest)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if !newest.Valid {
+		return nil, nil
+	}
+	return &newest.Time, nil
+}
+
+// checkFreshness returns an alert if the newest observation in db is older
+// than sla, or nil if it's fresh enough.  The database is checked rather than
+// this run's rows so that a run which fails outright still alerts.
+func checkFreshness(ctx context.Context, db *sql.DB, sla time.Duration, stats *runStats, runErr error) (*freshnessAlert, error) {
+	newest, err := newestObservation(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("finding newest observation: %w", err)
+	}
+	now := time.Now()
+	alert := &freshnessAlert{
+		SLASeconds:        sla.Seconds(),
+		NewestObservation: newest,
+		RunSuccess:        runErr == nil,
+		RowsWritten:       stats.rowsWritten,
+		CheckedAt:         now,
+	}
+	if runErr != nil {
+		alert.RunError = runErr.Error()
+	}
+	if newest != nil {
+		lag := now.Sub(*newest)
+		if lag <= sla {
+			return nil, nil
+		}
+		alert.LagSeconds = lag.Seconds()
+	}
+	return alert, nil
+}
+
+func postAlert(webhook string, alert *freshnessAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// reportFreshness checks the primary database against flags.freshnessSLA,
+// logging a breach and posting it to flags.alertWebhook if set.  It returns
+// whether the SLA was breached.  Failures to check or alert are logged
+// rather than returned, so they never mask the run's own result.
+func reportFreshness(flags *Flags, stats *runStats, runErr error) bool {
+	dbURL := ""
+	if len(flags.dbURLs) > 0 {
+		dbURL = flags.dbURLs[0]
+	}
+	db, _, err := store.Open(dbURL, flags.driver)
+	if err != nil {
+		slog.Warn("checking freshness: connecting to database", "err", err)
+		return false
+	}
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	alert, err := checkFreshness(ctx, db, flags.freshnessSLA, stats, runErr)
+	if err != nil {
+		slog.Warn("checking freshness", "err", err)
+		return false
+	}
+	if alert == nil {
+		return false
+	}
+	if alert.NewestObservation == nil {
+		slog.Error("freshness SLA breached: no observations stored", "sla", flags.freshnessSLA)
+	} else {
+		slog.Error("freshness SLA breached", "sla", flags.freshnessSLA, "lag_seconds", alert.LagSeconds)
+	}
+	if flags.alertWebhook != "" {
+		if err := postAlert(flags.alertWebhook, alert); err != nil {
+			slog.Warn("posting freshness alert", "err", err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+)
+
+type gapsFlags struct {
+	dbURL    string
+	driver   string
+	stations stringsFlag
+	window   time.Duration
+	minGap   time.Duration
+	refetch  bool
+	apiURL   string
+	logging  scraping.Logging
+}
+
+func (f *gapsFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("gaps", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.driver, "driver", "postgres", "database driver for -dburl, as for the scraper")
+	fs.Var(&f.stations, "station", "station to check; may be repeated or comma-separated.  every station reporting in the window if unset")
+	fs.DurationVar(&f.window, "window", 24*time.Hour, "how far back to look for gaps")
+	fs.DurationVar(&f.minGap, "min-gap", 90*time.Minute, "time between consecutive observations of a station that counts as a gap; stations report at least hourly")
+	fs.BoolVar(&f.refetch, "refetch", false, "if set, fetch the reports in each gap from the AWC API and store the missing ones")
+	fs.StringVar(&f.apiURL, "api-url", awc.MetarAPIURL, "url of the AWC METAR API")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+// gap is a stretch with no observations of station between two that were
+// stored.
+type gap struct {
+	station string
+	after   time.Time
+	before  time.Time
+}
+
+// findGaps returns the gaps longer than minGap between observations since
+// start, for stations, or every station with an observation since start if
+// stations is empty.  Stations that stopped reporting partway through don't
+// count, since there's nothing after the gap to bound it.
+func findGaps(ctx context.Context, db *sql.DB, d *store.Dialect, stations []string, start time.Time, minGap time.Duration) ([]gap, error) {
+	query := d.Builder.Select("station", "observation_time").From("metars").
+		Where(sq.GtOrEq{"observation_time": start}).
+		OrderBy("station", "observation_time")
+	if len(stations) > 0 {
+		query = query.Where(sq.Eq{"station": stations})
+	}
+	rows, err := query.RunWith(db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var gaps []gap
+	var lastStation string
+	var last time.Time
+	for rows.Next() {
+		var station string
+		var t time.Time
+		if err := rows.Scan(&station, &t); err != nil {
+			return nil, err
+		}
+		if station == lastStation && t.Sub(last) > minGap {
+			gaps = append(gaps, gap{station, last, t})
+		}
+		lastStation, last = station, t
+	}
+	return gaps, rows.Err()
+}
+
+func apiURL(base string, g gap) string {
+	q := awc.Query{
+		Format: "raw",
+		IDs:    []string{g.station},
+		Date:   g.before,
+		Hours:  int(math.Ceil(g.before.Sub(g.after).Hours())),
+	}
+	return q.URL(base)
+}
+
+// readRawReports reads one raw METAR per line, dropping those that aren't
+// strictly inside g.
+func readRawReports(r io.Reader, g gap) ([]*metarcsv.Observation, error) {
+	var observations []*metarcsv.Observation
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		decoded, err := metar.Decode(raw)
+		if err != nil {
+			slog.Warn("skipping unparseable observation", "text", raw, "err", err)
+			continue
+		}
+		t := reportTime(decoded, g.before)
+		if !t.After(g.after) || !t.Before(g.before) {
+			continue
+		}
+		observations = append(observations, archiveObservation(g.station, t, raw))
+	}
+	return observations, scanner.Err()
+}
+
+// reportTime resolves a report's day and time to the latest such time at or
+// before ref, since the report only carries the day of the month.
+func reportTime(m *metar.METAR, ref time.Time) time.Time {
+	ref = ref.UTC()
+	for months := 0; months < 12; months++ {
+		month := time.Date(ref.Year(), ref.Month()-time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+		t := time.Date(month.Year(), month.Month(), m.Day, m.Hour, m.Minute, 0, 0, time.UTC)
+		// a day past the end of the month rolls over into the next
+		if t.Month() == month.Month() && !t.After(ref) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func runGaps(ctx context.Context, flags *gapsFlags) error {
+	var stations []string
+	for _, s := range flags.stations {
+		stations = append(stations, strings.Split(s, ",")...)
+	}
+	db, d, err := store.Open(flags.dbURL, flags.driver)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	if d == store.ClickHouse {
+		return errors.New("gaps isn't supported for clickhouse")
+	}
+	gaps, err := findGaps(ctx, db, d, stations, time.Now().Add(-flags.window), flags.minGap)
+	if err != nil {
+		return fmt.Errorf("finding gaps: %w", err)
+	}
+	for _, g := range gaps {
+		slog.Info("gap", "station", g.station, "after", g.after, "before", g.before, "duration", g.before.Sub(g.after))
+	}
+	slog.Info("found gaps", "gaps", len(gaps))
+	if !flags.refetch {
+		return nil
+	}
+	b := &backfiller{
+		downloader: &awc.Downloader{MaxAttempts: 3, Backoff: 5 * time.Second, MaxBackoff: time.Minute, Timeout: 5 * time.Minute, HeaderTimeout: 30 * time.Second},
+		out:        store.New(db, d, nil),
+		db:         db,
+		dialect:    d,
+		opts:       &ingestOptions{},
+	}
+	filled := 0
+	for _, g := range gaps {
+		body, err := b.downloader.Open(ctx, apiURL(flags.apiURL, g))
+		if err != nil {
+			return fmt.Errorf("fetching %s: %w", g.station, err)
+		}
+		observations, err := readRawReports(body, g)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", g.station, err)
+		}
+		written, err := b.store(ctx, g.station, g.after, g.before, observations)
+		if err != nil {
+			return fmt.Errorf("storing %s: %w", g.station, err)
+		}
+		slog.Info("filled gap", "station", g.station, "after", g.after, "before", g.before, "written", written)
+		filled += written
+	}
+	slog.Info("filled gaps", "written", filled, "gaps", len(gaps))
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+	"mattdee123.com/aviationweather/units"
+)
+
+type getFlags struct {
+	dbURL    string
+	driver   string
+	apiURL   string
+	json     bool
+	units    units.System
+	stations []string
+	logging  scraping.Logging
+}
+
+func (f *getFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database; if unset, the AWC API is asked instead")
+	fs.StringVar(&f.driver, "driver", "postgres", "database driver for -dburl, as for the scraper")
+	fs.StringVar(&f.apiURL, "api-url", awc.MetarAPIURL, "url of the AWC METAR API, used without -dburl")
+	fs.BoolVar(&f.json, "json", false, "if set, print the observations as JSON, as the serve command returns them")
+	unitsFlag(fs, &f.units)
+	f.logging.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: metar_scraper get [flags] STATION...\n")
+		fs.PrintDefaults()
+	}
+	scraping.ParseFlags(fs, args)
+	f.stations = splitList(fs.Args())
+}
+
+// runGet prints the latest observation at each of flags.stations, from the
+// database or else the AWC API.
+func runGet(ctx context.Context, flags *getFlags) error {
+	if len(flags.stations) == 0 {
+		return errors.New("no stations given")
+	}
+	var latest map[string]*metarcsv.Observation
+	var err error
+	if flags.dbURL != "" {
+		latest, err = latestFromDB(ctx, flags)
+	} else {
+		latest, err = latestFromAPI(ctx, flags.apiURL, flags.stations)
+	}
+	if err != nil {
+		return err
+	}
+	var observations []*apiObservation
+	var missing []string
+	for _, station := range flags.stations {
+		if obs := latest[station]; obs != nil {
+			a := newAPIObservation(obs)
+			a.setUnits(flags.units)
+			observations = append(observations, a)
+		} else {
+			missing = append(missing, station)
+		}
+	}
+	if flags.json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(observations); err != nil {
+			return err
+		}
+	} else {
+		for _, station := range flags.stations {
+			if obs := latest[station]; obs != nil {
+				if err := printObservation(os.Stdout, obs, flags.units); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no observations for %v", missing)
+	}
+	return nil
+}
+
+func latestFromDB(ctx context.Context, flags *getFlags) (map[string]*metarcsv.Observation, error) {
+	db, d, err := store.Open(flags.dbURL, flags.driver)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	latest, err := newestObservations(ctx, db, d, flags.stations)
+	if err != nil {
+		return nil, fmt.Errorf("querying observations: %w", err)
+	}
+	return latest, nil
+}
+
+// latestFromAPI asks the AWC API at apiURL for the latest observation at each
+// of stations.  The API answers 204 No Content when none of them have
+// reports, which is an empty result rather than an error.
+func latestFromAPI(ctx context.Context, apiURL string, stations []string) (map[string]*metarcsv.Observation, error) {
+	downloader := &awc.Downloader{MaxAttempts: 3, Backoff: 2 * time.Second, MaxBackoff: 10 * time.Second, Timeout: time.Minute, HeaderTimeout: 30 * time.Second}
+	body, err := downloader.Open(ctx, awc.Query{Format: "json", IDs: stations, Hours: 3}.URL(apiURL))
+	if errors.Is(err, awc.ErrNoContent) {
+		return map[string]*metarcsv.Observation{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("fetching from the API: %w", err)
+	}
+	defer body.Close()
+	input, err := metarcsv.Open(body, "json", nil)
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]*metarcsv.Observation{}
+	for input.Next() {
+		_, obs, err := input.Record()
+		if err != nil || obs == nil {
+			continue
+		}
+		if prev := latest[obs.Station]; prev == nil || obs.ObservationTime.After(prev.ObservationTime) {
+			latest[obs.Station] = obs
+		}
+	}
+	return latest, input.Err()
+}
+
+// printObservation writes obs's time and raw text, then what it decodes to.
+func printObservation(w io.Writer, obs *metarcsv.Observation, u units.System) error {
+	age := time.Since(obs.ObservationTime).Round(time.Minute)
+	raw := obs.Field(metarcsv.ColRawText)
+	fmt.Fprintf(w, "%s at %s (%v ago)\n%s\n", obs.Station, obs.ObservationTime.UTC().Format(time.RFC3339), age, raw)
+	decoded, err := metar.Decode(raw)
+	if err != nil {
+		_, err := fmt.Fprintf(w, "  couldn't be decoded: %v\n\n", err)
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	describeMETAR(tw, decoded, u)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
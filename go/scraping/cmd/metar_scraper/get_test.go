@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetNoContent(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer api.Close()
+	latest, err := latestFromAPI(context.Background(), api.URL, []string{"XXXX"})
+	if err != nil || len(latest) != 0 {
+		t.Errorf("got %v, %v; want no observations and no error", latest, err)
+	}
+	err = runGet(context.Background(), &getFlags{apiURL: api.URL, stations: []string{"XXXX"}})
+	if err == nil || err.Error() != "no observations for [XXXX]" {
+		t.Errorf("runGet: got %v, want no observations for [XXXX]", err)
+	}
+}
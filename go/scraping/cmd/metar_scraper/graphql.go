@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	graphql "github.com/graph-gophers/graphql-go"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// graphqlSchema is served on /graphql with -graphql.  Values a report
+// doesn't have are null.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	"""
+	Observations in [from, to), by station and then oldest first.  from and
+	to are dates like 2006-01-02 or RFC 3339 times; to defaults to now and
+	from to a day before to.  Without stations, every station's are returned.
+	At most limit are returned, and never more than the server's -limit.
+	"""
+	observations(stations: [String!], from: String, to: String, limit: Int): [Observation!]!
+	"The newest observation of each of stations that has one, by station."
+	latest(stations: [String!]!): [Observation!]!
+}
+
+type Observation {
+	station: String!
+	"The observation time, in RFC 3339."
+	time: String!
+	rawText: String!
+	"METAR or SPECI."
+	metarType: String
+	latitude: Float
+	longitude: Float
+	elevationM: Float
+	tempC: Float
+	dewpointC: Float
+	wind: Wind
+	visibilityMi: Float
+	altimInHg: Float
+	"VFR, MVFR, IFR or LIFR, as AWC gives it or as computed from rawText."
+	flightCategory: String
+	"The lowest broken or overcast layer or vertical visibility, from rawText."
+	ceilingFt: Int
+	"Any column of the metars cache file, like wx_string, by its name there."
+	field(name: String!): String
+}
+
+type Wind {
+	"Null for a variable wind, and 0 for a calm one."
+	directionDegrees: Int
+	variable: Boolean!
+	speedKt: Int!
+	gustKt: Int
+}
+`
+
+// newGraphQLSchema returns the schema answered from s.  Queries are limited
+// in depth as well as in observations, since the resolvers are cheap but the
+// nesting isn't.
+func newGraphQLSchema(s *server) (*graphql.Schema, error) {
+	return graphql.ParseSchema(graphqlSchema, &graphqlResolver{s}, graphql.UseStringDescriptions(), graphql.UseFieldResolvers(), graphql.MaxDepth(5))
+}
+
+type graphqlResolver struct {
+	s *server
+}
+
+func (r *graphqlResolver) Observations(ctx context.Context, args struct {
+	Stations *[]string
+	From     *string
+	To       *string
+	Limit    *int32
+}) ([]*graphqlObservation, error) {
+	to := time.Now()
+	if args.To != nil {
+		var err error
+		if to, err = parseTimeFlag(*args.To); err != nil {
+			return nil, fmt.Errorf("bad to: %w", err)
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if args.From != nil {
+		var err error
+		if from, err = parseTimeFlag(*args.From); err != nil {
+			return nil, fmt.Errorf("bad from: %w", err)
+		}
+	}
+	limit := r.s.limit
+	if args.Limit != nil {
+		if *args.Limit <= 0 {
+			return nil, fmt.Errorf("bad limit %d", *args.Limit)
+		}
+		limit = min(int(*args.Limit), r.s.limit)
+	}
+	query := r.s.selectMetars().
+		Where(sq.GtOrEq{"observation_time": from}).
+		Where(sq.Lt{"observation_time": to}).
+		OrderBy("station", "observation_time").
+		Limit(uint64(limit))
+	if args.Stations != nil {
+		query = query.Where(sq.Eq{"station": upperAll(*args.Stations)})
+	}
+	rows, err := query.RunWith(r.s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	observations := []*graphqlObservation{}
+	for rows.Next() {
+		obs := &metarcsv.Observation{}
+		if err := rows.Scan(&obs.Station, &obs.ObservationTime, r.s.dialect.ScanArray(&obs.Parts)); err != nil {
+			return nil, err
+		}
+		observations = append(observations, &graphqlObservation{obs: obs})
+	}
+	return observations, rows.Err()
+}
+
+func (r *graphqlResolver) Latest(ctx context.Context, args struct{ Stations []string }) ([]*graphqlObservation, error) {
+	if len(args.Stations) > r.s.limit {
+		return nil, fmt.Errorf("more than %d stations", r.s.limit)
+	}
+	newest, err := newestObservations(ctx, r.s.db, r.s.dialect, upperAll(args.Stations))
+	if err != nil {
+		return nil, err
+	}
+	observations := []*graphqlObservation{}
+	for _, obs := range newest {
+		observations = append(observations, &graphqlObservation{obs: obs})
+	}
+	sort.Slice(observations, func(i, j int) bool {
+		return observations[i].obs.Station < observations[j].obs.Station
+	})
+	return observations, nil
+}
+
+func upperAll(stations []string) []string {
+	upper := make([]string, len(stations))
+	for i, station := range stations {
+		upper[i] = strings.ToUpper(station)
+	}
+	return upper
+}
+
+// graphqlObservation resolves an Observation.  Its raw text is only decoded
+// if a field that needs it is asked for.
+type graphqlObservation struct {
+	obs     *metarcsv.Observation
+	once    sync.Once
+	decoded *metar.METAR
+}
+
+func (o *graphqlObservation) Station() string { return o.obs.Station }
+
+func (o *graphqlObservation) Time() string {
+	return o.obs.ObservationTime.UTC().Format(time.RFC3339)
+}
+
+func (o *graphqlObservation) RawText() string { return o.obs.Field(metarcsv.ColRawText) }
+
+func (o *graphqlObservation) MetarType() *string     { return o.text(metarcsv.ColMetarType) }
+func (o *graphqlObservation) Latitude() *float64     { return o.number(metarcsv.ColLatitude) }
+func (o *graphqlObservation) Longitude() *float64    { return o.number(metarcsv.ColLongitude) }
+func (o *graphqlObservation) ElevationM() *float64   { return o.number(metarcsv.ColElevationM) }
+func (o *graphqlObservation) TempC() *float64        { return o.number(metarcsv.ColTempC) }
+func (o *graphqlObservation) DewpointC() *float64    { return o.number(metarcsv.ColDewpointC) }
+func (o *graphqlObservation) VisibilityMi() *float64 { return o.number(metarcsv.ColVisibilityMi) }
+func (o *graphqlObservation) AltimInHg() *float64    { return o.number(metarcsv.ColAltimInHg) }
+
+func (o *graphqlObservation) Wind() *graphqlWind {
+	speed := o.integer(metarcsv.ColWindSpeedKt)
+	if speed == nil {
+		return nil
+	}
+	return &graphqlWind{
+		DirectionDegrees: o.integer(metarcsv.ColWindDirDegrees),
+		Variable:         o.obs.Field(metarcsv.ColWindDirDegrees) == "VRB",
+		SpeedKt:          *speed,
+		GustKt:           o.integer(metarcsv.ColWindGustKt),
+	}
+}
+
+func (o *graphqlObservation) FlightCategory() *string {
+	if category := o.text(metarcsv.ColumnIndex["flight_category"]); category != nil {
+		return category
+	}
+	if category := o.obs.FlightCategory(); category != "" {
+		return &category
+	}
+	return nil
+}
+
+func (o *graphqlObservation) CeilingFt() *int32 {
+	o.once.Do(func() {
+		o.decoded, _ = metar.Decode(o.RawText())
+	})
+	if o.decoded == nil {
+		return nil
+	}
+	ceiling := o.decoded.Conditions.CeilingFt()
+	if ceiling == nil {
+		return nil
+	}
+	ft := int32(*ceiling)
+	return &ft
+}
+
+func (o *graphqlObservation) Field(args struct{ Name string }) (*string, error) {
+	i, ok := metarcsv.ColumnIndex[args.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown column %q", args.Name)
+	}
+	return o.text(i), nil
+}
+
+// text returns the column at index i, or nil if it's empty.
+func (o *graphqlObservation) text(i int) *string {
+	value := o.obs.Field(i)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (o *graphqlObservation) number(i int) *float64 {
+	value, ok := o.obs.Number(i)
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+func (o *graphqlObservation) integer(i int) *int32 {
+	value, ok := o.obs.Number(i)
+	if !ok {
+		return nil
+	}
+	n := int32(value)
+	return &n
+}
+
+type graphqlWind struct {
+	DirectionDegrees *int32
+	Variable         bool
+	SpeedKt          int32
+	GustKt           *int32
+}
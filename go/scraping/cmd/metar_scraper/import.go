@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+)
+
+type importFlags struct {
+	dbURL       string
+	driver      string
+	inputFormat string
+	batchSize   int
+	skipBadRows bool
+	latest      bool
+	paths       []string
+	logging     scraping.Logging
+}
+
+func (f *importFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.driver, "driver", "postgres", "database driver for -dburl, as for the scraper")
+	fs.StringVar(&f.inputFormat, "input-format", "auto", "csv, json, xml, or auto to tell each file's format by its content")
+	fs.IntVar(&f.batchSize, "batch-size", 500, "rows per INSERT statement")
+	fs.BoolVar(&f.skipBadRows, "skip-bad-rows", false, "if set, log and skip records that fail to parse instead of failing the file")
+	fs.BoolVar(&f.latest, "latest", false, "if set, also keep metars_latest up to date")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: metar_scraper import [flags] file-or-directory...")
+		fs.PrintDefaults()
+	}
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+	f.paths = fs.Args()
+}
+
+// importFiles returns the files under paths, walking directories, in the
+// order given and then lexically.
+func importFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.Type().IsRegular() {
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// importFile ingests one file, .gz and .zst included, in its own
+// transaction.
+func importFile(ctx context.Context, out store.Store, name string, opts *ingestOptions) (*runStats, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r, err := awc.Decompress(name, file)
+	if err != nil {
+		return nil, err
+	}
+	stats := &runStats{}
+	return stats, readToDB(ctx, out, r, opts, stats)
+}
+
+func runImport(ctx context.Context, flags *importFlags) error {
+	if len(flags.paths) == 0 {
+		return errors.New("no files or directories given")
+	}
+	files, err := importFiles(flags.paths)
+	if err != nil {
+		return fmt.Errorf("finding files: %w", err)
+	}
+	db, d, err := store.Open(flags.dbURL, flags.driver)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	opts := &ingestOptions{
+		inputFormat: flags.inputFormat,
+		skipBadRows: flags.skipBadRows,
+		maxBadRows:  math.MaxInt,
+		rows:        store.Options{Latest: flags.latest, BatchSize: flags.batchSize},
+	}
+	if err := store.CreateSchema(ctx, db, d, opts.rows.ConflictKey()); err != nil {
+		return err
+	}
+	out := store.New(db, d, &opts.rows)
+	total := 0
+	for i, name := range files {
+		stats, err := importFile(ctx, out, name, opts)
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", name, err)
+		}
+		total += stats.rowsWritten
+		slog.Info("imported file", "file", name, "index", i+1, "files", len(files), "written", stats.rowsWritten, "invalid", stats.rowsInvalid, "bad", stats.rowsBad)
+	}
+	slog.Info("import finished", "written", total, "files", len(files))
+	return nil
+}
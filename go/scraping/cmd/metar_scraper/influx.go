@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// influxMeasurement is the measurement observations are written under.
+const influxMeasurement = "metar"
+
+// influxBatchLines is how many lines go in each write request, well under
+// the size InfluxDB suggests.
+const influxBatchLines = 5000
+
+// influxStore writes observations as InfluxDB line protocol, posted to url
+// and/or appended to file when a file commits.  Points are keyed by station
+// and time like metars, so re-sending a file overwrites rather than
+// duplicates.
+type influxStore struct {
+	// url is the write endpoint with its query, e.g.
+	// http://localhost:8086/api/v2/write?org=o&bucket=b&precision=s.
+	url   string
+	token string
+	file  string
+	lines []string
+}
+
+var (
+	influxTagEscaper    = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	influxStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// influxLine returns obs as a line of line protocol at precision=s: the
+// station, metar type and flight category as tags, and the typed columns,
+// raw text and schema_version as fields.
+func influxLine(obs *metarcsv.Observation) (string, error) {
+	typed := map[string]interface{}{}
+	if err := store.SetTypedColumns(typed, obs); err != nil {
+		return "", err
+	}
+	var line strings.Builder
+	line.WriteString(influxMeasurement)
+	line.WriteString(",station=" + influxTagEscaper.Replace(obs.Station))
+	for _, tag := range []struct {
+		name  string
+		index int
+	}{{"metar_type", metarcsv.ColMetarType}, {"flight_category", metarcsv.ColumnIndex["flight_category"]}} {
+		if value := obs.Field(tag.index); value != "" {
+			line.WriteString("," + tag.name + "=" + influxTagEscaper.Replace(value))
+		}
+	}
+	fields := []string{`raw_text="` + influxStringEscaper.Replace(obs.Field(metarcsv.ColRawText)) + `"`, "schema_version=" + strconv.Itoa(outputSchemaVersion) + "i"}
+	for name, value := range typed {
+		switch v := value.(type) {
+		case float64:
+			fields = append(fields, name+"="+strconv.FormatFloat(v, 'f', -1, 64))
+		case int:
+			fields = append(fields, name+"="+strconv.Itoa(v)+"i")
+		case bool:
+			fields = append(fields, name+"="+strconv.FormatBool(v))
+		case string:
+			fields = append(fields, name+`="`+influxStringEscaper.Replace(v)+`"`)
+		}
+	}
+	// map order is random, and sorted fields keep the output diffable
+	sort.Strings(fields[1:])
+	line.WriteString(" " + strings.Join(fields, ","))
+	line.WriteString(" " + strconv.FormatInt(obs.ObservationTime.Unix(), 10))
+	return line.String(), nil
+}
+
+func (s *influxStore) Begin(ctx context.Context) error {
+	s.lines = nil
+	return nil
+}
+
+func (s *influxStore) Write(obs *metarcsv.Observation) error {
+	line, err := influxLine(obs)
+	if err != nil {
+		return err
+	}
+	s.lines = append(s.lines, line)
+	return nil
+}
+
+func (s *influxStore) Commit() error {
+	lines := s.lines
+	s.lines = nil
+	if s.file != "" {
+		if err := appendLines(s.file, lines); err != nil {
+			return fmt.Errorf("writing %s: %w", s.file, err)
+		}
+	}
+	if s.url == "" {
+		return nil
+	}
+	for start := 0; start < len(lines); start += influxBatchLines {
+		end := min(start+influxBatchLines, len(lines))
+		if err := s.post(lines[start:end]); err != nil {
+			return fmt.Errorf("writing to influx: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *influxStore) post(lines []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	body := strings.Join(lines, "\n") + "\n"
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+func (s *influxStore) Rollback() {
+	s.lines = nil
+}
+
+// appendLines appends lines to fname, creating it if needed.
+func appendLines(fname string, lines []string) error {
+	file, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(file, line+"\n"); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
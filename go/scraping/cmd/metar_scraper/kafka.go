@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// kafkaStore publishes observations to a Kafka topic when a file commits,
+// keyed by station so that each station's observations stay in order on one
+// partition.  Values are JSON, as the serve command returns them.
+type kafkaStore struct {
+	brokers  []string
+	topic    string
+	messages []kafka.Message
+}
+
+func (s *kafkaStore) Begin(ctx context.Context) error {
+	s.messages = nil
+	return nil
+}
+
+func (s *kafkaStore) Write(obs *metarcsv.Observation) error {
+	value, err := json.Marshal(newAPIObservation(obs))
+	if err != nil {
+		return err
+	}
+	s.messages = append(s.messages, kafka.Message{Key: []byte(obs.Station), Value: value, Time: obs.ObservationTime})
+	return nil
+}
+
+func (s *kafkaStore) Commit() error {
+	messages := s.messages
+	s.messages = nil
+	if len(messages) == 0 {
+		return nil
+	}
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(s.brokers...),
+		Topic:        s.topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := w.WriteMessages(ctx, messages...); err != nil {
+		w.Close()
+		return fmt.Errorf("publishing to kafka: %w", err)
+	}
+	return w.Close()
+}
+
+func (s *kafkaStore) Rollback() {
+	s.messages = nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"mattdee123.com/aviationweather/store"
+)
+
+// scrapeLockKey is the postgres advisory lock key, and scrapeLockName the
+// mysql lock name, that metar_scraper instances hold while they run.
+const (
+	scrapeLockKey  = 0x6d65746172 // "metar"
+	scrapeLockName = "metar_scraper"
+)
+
+// errLockHeld is returned by acquireLock when another instance has the lock
+// and it was asked not to wait.
+var errLockHeld = errors.New("another scraper holds the lock")
+
+// scrapeLock is a session-level lock, held on its own connection so that it
+// isn't released, or left held, by the pool handing the connection out.
+type scrapeLock struct {
+	conn   *sql.Conn
+	unlock string
+	arg    interface{}
+}
+
+// acquireLock takes the scrape lock on db, waiting for it if wait is set.
+// It returns nil with no error for dialects without locks, where concurrent
+// runs aren't an issue: SQLite only has one writer, and ClickHouse inserts
+// don't conflict.
+func acquireLock(ctx context.Context, db *sql.DB, d *store.Dialect, wait bool) (*scrapeLock, error) {
+	var lockSQL, unlock string
+	var arg interface{}
+	switch d {
+	case store.Postgres:
+		lockSQL, unlock, arg = "SELECT pg_try_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", int64(scrapeLockKey)
+		if wait {
+			// pg_advisory_lock returns void, which is NULL
+			lockSQL = "SELECT pg_advisory_lock($1) IS NULL"
+		}
+	case store.MySQL:
+		lockSQL, unlock, arg = "SELECT GET_LOCK(?, 0) = 1", "SELECT RELEASE_LOCK(?)", scrapeLockName
+		if wait {
+			// mysql treats a negative timeout as forever
+			lockSQL = "SELECT GET_LOCK(?, -1) = 1"
+		}
+	default:
+		return nil, nil
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var got bool
+	if err := conn.QueryRowContext(ctx, lockSQL, arg).Scan(&got); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("taking lock: %w", err)
+	}
+	if !got {
+		conn.Close()
+		return nil, errLockHeld
+	}
+	return &scrapeLock{conn, unlock, arg}, nil
+}
+
+// release releases l, which may be nil.
+func (l *scrapeLock) release() {
+	if l == nil {
+		return
+	}
+	if _, err := l.conn.ExecContext(context.Background(), l.unlock, l.arg); err != nil {
+		slog.Warn("releasing lock", "err", err)
+		// don't put a connection that might still hold the lock back in the
+		// pool
+		l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	l.conn.Close()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// testObservation returns an observation of station at t with raw text raw,
+// and any other columns in fields.
+func testObservation(t *testing.T, station string, at time.Time, raw string, fields map[int]string) *metarcsv.Observation {
+	t.Helper()
+	parts := make([]string, len(metarcsv.Columns))
+	parts[metarcsv.ColRawText] = raw
+	parts[metarcsv.ColStation] = station
+	parts[metarcsv.ColObservationTime] = at.UTC().Format(time.RFC3339)
+	for i, value := range fields {
+		parts[i] = value
+	}
+	obs, err := metarcsv.NewObservation(parts, &metarcsv.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obs
+}
+
+// testDB returns a new SQLite database with the scrapers' schema, keyed on
+// station and observation_time.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(store.SQLite.Driver, filepath.Join(t.TempDir(), "metars.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := store.CreateSchema(context.Background(), db, store.SQLite, (&store.Options{}).ConflictKey()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// writeAll writes observations to s in one transaction.
+func writeAll(t *testing.T, s store.Store, observations ...*metarcsv.Observation) {
+	t.Helper()
+	if err := s.Begin(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Rollback()
+	for _, obs := range observations {
+		if err := s.Write(obs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAliasFlag(t *testing.T) {
+	aliases := aliasFlag{}
+	if err := aliases.Set("PAFA=PAFB"); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{"PAFA", "PAFA=", "=PAFB", "A=B=C"} {
+		if err := aliases.Set(bad); err == nil {
+			t.Errorf("%q: got no error", bad)
+		}
+	}
+	fname := filepath.Join(t.TempDir(), "aliases")
+	if err := os.WriteFile(fname, []byte("# renamed\nKOLD=KNEW\n\n  PHTO=PHIT  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := readAliases(aliases, fname); err != nil {
+		t.Fatal(err)
+	}
+	want := aliasFlag{"PAFA": "PAFB", "KOLD": "KNEW", "PHTO": "PHIT"}
+	if !maps.Equal(aliases, want) {
+		t.Errorf("aliases = %v, want %v", aliases, want)
+	}
+}
+
+func TestReadToDBStrictness(t *testing.T) {
+	at := time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC)
+	file := testFile(
+		testObservation(t, "KBOS", at, "KBOS 141154Z 27010KT 10SM BKN030 12/05 A2992", nil),
+		// a wind without its unit
+		testObservation(t, "KJFK", at, "KJFK 141154Z 27010 10SM BKN030 12/05 A2992", nil),
+	)
+	for _, test := range []struct {
+		strictness        metar.Strictness
+		written, rejected int
+	}{
+		{metar.Lenient, 2, 0},
+		{metar.BestEffort, 2, 0},
+		{metar.Strict, 1, 1},
+	} {
+		db := testDB(t)
+		opts := &ingestOptions{inputFormat: "csv", strictness: test.strictness}
+		stats := &runStats{}
+		if err := readToDB(context.Background(), store.New(db, store.SQLite, nil), strings.NewReader(file), opts, stats); err != nil {
+			t.Fatal(err)
+		}
+		if stats.rowsWritten != test.written || stats.rowsRejected != test.rejected {
+			t.Errorf("%s: wrote %d and rejected %d, want %d and %d", test.strictness, stats.rowsWritten, stats.rowsRejected, test.written, test.rejected)
+		}
+	}
+}
+
+func TestClickHouseUnsupported(t *testing.T) {
+	if err := clickhouseUnsupported(&Flags{keyMetarType: true, batchSize: 100}); err != nil {
+		t.Errorf("key and batch options: %v", err)
+	}
+	err := clickhouseUnsupported(&Flags{remarks: true, latest: true, keyMetarType: true})
+	if err == nil || err.Error() != "-latest, -remarks isn't supported for clickhouse" {
+		t.Errorf("got %v, want -latest and -remarks unsupported", err)
+	}
+}
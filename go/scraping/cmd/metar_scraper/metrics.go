@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// runStats counts what happened during a single run.
+type runStats struct {
+	start       time.Time
+	end         time.Time
+	success     bool
+	rowsWritten int
+	rowsInvalid int
+	// rowsSampledOut are valid rows dropped by -sample-interval.
+	rowsSampledOut int
+	// rowsOutOfRegion are valid rows dropped by the region filter.
+	rowsOutOfRegion int
+	// rowsSeen are valid rows skipped by -seen-filter-size as already
+	// written.
+	rowsSeen int
+	// rowsRejected are rows dropped by -strict for violating a rule.
+	rowsRejected int
+	// rowsBad are lines skipped by -skip-bad-rows for failing to parse.
+	rowsBad      int
+	linesScanned int
+	// downloadDuration is how long the input took to open: the whole
+	// download with -filename, or until the response started when streaming.
+	downloadDuration time.Duration
+	// lastSuccess is when the last successful run, this one or an earlier
+	// one in the same daemon, finished.  It's zero if there hasn't been one.
+	lastSuccess time.Time
+	// stations are those with a row written, for the summary.
+	stations map[string]bool
+	// ruleViolations counts validation rule violations by rule name.
+	ruleViolations map[string]int
+	// expectedMissing counts observations missing each expected field.
+	expectedMissing map[string]int
+	// coordinateMismatches counts observations far from their station, with
+	// -validate-coordinates.
+	coordinateMismatches int
+}
+
+// gauge is a single unlabeled metric.
+type gauge struct {
+	name, help string
+	value      float64
+}
+
+// rowsSkipped counts the lines that weren't written, whatever the reason.
+func (s *runStats) rowsSkipped() int {
+	return s.rowsInvalid + s.rowsBad + s.rowsRejected + s.rowsSampledOut + s.rowsOutOfRegion + s.rowsSeen
+}
+
+// wrote counts a row written for obs.
+func (s *runStats) wrote(obs *metarcsv.Observation) {
+	s.rowsWritten++
+	if s.stations == nil {
+		s.stations = map[string]bool{}
+	}
+	s.stations[obs.Station] = true
+}
+
+// logArgs are the run's attributes for the summary log line.
+func (s *runStats) logArgs() []any {
+	return []any{
+		"success", s.success,
+		"duration", s.end.Sub(s.start).Round(time.Millisecond),
+		"download_duration", s.downloadDuration.Round(time.Millisecond),
+		"lines", s.linesScanned,
+		"written", s.rowsWritten,
+		"stations", len(s.stations),
+		"skipped", s.rowsSkipped(),
+		"invalid", s.rowsInvalid,
+		"bad", s.rowsBad,
+		"rejected", s.rowsRejected,
+		"sampled_out", s.rowsSampledOut,
+		"out_of_region", s.rowsOutOfRegion,
+		"seen", s.rowsSeen,
+		"coordinate_mismatches", s.coordinateMismatches,
+	}
+}
+
+// gauges are stats' unlabeled metrics.
+func (s *runStats) gauges() []gauge {
+	success := 0
+	if s.success {
+		success = 1
+	}
+	metrics := []gauge{
+		{"metar_scraper_last_run_success", "Whether the last run succeeded.", float64(success)},
+		{"metar_scraper_last_run_timestamp_seconds", "When the last run finished.", float64(s.end.UnixNano()) / 1e9},
+		{"metar_scraper_last_run_duration_seconds", "How long the last run took.", s.end.Sub(s.start).Seconds()},
+		{"metar_scraper_last_run_lines_scanned", "Data lines read in the last run.", float64(s.linesScanned)},
+		{"metar_scraper_last_run_rows_written", "Rows upserted in the last run.", float64(s.rowsWritten)},
+		{"metar_scraper_last_run_rows_invalid", "Lines skipped as invalid in the last run.", float64(s.rowsInvalid)},
+		{"metar_scraper_last_run_rows_sampled_out", "Rows dropped by sampling in the last run.", float64(s.rowsSampledOut)},
+		{"metar_scraper_last_run_rows_out_of_region", "Rows outside the -bbox or station filter in the last run.", float64(s.rowsOutOfRegion)},
+		{"metar_scraper_last_run_rows_seen", "Rows skipped as already written this session by -seen-filter-size in the last run.", float64(s.rowsSeen)},
+		{"metar_scraper_last_run_rows_rejected", "Rows rejected for violating a validation rule in the last run.", float64(s.rowsRejected)},
+		{"metar_scraper_last_run_rows_bad", "Lines skipped for failing to parse in the last run.", float64(s.rowsBad)},
+		{"metar_scraper_last_run_coordinate_mismatches", "Rows far from their station's coordinates in the last run, with -validate-coordinates.", float64(s.coordinateMismatches)},
+		{"metar_scraper_last_run_rows_skipped", "Lines not written for any reason in the last run.", float64(s.rowsSkipped())},
+		{"metar_scraper_last_run_download_duration_seconds", "How long the last run's download took to open.", s.downloadDuration.Seconds()},
+	}
+	if !s.lastSuccess.IsZero() {
+		metrics = append(metrics, gauge{"metar_scraper_last_success_timestamp_seconds", "When the last successful run finished.", float64(s.lastSuccess.UnixNano()) / 1e9})
+	}
+	return metrics
+}
+
+// labeledGauge is a metric with one sample per key of values, as the label
+// named label.
+type labeledGauge struct {
+	name, help, label string
+	values            map[string]int
+}
+
+// labeledGauges are stats' labeled metrics.
+func (s *runStats) labeledGauges() []labeledGauge {
+	return []labeledGauge{
+		{"metar_scraper_last_run_rule_violations", "Validation rule violations in the last run.", "rule", s.ruleViolations},
+		{"metar_scraper_last_run_expected_field_missing", "Rows missing each expected field in the last run.", "field", s.expectedMissing},
+	}
+}
+
+// writeMetrics writes stats in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, stats *runStats) error {
+	for _, m := range stats.gauges() {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", m.name, m.help, m.name, m.name, strconv.FormatFloat(m.value, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	for _, m := range stats.labeledGauges() {
+		if err := writeLabeledMetric(w, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLabeledMetric writes m, or nothing if it has no values.
+func writeLabeledMetric(w io.Writer, m labeledGauge) error {
+	if len(m.values) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name); err != nil {
+		return err
+	}
+	var keys []string
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(w, "%s{%s=%q} %d\n", m.name, m.label, key, m.values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registry returns a Prometheus registry with stats' metrics, as
+// writeMetrics writes them.
+func registry(stats *runStats) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	for _, m := range stats.gauges() {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: m.name, Help: m.help})
+		g.Set(m.value)
+		reg.MustRegister(g)
+	}
+	for _, m := range stats.labeledGauges() {
+		if len(m.values) == 0 {
+			continue
+		}
+		vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: m.name, Help: m.help}, []string{m.label})
+		for key, value := range m.values {
+			vec.WithLabelValues(key).Set(float64(value))
+		}
+		reg.MustRegister(vec)
+	}
+	return reg
+}
+
+// writeMetricsTextfile writes stats to fname for the node_exporter textfile
+// collector.  It writes to a temporary file in the same directory and renames
+// it into place so the collector never sees a partial file.
+func writeMetricsTextfile(fname string, stats *runStats) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fname), "."+filepath.Base(fname)+".*")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeMetrics(tmp, stats); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing metrics: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+	// CreateTemp uses 0600, which the collector may not be able to read
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("setting permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), fname); err != nil {
+		return fmt.Errorf("renaming into place: %w", err)
+	}
+	return nil
+}
+
+// pushMetrics replaces the metrics for job on the Prometheus Pushgateway at
+// gatewayURL with stats.
+func pushMetrics(gatewayURL, job string, stats *runStats) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return push.New(gatewayURL, job).Client(client).Gatherer(registry(stats)).Push()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushMetrics(t *testing.T) {
+	var method, path string
+	status := http.StatusOK
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(status)
+		w.Write([]byte("push refused"))
+	}))
+	defer gateway.Close()
+	stats := &runStats{
+		start:          time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC),
+		end:            time.Date(2026, 10, 14, 11, 54, 3, 0, time.UTC),
+		success:        true,
+		rowsWritten:    2,
+		ruleViolations: map[string]int{"temperature": 1},
+	}
+	if err := pushMetrics(gateway.URL, "metar_scraper", stats); err != nil {
+		t.Fatal(err)
+	}
+	// the whole group is replaced
+	if method != http.MethodPut || path != "/metrics/job/metar_scraper" {
+		t.Errorf("pushed with %s %s", method, path)
+	}
+
+	status = http.StatusBadRequest
+	if err := pushMetrics(gateway.URL, "metar_scraper", stats); err == nil || !strings.Contains(err.Error(), "push refused") {
+		t.Errorf("refused push: got %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	stats := &runStats{ruleViolations: map[string]int{"temperature": 1, "wind": 2}}
+	families, err := registry(stats).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same metrics as writeMetrics, but for the empty expected fields
+	var text strings.Builder
+	if err := writeMetrics(&text, stats); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Count(text.String(), "# TYPE "); len(families) != want {
+		t.Errorf("gathered %d metrics, want %d", len(families), want)
+	}
+	for _, family := range families {
+		if family.GetName() == "metar_scraper_last_run_rule_violations" && len(family.GetMetric()) != 2 {
+			t.Errorf("rule violations have %d samples, want 2", len(family.GetMetric()))
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"mattdee123.com/aviationweather/scraping"
+)
+
+type migrateFlags struct {
+	dbURL    string
+	dir      string
+	baseline string
+	dryRun   bool
+	logging  scraping.Logging
+}
+
+func (f *migrateFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.dir, "dir", "sql", "directory holding the numbered NNN.sql migrations")
+	fs.StringVar(&f.baseline, "baseline", "", "if set, record migrations up to and including this version (e.g. 008) as applied without running them, for databases set up by hand")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "if set, only list the migrations that would run")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+// migrationFile matches the versioned migrations.  Others in the directory,
+// like metar_type_key.sql, are optional and have to be run by hand.
+var migrationFile = regexp.MustCompile(`^(\d{3})\.sql$`)
+
+const migrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version text primary key,
+    applied_at timestamptz NOT NULL DEFAULT now()
+)`
+
+// runMigrate applies, in order, the migrations in flags.dir that aren't yet
+// recorded in schema_migrations.  Each one runs in its own transaction along
+// with recording it, so a failure leaves the database at the last good
+// version.
+func runMigrate(ctx context.Context, flags *migrateFlags) error {
+	versions, err := migrationVersions(flags.dir)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("postgres", flags.dbURL)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, migrationsTable); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	applied := map[string]bool{}
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return err
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if applied[version] {
+			continue
+		}
+		baseline := flags.baseline != "" && version <= flags.baseline
+		if flags.dryRun {
+			if baseline {
+				slog.Info("dry run: would record as applied", "version", version)
+			} else {
+				slog.Info("dry run: would apply", "version", version)
+			}
+			continue
+		}
+		if err := applyMigration(ctx, db, flags.dir, version, baseline); err != nil {
+			return fmt.Errorf("migration %s: %w", version, err)
+		}
+		if baseline {
+			slog.Info("recorded as applied", "version", version)
+		} else {
+			slog.Info("applied", "version", version)
+		}
+	}
+	return nil
+}
+
+// migrationVersions returns the versions of the migrations in dir, in order.
+func migrationVersions(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+	var versions []string
+	for _, entry := range entries {
+		if m := migrationFile.FindStringSubmatch(entry.Name()); m != nil {
+			versions = append(versions, m[1])
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no migrations in %s", dir)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, dir, version string, recordOnly bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if !recordOnly {
+		text, err := os.ReadFile(filepath.Join(dir, version+".sql"))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(text)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return fmt.Errorf("recording: %w", err)
+	}
+	return tx.Commit()
+}
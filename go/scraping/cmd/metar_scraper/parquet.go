@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// parquetRow is an exported observation in Parquet: the typed columns as
+// with -typed-columns, plus the decoded raw text as JSON.
+type parquetRow struct {
+	Station             string              `parquet:"station"`
+	ObservationTime     time.Time           `parquet:"observation_time,timestamp"`
+	RawText             string              `parquet:"raw_text"`
+	MetarType           *string             `parquet:"metar_type"`
+	FlightCategory      *string             `parquet:"flight_category"`
+	Latitude            *float64            `parquet:"latitude"`
+	Longitude           *float64            `parquet:"longitude"`
+	TempC               *float64            `parquet:"temp_c"`
+	DewpointC           *float64            `parquet:"dewpoint_c"`
+	WindDirDegrees      *int64              `parquet:"wind_dir_degrees"`
+	WindSpeedKt         *int64              `parquet:"wind_speed_kt"`
+	WindGustKt          *int64              `parquet:"wind_gust_kt"`
+	VisibilityStatuteMi *float64            `parquet:"visibility_statute_mi"`
+	AltimInHg           *float64            `parquet:"altim_in_hg"`
+	SeaLevelPressureMb  *float64            `parquet:"sea_level_pressure_mb"`
+	Corrected           bool                `parquet:"corrected"`
+	Auto                bool                `parquet:"auto"`
+	WxString            *string             `parquet:"wx_string"`
+	VertVisFt           *int64              `parquet:"vert_vis_ft"`
+	ElevationM          *float64            `parquet:"elevation_m"`
+	CloudLayers         []parquetCloudLayer `parquet:"cloud_layers,list"`
+	// Decoded is the metar.METAR from raw_text as JSON, or null if it
+	// doesn't decode.
+	Decoded       *string `parquet:"decoded,json"`
+	SchemaVersion int32   `parquet:"schema_version"`
+}
+
+type parquetCloudLayer struct {
+	SkyCover       string `parquet:"sky_cover"`
+	CloudBaseFtAGL *int64 `parquet:"cloud_base_ft_agl"`
+}
+
+// newParquetRow converts obs using store.SetTypedColumns, so the values match
+// what -typed-columns stores.
+func newParquetRow(obs *metarcsv.Observation) (*parquetRow, error) {
+	typed := map[string]interface{}{}
+	if err := store.SetTypedColumns(typed, obs); err != nil {
+		return nil, err
+	}
+	number := func(name string) *float64 {
+		if value, ok := typed[name].(float64); ok {
+			return &value
+		}
+		return nil
+	}
+	integer := func(name string) *int64 {
+		if value, ok := typed[name].(int); ok {
+			v := int64(value)
+			return &v
+		}
+		return nil
+	}
+	text := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+	row := &parquetRow{
+		Station:             obs.Station,
+		ObservationTime:     obs.ObservationTime.UTC(),
+		RawText:             obs.Field(metarcsv.ColRawText),
+		MetarType:           text(obs.Field(metarcsv.ColMetarType)),
+		FlightCategory:      text(obs.Field(metarcsv.ColumnIndex["flight_category"])),
+		Latitude:            number("latitude"),
+		Longitude:           number("longitude"),
+		TempC:               number("temp_c"),
+		DewpointC:           number("dewpoint_c"),
+		WindDirDegrees:      integer("wind_dir_degrees"),
+		WindSpeedKt:         integer("wind_speed_kt"),
+		WindGustKt:          integer("wind_gust_kt"),
+		VisibilityStatuteMi: number("visibility_statute_mi"),
+		AltimInHg:           number("altim_in_hg"),
+		SeaLevelPressureMb:  number("sea_level_pressure_mb"),
+		Corrected:           typed["corrected"] == true,
+		Auto:                typed["auto"] == true,
+		WxString:            text(obs.Field(metarcsv.ColumnIndex["wx_string"])),
+		VertVisFt:           integer("vert_vis_ft"),
+		ElevationM:          number("elevation_m"),
+		SchemaVersion:       outputSchemaVersion,
+	}
+	if encoded, ok := typed["cloud_layers"].(string); ok {
+		var layers []store.CloudLayer
+		if err := json.Unmarshal([]byte(encoded), &layers); err != nil {
+			return nil, err
+		}
+		for _, layer := range layers {
+			l := parquetCloudLayer{SkyCover: layer.SkyCover}
+			if layer.CloudBaseFtAGL != nil {
+				base := int64(*layer.CloudBaseFtAGL)
+				l.CloudBaseFtAGL = &base
+			}
+			row.CloudLayers = append(row.CloudLayers, l)
+		}
+	}
+	if decoded, err := metar.Decode(row.RawText); err == nil {
+		encoded, err := json.Marshal(decoded)
+		if err != nil {
+			return nil, fmt.Errorf("encoding decoded: %w", err)
+		}
+		row.Decoded = text(string(encoded))
+	}
+	return row, nil
+}
+
+// parquetWriter writes a Parquet file per UTC day under dir, in Hive-style
+// date=2006-01-02 directories that DuckDB and Spark read as a partition
+// column.  Observations must come in time order, so each day's file is
+// finished before the next is started.
+type parquetWriter struct {
+	dir   string
+	day   string
+	file  *os.File
+	w     *parquet.GenericWriter[parquetRow]
+	files int
+}
+
+func (p *parquetWriter) write(obs *metarcsv.Observation) error {
+	row, err := newParquetRow(obs)
+	if err != nil {
+		return err
+	}
+	if day := row.ObservationTime.Format("2006-01-02"); day != p.day {
+		if err := p.flush(); err != nil {
+			return err
+		}
+		if err := p.open(day); err != nil {
+			return err
+		}
+	}
+	_, err = p.w.Write([]parquetRow{*row})
+	return err
+}
+
+func (p *parquetWriter) open(day string) error {
+	dir := filepath.Join(p.dir, "date="+day)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(filepath.Join(dir, "metars.parquet"))
+	if err != nil {
+		return err
+	}
+	p.day, p.file, p.w = day, file, parquet.NewGenericWriter[parquetRow](file)
+	p.files++
+	return nil
+}
+
+// flush finishes the current day's file, if there is one.
+func (p *parquetWriter) flush() error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.w.Close()
+	if closeErr := p.file.Close(); err == nil {
+		err = closeErr
+	}
+	p.file, p.w = nil, nil
+	return err
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	pq "github.com/lib/pq"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+)
+
+type pruneFlags struct {
+	dbURL      string
+	driver     string
+	olderThan  time.Duration
+	retain     retentionFlag
+	archive    string
+	partitions bool
+	dryRun     bool
+	logging    scraping.Logging
+}
+
+func (f *pruneFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.driver, "driver", "postgres", "database driver for -dburl: postgres or sqlite")
+	fs.DurationVar(&f.olderThan, "older-than", 0, "delete observations older than this, e.g. 8760h for a year")
+	f.retain = retentionFlag{}
+	fs.Var(f.retain, "retain", "CATEGORY=DURATION, like IFR=17520h, to delete observations of that flight category once older than this instead of -older-than; may be repeated.  without it every category is kept for -older-than")
+	fs.StringVar(&f.archive, "archive", "", "if set, first write the observations being deleted to this file as gzipped jsonl")
+	fs.BoolVar(&f.partitions, "partitions", false, "if set, detach monthly partitions (see sql/partition.sql) that are entirely older than the cutoff rather than deleting their rows; detached tables are left for you to drop.  with -retain, the cutoff is the earliest of them")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "if set, only count the rows that would be deleted")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+// retentionFlag is how long to keep each flight category that isn't kept
+// for -older-than.
+type retentionFlag map[string]time.Duration
+
+func (r retentionFlag) String() string {
+	var pairs []string
+	for category, d := range r {
+		pairs = append(pairs, category+"="+d.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (r retentionFlag) Set(value string) error {
+	category, duration, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected CATEGORY=DURATION, got %q", value)
+	}
+	category = strings.ToUpper(category)
+	if !slices.Contains(flightCategories, category) {
+		return fmt.Errorf("unknown flight category %q, want one of %s", category, strings.Join(flightCategories, ", "))
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("%s must be kept for a positive duration", category)
+	}
+	r[category] = d
+	return nil
+}
+
+// partitionPattern matches the names partitionName gives.
+var partitionPattern = regexp.MustCompile(`^metars_(\d{4})_(\d{2})$`)
+
+// categoryExpressions are, by dialect, the flight category of a metars row:
+// the flight_category column -flight-category stores, or else AWC's own
+// column in csv_parts.  A row with neither has no category and is kept for
+// -older-than.
+var categoryExpressions = map[*store.Dialect]string{
+	store.Postgres: fmt.Sprintf("COALESCE(flight_category, NULLIF(csv_parts[%d], ''))", metarcsv.ColumnIndex["flight_category"]+1),
+	store.SQLite:   fmt.Sprintf("COALESCE(flight_category, NULLIF(json_extract(csv_parts, '$[%d]'), ''))", metarcsv.ColumnIndex["flight_category"]),
+}
+
+// pruneWindow is the observations one DELETE removes: those of category
+// before cutoff or, for the window with no category, those of every
+// category without its own window.
+type pruneWindow struct {
+	category string
+	cutoff   time.Time
+}
+
+// pruneWindows returns the windows for flags as of now, with each -retain
+// category's first, in order, and the default last.
+func pruneWindows(flags *pruneFlags, now time.Time) []pruneWindow {
+	var windows []pruneWindow
+	for _, category := range flightCategories {
+		if d, ok := flags.retain[category]; ok {
+			windows = append(windows, pruneWindow{category, now.Add(-d).UTC()})
+		}
+	}
+	return append(windows, pruneWindow{"", now.Add(-flags.olderThan).UTC()})
+}
+
+// where matches the window's rows, where category is the dialect's
+// categoryExpressions and others the categories with their own window.
+func (w pruneWindow) where(category string, others []string) sq.Sqlizer {
+	before := sq.Lt{"observation_time": w.cutoff}
+	switch {
+	case w.category != "":
+		return sq.And{before, sq.Eq{category: w.category}}
+	case len(others) > 0:
+		return sq.And{before, sq.Or{sq.Eq{category: nil}, sq.NotEq{category: others}}}
+	}
+	return before
+}
+
+func (w pruneWindow) String() string {
+	if w.category == "" {
+		return "default"
+	}
+	return w.category
+}
+
+func runPrune(ctx context.Context, flags *pruneFlags) error {
+	if flags.olderThan <= 0 {
+		return fmt.Errorf("-older-than is required")
+	}
+	db, d, err := store.Open(flags.dbURL, flags.driver)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	count, err := pruneMetars(ctx, db, d, flags, time.Now())
+	if err != nil {
+		return err
+	}
+	if flags.dryRun {
+		slog.Info("dry run: would delete observations", "rows", count)
+		return nil
+	}
+	slog.Info("deleted observations", "rows", count)
+	return nil
+}
+
+// pruneMetars deletes the metars rows older than flags' cutoffs as of now,
+// or with flags.dryRun only counts them, and returns how many there are.
+// Each flight category given its own retention with -retain is deleted by
+// a DELETE of its own, and the rest by one for -older-than.  The archive,
+// the partitions detached and the deletes all happen in one repeatable read
+// transaction, so exactly the archived rows are removed.  metars_latest is
+// left alone.
+func pruneMetars(ctx context.Context, db *sql.DB, d *store.Dialect, flags *pruneFlags, now time.Time) (int64, error) {
+	category, ok := categoryExpressions[d]
+	if !ok {
+		return 0, fmt.Errorf("prune doesn't support %s", d.Driver)
+	}
+	if flags.partitions && d != store.Postgres {
+		return 0, fmt.Errorf("-partitions needs postgres")
+	}
+	windows := pruneWindows(flags, now)
+	var others []string
+	for _, w := range windows[:len(windows)-1] {
+		others = append(others, w.category)
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return 0, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if flags.dryRun {
+		var total int64
+		for _, w := range windows {
+			var count int64
+			err := d.Builder.Select("count(*)").From("metars").Where(w.where(category, others)).
+				RunWith(tx).QueryRowContext(ctx).Scan(&count)
+			if err != nil {
+				return 0, fmt.Errorf("counting %s: %w", w, err)
+			}
+			slog.Info("dry run: would delete observations", "category", w, "rows", count, "before", w.cutoff)
+			total += count
+		}
+		return total, nil
+	}
+	if flags.archive != "" {
+		var all sq.Or
+		for _, w := range windows {
+			all = append(all, w.where(category, others))
+		}
+		if err := archive(ctx, tx, d, all, flags.archive); err != nil {
+			return 0, fmt.Errorf("archiving: %w", err)
+		}
+	}
+	if flags.partitions {
+		// a partition holds every category, so only those past all the
+		// cutoffs can go
+		earliest := windows[0].cutoff
+		for _, w := range windows {
+			if w.cutoff.Before(earliest) {
+				earliest = w.cutoff
+			}
+		}
+		if err := detachPartitionsBefore(ctx, tx, earliest); err != nil {
+			return 0, err
+		}
+	}
+	var total int64
+	for _, w := range windows {
+		result, err := d.Builder.Delete("metars").Where(w.where(category, others)).RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("deleting %s: %w", w, err)
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		slog.Info("deleted observations", "category", w, "rows", deleted, "before", w.cutoff)
+		total += deleted
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing: %w", err)
+	}
+	return total, nil
+}
+
+// archive writes the observations matching where to fname as gzipped
+// jsonl.  The file is complete and closed before it returns, so it's safe to
+// delete the rows after.
+func archive(ctx context.Context, tx *sql.Tx, d *store.Dialect, where sq.Sqlizer, fname string) error {
+	file, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	zw := gzip.NewWriter(file)
+	buffered := bufio.NewWriter(zw)
+	w := &jsonlWriter{buffered}
+	rows, err := d.Builder.Select("station", "observation_time", "csv_parts").From("metars").Where(where).
+		OrderBy("station", "observation_time").RunWith(tx).QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		obs := &metarcsv.Observation{}
+		if err := rows.Scan(&obs.Station, &obs.ObservationTime, d.ScanArray(&obs.Parts)); err != nil {
+			return fmt.Errorf("scanning: %w", err)
+		}
+		if err := w.write(obs); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	slog.Info("archived observations", "rows", count, "file", fname)
+	return nil
+}
+
+// detachPartitionsBefore detaches the monthly partitions of metars that end
+// at or before cutoff.
+func detachPartitionsBefore(ctx context.Context, tx *sql.Tx, cutoff time.Time) error {
+	rows, err := tx.QueryContext(ctx, `SELECT c.relname FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+WHERE i.inhparent = 'metars'::regclass`)
+	if err != nil {
+		return fmt.Errorf("listing partitions: %w", err)
+	}
+	var old []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		m := partitionPattern.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		month, err := time.Parse("2006-01", m[1]+"-"+m[2])
+		if err != nil {
+			continue
+		}
+		if !month.AddDate(0, 1, 0).After(cutoff) {
+			old = append(old, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("listing partitions: %w", err)
+	}
+	for _, name := range old {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE metars DETACH PARTITION %s", pq.QuoteIdentifier(name))); err != nil {
+			return fmt.Errorf("detaching %s: %w", name, err)
+		}
+	}
+	if len(old) > 0 {
+		slog.Info("detached partitions", "partitions", old)
+	}
+	return nil
+}
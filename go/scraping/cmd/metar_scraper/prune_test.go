@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// pruneNow is when the prune tests prune.
+var pruneNow = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+const day = 24 * time.Hour
+
+// pruneDB returns a database with a KVFR, KIFR, KLIFR and KNONE row (the
+// last with no flight category) 10, 60 and 120 days before pruneNow.
+func pruneDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testDB(t)
+	s := store.New(db, store.SQLite, nil)
+	for _, category := range []string{"VFR", "IFR", "LIFR", ""} {
+		station := "K" + category
+		fields := map[int]string{metarcsv.ColumnIndex["flight_category"]: category}
+		if category == "" {
+			station = "KNONE"
+		}
+		for _, days := range []int{10, 60, 120} {
+			at := pruneNow.Add(-time.Duration(days) * day)
+			writeAll(t, s, testObservation(t, station, at, station+" "+at.Format("021504")+"Z 27010KT 10SM FEW050 12/05 A2992", fields))
+		}
+	}
+	return db
+}
+
+// remaining returns each metars row as its station and age in days, in
+// order.
+func remaining(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT station, observation_time FROM metars ORDER BY station, observation_time DESC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var left []string
+	for rows.Next() {
+		var station string
+		var at time.Time
+		if err := rows.Scan(&station, &at); err != nil {
+			t.Fatal(err)
+		}
+		left = append(left, fmt.Sprintf("%s %d", station, int(pruneNow.Sub(at)/day)))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return left
+}
+
+func TestPrune(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		retain retentionFlag
+		want   []string
+	}{
+		{
+			// without -retain, every category is kept as long
+			name: "uniform",
+			want: []string{"KIFR 10", "KLIFR 10", "KNONE 10", "KVFR 10"},
+		},
+		{
+			name:   "IFR and LIFR kept longer",
+			retain: retentionFlag{"IFR": 90 * day, "LIFR": 90 * day},
+			want:   []string{"KIFR 10", "KIFR 60", "KLIFR 10", "KLIFR 60", "KNONE 10", "KVFR 10"},
+		},
+		{
+			name:   "LIFR kept forever, VFR deleted sooner",
+			retain: retentionFlag{"LIFR": 1000 * day, "VFR": 5 * day},
+			want:   []string{"KIFR 10", "KLIFR 10", "KLIFR 60", "KLIFR 120", "KNONE 10"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			db := pruneDB(t)
+			flags := &pruneFlags{olderThan: 30 * day, retain: test.retain, dryRun: true}
+			counted, err := pruneMetars(context.Background(), db, store.SQLite, flags, pruneNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := metarsCount(t, db); got != 12 {
+				t.Errorf("dry run left %d rows, want all 12", got)
+			}
+
+			flags.dryRun = false
+			flags.archive = filepath.Join(t.TempDir(), "archive.jsonl.gz")
+			deleted, err := pruneMetars(context.Background(), db, store.SQLite, flags, pruneNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := remaining(t, db); !slices.Equal(got, test.want) {
+				t.Errorf("left %q, want %q", got, test.want)
+			}
+			if want := int64(12 - len(test.want)); deleted != want || counted != want {
+				t.Errorf("deleted %d and counted %d in the dry run, want %d", deleted, counted, want)
+			}
+			if archived := archivedLines(t, flags.archive); archived != int(deleted) {
+				t.Errorf("archived %d, deleted %d", archived, deleted)
+			}
+		})
+	}
+}
+
+func archivedLines(t *testing.T, fname string) int {
+	t.Helper()
+	file, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(zr)
+	n := 0
+	for scanner.Scan() {
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestPruneStoredCategory(t *testing.T) {
+	// the flight_category column -flight-category stores wins over AWC's
+	db := testDB(t)
+	at := pruneNow.Add(-60 * day)
+	obs := testObservation(t, "KBOS", at, "KBOS 151200Z 27010KT 1/2SM FG OVC002 12/12 A2992",
+		map[int]string{metarcsv.ColumnIndex["flight_category"]: "VFR"})
+	writeAll(t, store.New(db, store.SQLite, &store.Options{FlightCategory: true}), obs)
+	flags := &pruneFlags{olderThan: 30 * day, retain: retentionFlag{"LIFR": 90 * day}}
+	if _, err := pruneMetars(context.Background(), db, store.SQLite, flags, pruneNow); err != nil {
+		t.Fatal(err)
+	}
+	if got := metarsCount(t, db); got != 1 {
+		t.Errorf("LIFR row stored as VFR by AWC deleted")
+	}
+}
+
+func TestRetentionFlag(t *testing.T) {
+	retain := retentionFlag{}
+	for _, value := range []string{"ifr=720h", "LIFR=8760h"} {
+		if err := retain.Set(value); err != nil {
+			t.Errorf("%q: %v", value, err)
+		}
+	}
+	if got, want := retain.String(), "IFR=720h0m0s,LIFR=8760h0m0s"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	for _, bad := range []string{"IFR", "IFR=", "IMC=720h", "IFR=a year", "IFR=-1h", "VFR=0s"} {
+		if err := retain.Set(bad); err == nil {
+			t.Errorf("%q: got no error", bad)
+		}
+	}
+}
+
+func TestPruneUnsupported(t *testing.T) {
+	flags := &pruneFlags{olderThan: 30 * day}
+	if _, err := pruneMetars(context.Background(), nil, store.MySQL, flags, pruneNow); err == nil {
+		t.Error("mysql: got no error")
+	}
+	flags.partitions = true
+	if _, err := pruneMetars(context.Background(), testDB(t), store.SQLite, flags, pruneNow); err == nil {
+		t.Error("sqlite -partitions: got no error")
+	}
+}
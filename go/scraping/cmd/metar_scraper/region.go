@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// regionFilter restricts ingestion to a bounding box and a set of stations.
+// An observation is kept if it's in the box or is one of the stations; with
+// neither set, everything is kept.
+type regionFilter struct {
+	box *boundingBox
+	// stations holds the stations to keep, after aliasing, including those
+	// found for -state and -country.
+	stations map[string]bool
+}
+
+type boundingBox struct {
+	minLat, minLon, maxLat, maxLon float64
+}
+
+// parseBoundingBox parses "min_lat,min_lon,max_lat,max_lon".  min_lon may be
+// greater than max_lon for a box that crosses the antimeridian.
+func parseBoundingBox(value string) (*boundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expected min_lat,min_lon,max_lat,max_lon, got %q", value)
+	}
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	b := &boundingBox{values[0], values[1], values[2], values[3]}
+	if b.minLat > b.maxLat || b.minLat < -90 || b.maxLat > 90 ||
+		b.minLon < -180 || b.minLon > 180 || b.maxLon < -180 || b.maxLon > 180 {
+		return nil, fmt.Errorf("box %q out of range", value)
+	}
+	return b, nil
+}
+
+func (b *boundingBox) contains(lat, lon float64) bool {
+	if lat < b.minLat || lat > b.maxLat {
+		return false
+	}
+	if b.minLon <= b.maxLon {
+		return lon >= b.minLon && lon <= b.maxLon
+	}
+	return lon >= b.minLon || lon <= b.maxLon
+}
+
+// splitList splits each comma-separated value into upper-cased codes.
+func splitList(values []string) []string {
+	var codes []string
+	for _, value := range values {
+		for _, code := range strings.Split(value, ",") {
+			if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes
+}
+
+// readStationFile returns the stations listed in fname, one per line.  Blank
+// lines and lines starting with # are ignored.
+func readStationFile(fname string) ([]string, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var stations []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		stations = append(stations, line)
+	}
+	return stations, scanner.Err()
+}
+
+// stationsIn returns the stations in the stations table (written by
+// station_scraper) that are in one of the states or countries.
+func stationsIn(ctx context.Context, db *sql.DB, d *store.Dialect, states, countries []string) ([]string, error) {
+	var where sq.Or
+	if len(states) > 0 {
+		where = append(where, sq.Eq{"state": states})
+	}
+	if len(countries) > 0 {
+		where = append(where, sq.Eq{"country": countries})
+	}
+	rows, err := d.Builder.Select("station").From("stations").Where(where).RunWith(db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var stations []string
+	for rows.Next() {
+		var station string
+		if err := rows.Scan(&station); err != nil {
+			return nil, err
+		}
+		stations = append(stations, station)
+	}
+	return stations, rows.Err()
+}
+
+// newRegionFilter builds the filter from the -bbox, -only-station,
+// -station-file, -state and -country flags, looking states and countries up
+// in db.  It returns nil if none are set.
+func newRegionFilter(ctx context.Context, flags *Flags, db *sql.DB, d *store.Dialect) (*regionFilter, error) {
+	stations := splitList(flags.onlyStations)
+	if flags.stationFile != "" {
+		fromFile, err := readStationFile(flags.stationFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading -station-file: %w", err)
+		}
+		stations = append(stations, splitList(fromFile)...)
+	}
+	states, countries := splitList(flags.states), splitList(flags.countries)
+	if flags.bbox == "" && len(stations) == 0 && len(states) == 0 && len(countries) == 0 {
+		return nil, nil
+	}
+	f := &regionFilter{stations: map[string]bool{}}
+	if flags.bbox != "" {
+		var err error
+		if f.box, err = parseBoundingBox(flags.bbox); err != nil {
+			return nil, fmt.Errorf("bad -bbox: %w", err)
+		}
+	}
+	if len(states) > 0 || len(countries) > 0 {
+		if d == store.ClickHouse {
+			return nil, errors.New("-state and -country need the stations table, which isn't written to clickhouse")
+		}
+		if db == nil {
+			return nil, errors.New("-state and -country need the stations table, so can't be used with -dry-run or -no-database")
+		}
+		found, err := stationsIn(ctx, db, d, states, countries)
+		if err != nil {
+			return nil, fmt.Errorf("looking up stations by state and country: %w", err)
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no stations found in -state %v or -country %v; has station_scraper run?", states, countries)
+		}
+		stations = append(stations, found...)
+	}
+	for _, station := range stations {
+		f.stations[station] = true
+	}
+	return f, nil
+}
+
+// keep reports whether obs is in the region.  Observations without a
+// position are only kept if their station is listed.
+func (f *regionFilter) keep(obs *metarcsv.Observation) bool {
+	if f.stations[obs.Station] {
+		return true
+	}
+	if f.box == nil {
+		return false
+	}
+	lat, latOK := obs.Number(metarcsv.ColLatitude)
+	lon, lonOK := obs.Number(metarcsv.ColLongitude)
+	return latOK && lonOK && f.box.contains(lat, lon)
+}
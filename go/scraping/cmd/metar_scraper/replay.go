@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gocloud.dev/blob"
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// replayFile is one file for -replay to ingest.
+type replayFile struct {
+	name string
+	open func(ctx context.Context) (io.ReadCloser, error)
+}
+
+// listReplayFiles returns the files -replay names, in name order, which for
+// the file names -archive-url gives is the order they were downloaded in.
+// source is a bucket URL, for every object in the bucket (or under its prefix
+// parameter); a directory, for every file under it; or else a glob.  The
+// returned func closes the bucket, if there is one.
+func listReplayFiles(ctx context.Context, source string) ([]replayFile, func(), error) {
+	var files []replayFile
+	if strings.Contains(source, "://") {
+		bucket, err := blob.OpenBucket(ctx, source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening bucket: %w", err)
+		}
+		iter := bucket.List(nil)
+		for {
+			obj, err := iter.Next(ctx)
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				bucket.Close()
+				return nil, nil, fmt.Errorf("listing bucket: %w", err)
+			}
+			if obj.IsDir {
+				continue
+			}
+			key := obj.Key
+			files = append(files, replayFile{name: key, open: func(ctx context.Context) (io.ReadCloser, error) {
+				return bucket.NewReader(ctx, key, nil)
+			}})
+		}
+		if len(files) == 0 {
+			bucket.Close()
+			return nil, nil, fmt.Errorf("no files in %s", source)
+		}
+		// listings are already in key order
+		return files, func() { bucket.Close() }, nil
+	}
+	var names []string
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		err := filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+			// file:// buckets keep each object's metadata in a .attrs file
+			// beside it
+			if err == nil && !entry.IsDir() && !strings.HasSuffix(path, ".attrs") {
+				names = append(names, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing %s: %w", source, err)
+		}
+	} else {
+		if names, err = filepath.Glob(source); err != nil {
+			return nil, nil, fmt.Errorf("bad -replay pattern: %w", err)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no files match %s", source)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		files = append(files, replayFile{name: name, open: func(ctx context.Context) (io.ReadCloser, error) {
+			return os.Open(name)
+		}})
+	}
+	return files, func() {}, nil
+}
+
+// replayFiles ingests each of files into out in turn, in a transaction of its
+// own, logging progress as it goes.  Files before one that fails stay
+// ingested, so the log's last replayed file is where to pick up from.  With
+// pace, each file is held back until it's due, as if arriving live.
+func replayFiles(ctx context.Context, files []replayFile, out store.Store, opts *ingestOptions, stats *runStats, pace *replayPacer) error {
+	start := time.Now()
+	for i, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		written, scanned := stats.rowsWritten, stats.linesScanned
+		if opts.deadLetter != nil {
+			opts.deadLetter.source = f.name
+		}
+		if err := replayOne(ctx, f, out, opts, stats, pace); err != nil {
+			return fmt.Errorf("replaying %s: %w", f.name, err)
+		}
+		elapsed := time.Since(start)
+		remaining := elapsed / time.Duration(i+1) * time.Duration(len(files)-i-1)
+		slog.Info("replayed file", "file", f.name, "done", i+1, "files", len(files),
+			"lines", stats.linesScanned-scanned, "written", stats.rowsWritten-written,
+			"elapsed", elapsed.Round(time.Second), "remaining", remaining.Round(time.Second))
+	}
+	return nil
+}
+
+func replayOne(ctx context.Context, f replayFile, out store.Store, opts *ingestOptions, stats *runStats, pace *replayPacer) error {
+	r, err := f.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	body, err := awc.Decompress(f.name, r)
+	if err != nil {
+		return err
+	}
+	if c, ok := body.(io.Closer); ok {
+		defer c.Close()
+	}
+	if pace == nil {
+		return readToDB(ctx, out, body, opts, stats)
+	}
+	// the file is read twice, once for its time and once to ingest it, but
+	// snapshots are small enough to hold in memory
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if err := pace.wait(ctx, snapshotTime(data, opts)); err != nil {
+		return err
+	}
+	return readToDB(ctx, out, bytes.NewReader(data), opts, stats)
+}
+
+// snapshotTime returns the newest observation time in data, a file as
+// readToDB reads it, or the zero time if it has none that parse.
+func snapshotTime(data []byte, opts *ingestOptions) time.Time {
+	var newest time.Time
+	input, err := metarcsv.Open(bytes.NewReader(data), opts.inputFormat, &opts.records)
+	if err != nil {
+		return newest
+	}
+	for input.Next() {
+		if _, obs, err := input.Record(); err == nil && obs != nil && obs.ObservationTime.After(newest) {
+			newest = obs.ObservationTime
+		}
+	}
+	return newest
+}
+
+// replayPacer spaces out -paced replays by their snapshots' times: each is
+// due as long after the first as its newest observation was after the
+// first's, divided by speed.  Snapshots out of order, or with no times, are
+// due at once.
+type replayPacer struct {
+	speed float64
+	// first is the first snapshot's time, and started when it was replayed.
+	first, started time.Time
+}
+
+// delay returns how long after now the snapshot taken at at is due.
+func (p *replayPacer) delay(at, now time.Time) time.Duration {
+	if at.IsZero() {
+		return 0
+	}
+	if p.first.IsZero() {
+		p.first, p.started = at, now
+		return 0
+	}
+	due := p.started.Add(time.Duration(float64(at.Sub(p.first)) / p.speed))
+	return max(due.Sub(now), 0)
+}
+
+// wait sleeps until the snapshot taken at at is due, or ctx is done.
+func (p *replayPacer) wait(ctx context.Context, at time.Time) error {
+	d := p.delay(at, time.Now())
+	if d == 0 {
+		return nil
+	}
+	slog.Info("pacing replay", "snapshot", at.Format(time.RFC3339), "wait", d.Round(time.Millisecond))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+func TestReplayPacerDelay(t *testing.T) {
+	p := &replayPacer{speed: 60}
+	first := time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC)
+	started := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		name    string
+		at, now time.Time
+		want    time.Duration
+	}{
+		{"first", first, started, 0},
+		// an hour of snapshots, sixty times faster, is due a minute in
+		{"an hour later", first.Add(time.Hour), started.Add(10 * time.Second), 50 * time.Second},
+		{"already due", first.Add(time.Hour), started.Add(2 * time.Minute), 0},
+		{"out of order", first.Add(-time.Hour), started.Add(time.Second), 0},
+		{"no times", time.Time{}, started, 0},
+	} {
+		if got := p.delay(test.at, test.now); got != test.want {
+			t.Errorf("%s: delay %v, want %v", test.name, got, test.want)
+		}
+	}
+	if !p.first.Equal(first) || !p.started.Equal(started) {
+		t.Errorf("paced from %v at %v, want %v at %v", p.first, p.started, first, started)
+	}
+}
+
+func TestReplayPaced(t *testing.T) {
+	db := testDB(t)
+	dir := t.TempDir()
+	at := time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC)
+	for name, obsAt := range map[string]time.Time{
+		"0.csv": at,
+		"1.csv": at.Add(time.Hour),
+	} {
+		raw := "KBOS " + obsAt.Format("021504Z") + " 27010KT 10SM FEW050 12/05 A2992"
+		// each ends in a cut-off line, as cache files often do
+		file := testFile(testObservation(t, "KBOS", obsAt, raw, nil), &metarcsv.Observation{Parts: []string{"KB"}})
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, closeFiles, err := listReplayFiles(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFiles()
+	data, err := os.ReadFile(files[1].name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshotTime(data, &ingestOptions{inputFormat: "csv"}); !got.Equal(at.Add(time.Hour)) {
+		t.Errorf("snapshot time %v, want %v", got, at.Add(time.Hour))
+	}
+
+	// the hour between the two, 36000 times faster
+	const want = 100 * time.Millisecond
+	opts := &ingestOptions{inputFormat: "csv"}
+	stats := &runStats{}
+	start := time.Now()
+	if err := replayFiles(context.Background(), files, store.New(db, store.SQLite, nil), opts, stats, &replayPacer{speed: 36000}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < want {
+		t.Errorf("replayed in %v, want at least %v", elapsed, want)
+	}
+	if got := metarsCount(t, db); got != 2 {
+		t.Errorf("stored %d rows, want 2", got)
+	}
+
+	// a replay stopped while a file is due an hour from now stops waiting
+	ctx, cancel := context.WithTimeout(context.Background(), want)
+	defer cancel()
+	pace := &replayPacer{speed: 1}
+	pace.delay(at, time.Now())
+	err = replayFiles(ctx, files[1:], store.New(db, store.SQLite, nil), opts, &runStats{}, pace)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("stopped replay: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
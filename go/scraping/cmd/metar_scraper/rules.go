@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// rule is a cross-field validity check on an observation.  check returns a
+// description of the problem, or "" if the observation passes.  Rules skip
+// observations that are missing the fields they look at.
+type rule struct {
+	name  string
+	check func(obs *metarcsv.Observation) string
+}
+
+var builtinRules = []rule{
+	{"dewpoint_above_temp", checkDewpoint},
+	{"gust_below_speed", checkGust},
+	{"wind_dir_range", checkWindDir},
+	{"cavok_visibility", checkCAVOKVisibility},
+	{"future_observation", checkFutureObservation},
+}
+
+// cavokMinVisibilityMi is 10km, the visibility CAVOK implies, with some slack
+// for rounding.
+const cavokMinVisibilityMi = 6
+
+func checkDewpoint(obs *metarcsv.Observation) string {
+	temp, tempOK := obs.Number(metarcsv.ColTempC)
+	dewpoint, dewpointOK := obs.Number(metarcsv.ColDewpointC)
+	if tempOK && dewpointOK && dewpoint > temp {
+		return fmt.Sprintf("dewpoint %v above temperature %v", dewpoint, temp)
+	}
+	return ""
+}
+
+func checkGust(obs *metarcsv.Observation) string {
+	speed, speedOK := obs.Number(metarcsv.ColWindSpeedKt)
+	gust, gustOK := obs.Number(metarcsv.ColWindGustKt)
+	if speedOK && gustOK && gust > 0 && gust < speed {
+		return fmt.Sprintf("gust %vkt below wind speed %vkt", gust, speed)
+	}
+	return ""
+}
+
+func checkWindDir(obs *metarcsv.Observation) string {
+	// "VRB" doesn't parse as a number and is skipped
+	dir, ok := obs.Number(metarcsv.ColWindDirDegrees)
+	if ok && (dir < 0 || dir > 360) {
+		return fmt.Sprintf("wind direction %v out of range", dir)
+	}
+	return ""
+}
+
+func checkCAVOKVisibility(obs *metarcsv.Observation) string {
+	vis, ok := obs.Number(metarcsv.ColVisibilityMi)
+	if ok && vis < cavokMinVisibilityMi && strings.Contains(" "+obs.Field(metarcsv.ColRawText)+" ", " CAVOK ") {
+		return fmt.Sprintf("visibility %vmi with CAVOK", vis)
+	}
+	return ""
+}
+
+func checkFutureObservation(obs *metarcsv.Observation) string {
+	if limit := time.Now().Add(time.Hour); obs.ObservationTime.After(limit) {
+		return fmt.Sprintf("observation time %v is in the future", obs.ObservationTime)
+	}
+	return ""
+}
+
+// validator applies a set of rules to each observation.  Violations are
+// always logged and counted; in strict mode the observation is also
+// rejected.
+type validator struct {
+	rules  []rule
+	strict bool
+	// violations counts violations by rule name.
+	violations map[string]int
+}
+
+// newValidator returns a validator for the built-in rules, minus the
+// comma-separated rule names in disabled.
+func newValidator(disabled string, strict bool) (*validator, error) {
+	skip := map[string]bool{}
+	for _, name := range strings.Split(disabled, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			skip[name] = true
+		}
+	}
+	v := &validator{strict: strict, violations: map[string]int{}}
+	for _, r := range builtinRules {
+		if skip[r.name] {
+			delete(skip, r.name)
+			continue
+		}
+		v.rules = append(v.rules, r)
+	}
+	for name := range skip {
+		return nil, fmt.Errorf("unknown rule %q", name)
+	}
+	return v, nil
+}
+
+// valid checks obs against every rule and reports whether it should be kept.
+func (v *validator) valid(obs *metarcsv.Observation) bool {
+	ok := true
+	for _, r := range v.rules {
+		if problem := r.check(obs); problem != "" {
+			slog.Debug("rule violated", "station", obs.Station, "observation_time", obs.ObservationTime, "rule", r.name, "problem", problem)
+			v.violations[r.name]++
+			ok = false
+		}
+	}
+	return ok || !v.strict
+}
+
+// summary describes the violation counts by rule, e.g. "gust_below_speed=2".
+func (v *validator) summary() string {
+	var parts []string
+	for name, count := range v.violations {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// ruleTest is an observation and whether a rule should flag it.
+type ruleTest struct {
+	name    string
+	raw     string
+	at      time.Time
+	fields  map[int]string
+	flagged bool
+}
+
+func testRule(t *testing.T, check func(obs *metarcsv.Observation) string, tests []ruleTest) {
+	t.Helper()
+	for _, test := range tests {
+		at := test.at
+		if at.IsZero() {
+			at = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+		}
+		raw := test.raw
+		if raw == "" {
+			raw = "KBOS 141200Z 27010KT 10SM FEW050 12/05 A2992"
+		}
+		problem := check(testObservation(t, "KBOS", at, raw, test.fields))
+		if (problem != "") != test.flagged {
+			t.Errorf("%s: problem %q, want flagged %v", test.name, problem, test.flagged)
+		}
+	}
+}
+
+func TestCheckDewpoint(t *testing.T) {
+	testRule(t, checkDewpoint, []ruleTest{
+		{name: "below", fields: map[int]string{metarcsv.ColTempC: "12.0", metarcsv.ColDewpointC: "5.0"}},
+		{name: "equal", fields: map[int]string{metarcsv.ColTempC: "5.0", metarcsv.ColDewpointC: "5.0"}},
+		{name: "above", fields: map[int]string{metarcsv.ColTempC: "5.0", metarcsv.ColDewpointC: "6.1"}, flagged: true},
+		{name: "below freezing", fields: map[int]string{metarcsv.ColTempC: "-8.3", metarcsv.ColDewpointC: "-5.0"}, flagged: true},
+		{name: "no dewpoint", fields: map[int]string{metarcsv.ColTempC: "5.0"}},
+	})
+}
+
+func TestCheckGust(t *testing.T) {
+	testRule(t, checkGust, []ruleTest{
+		{name: "gusting", fields: map[int]string{metarcsv.ColWindSpeedKt: "15", metarcsv.ColWindGustKt: "25"}},
+		{name: "no gust", fields: map[int]string{metarcsv.ColWindSpeedKt: "15"}},
+		{name: "zero gust", fields: map[int]string{metarcsv.ColWindSpeedKt: "15", metarcsv.ColWindGustKt: "0"}},
+		{name: "below", fields: map[int]string{metarcsv.ColWindSpeedKt: "25", metarcsv.ColWindGustKt: "15"}, flagged: true},
+	})
+}
+
+func TestCheckWindDir(t *testing.T) {
+	testRule(t, checkWindDir, []ruleTest{
+		{name: "north", fields: map[int]string{metarcsv.ColWindDirDegrees: "360"}},
+		{name: "calm", fields: map[int]string{metarcsv.ColWindDirDegrees: "0"}},
+		{name: "variable", fields: map[int]string{metarcsv.ColWindDirDegrees: "VRB"}},
+		{name: "missing"},
+		{name: "too high", fields: map[int]string{metarcsv.ColWindDirDegrees: "370"}, flagged: true},
+		{name: "negative", fields: map[int]string{metarcsv.ColWindDirDegrees: "-10"}, flagged: true},
+	})
+}
+
+func TestCheckCAVOKVisibility(t *testing.T) {
+	cavok := "LFPG 141200Z 24010KT CAVOK 18/09 Q1020"
+	testRule(t, checkCAVOKVisibility, []ruleTest{
+		{name: "cavok", raw: cavok, fields: map[int]string{metarcsv.ColVisibilityMi: "6.21"}},
+		{name: "cavok 10+", raw: cavok, fields: map[int]string{metarcsv.ColVisibilityMi: "10+"}},
+		{name: "cavok no visibility", raw: cavok},
+		{name: "cavok zero", raw: cavok, fields: map[int]string{metarcsv.ColVisibilityMi: "0"}, flagged: true},
+		{name: "cavok low", raw: cavok, fields: map[int]string{metarcsv.ColVisibilityMi: "3.0"}, flagged: true},
+		{name: "low without cavok", raw: "LFPG 141200Z 24010KT 4000 BR NSC 18/17 Q1020", fields: map[int]string{metarcsv.ColVisibilityMi: "2.49"}},
+		{name: "cavok in a word", raw: "LFPG 141200Z 24010KT 4000 BR NSC 18/17 Q1020 RMK NOCAVOK", fields: map[int]string{metarcsv.ColVisibilityMi: "2.49"}},
+	})
+}
+
+func TestCheckFutureObservation(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	testRule(t, checkFutureObservation, []ruleTest{
+		{name: "past", at: now.Add(-time.Hour)},
+		{name: "clock skew", at: now.Add(30 * time.Minute)},
+		{name: "future", at: now.Add(2 * time.Hour), flagged: true},
+	})
+}
+
+func TestNewValidator(t *testing.T) {
+	if _, err := newValidator("gust_below_speed, no_such_rule", false); err == nil {
+		t.Error("unknown disabled rule: got no error")
+	}
+	v, err := newValidator(" gust_below_speed,future_observation ", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range v.rules {
+		names = append(names, r.name)
+	}
+	if got, want := len(v.rules), len(builtinRules)-2; got != want {
+		t.Errorf("rules %q, want %d", names, want)
+	}
+	for _, name := range names {
+		if name == "gust_below_speed" || name == "future_observation" {
+			t.Errorf("disabled rule %s still applied", name)
+		}
+	}
+}
+
+func TestValidatorStrict(t *testing.T) {
+	at := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	bad := testObservation(t, "KBOS", at, "KBOS 141200Z 27025G15KT 10SM FEW050 05/06 A2992", map[int]string{
+		metarcsv.ColTempC: "5.0", metarcsv.ColDewpointC: "6.0", metarcsv.ColWindSpeedKt: "25", metarcsv.ColWindGustKt: "15",
+	})
+	good := testObservation(t, "KBOS", at, "KBOS 141200Z 27010KT 10SM FEW050 12/05 A2992", map[int]string{
+		metarcsv.ColTempC: "12.0", metarcsv.ColDewpointC: "5.0",
+	})
+	for _, strict := range []bool{false, true} {
+		v, err := newValidator("", strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !v.valid(good) {
+			t.Errorf("strict %v: good observation rejected", strict)
+		}
+		if v.valid(bad) == strict {
+			t.Errorf("strict %v: bad observation kept %v", strict, !strict)
+		}
+		if got, want := v.summary(), "dewpoint_above_temp=1, gust_below_speed=1"; got != want {
+			t.Errorf("strict %v: summary %q, want %q", strict, got, want)
+		}
+	}
+}
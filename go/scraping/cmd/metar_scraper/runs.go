@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"mattdee123.com/aviationweather/store"
+)
+
+// runProduct is what metar_scraper's rows in scrape_runs are for.
+const runProduct = "metar"
+
+// recordRun writes the run's stats to scrape_runs in the primary database,
+// with its own connection so that a run which failed to connect is still
+// recorded if the database comes back.  Failures are logged rather than
+// returned, like the other reporting.
+func recordRun(flags *Flags, stats *runStats, runErr error) {
+	dbURL := ""
+	if len(flags.dbURLs) > 0 {
+		dbURL = flags.dbURLs[0]
+	}
+	db, d, err := store.Open(dbURL, flags.driver)
+	if err != nil {
+		slog.Warn("recording run: connecting to database", "err", err)
+		return
+	}
+	defer db.Close()
+	if d == store.ClickHouse {
+		slog.Warn("recording run: scrape_runs isn't written to clickhouse")
+		return
+	}
+	var message *string
+	if runErr != nil {
+		text := runErr.Error()
+		message = &text
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = d.Builder.Insert("scrape_runs").
+		Columns("started_at", "finished_at", "product", "success", "error", "lines_scanned", "rows_written", "rows_skipped").
+		Values(stats.start, stats.end, runProduct, stats.success, message, stats.linesScanned, stats.rowsWritten, stats.rowsSkipped()).
+		RunWith(db).ExecContext(ctx)
+	if err != nil {
+		slog.Warn("recording run", "err", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// sampler keeps at most one observation per station per interval bucket.
+// Within a bucket, routine METARs beat SPECIs, and then the observation
+// closest to a bucket boundary (i.e. the top of the hour for 1h) wins.  It
+// only sees one file's observations, so doesn't stop another file adding a
+// second row to a bucket.
+type sampler struct {
+	interval time.Duration
+	// seen is the number of observations passed to add.
+	seen int
+	best map[sampleKey]*metarcsv.Observation
+}
+
+type sampleKey struct {
+	station string
+	bucket  time.Time
+}
+
+func newSampler(interval time.Duration) *sampler {
+	return &sampler{
+		interval: interval,
+		best:     map[sampleKey]*metarcsv.Observation{},
+	}
+}
+
+func (s *sampler) add(obs *metarcsv.Observation) {
+	s.seen++
+	key := sampleKey{obs.Station, obs.ObservationTime.Truncate(s.interval)}
+	if current, ok := s.best[key]; !ok || s.better(obs, current) {
+		s.best[key] = obs
+	}
+}
+
+// better reports whether a should be kept over b.
+func (s *sampler) better(a, b *metarcsv.Observation) bool {
+	aRoutine, bRoutine := a.Field(metarcsv.ColMetarType) == "METAR", b.Field(metarcsv.ColMetarType) == "METAR"
+	if aRoutine != bRoutine {
+		return aRoutine
+	}
+	aDist, bDist := s.boundaryDistance(a.ObservationTime), s.boundaryDistance(b.ObservationTime)
+	if aDist != bDist {
+		return aDist < bDist
+	}
+	return a.ObservationTime.Before(b.ObservationTime)
+}
+
+// boundaryDistance is how far t is from the nearest bucket boundary.
+func (s *sampler) boundaryDistance(t time.Time) time.Duration {
+	sinceStart := t.Sub(t.Truncate(s.interval))
+	if untilEnd := s.interval - sinceStart; untilEnd < sinceStart {
+		return untilEnd
+	}
+	return sinceStart
+}
+
+// observations returns the kept observations ordered by station and time.
+func (s *sampler) observations() []*metarcsv.Observation {
+	var kept []*metarcsv.Observation
+	for _, obs := range s.best {
+		kept = append(kept, obs)
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		if kept[i].Station != kept[j].Station {
+			return kept[i].Station < kept[j].Station
+		}
+		return kept[i].ObservationTime.Before(kept[j].ObservationTime)
+	})
+	return kept
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+func TestSampler(t *testing.T) {
+	hour := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	report := func(station string, minutes int, metarType string) *metarcsv.Observation {
+		return testObservation(t, station, hour.Add(time.Duration(minutes)*time.Minute), station+" report", map[int]string{metarcsv.ColMetarType: metarType})
+	}
+	for _, test := range []struct {
+		name string
+		in   []*metarcsv.Observation
+		// want are the minutes past 12:00 of the kept observations, by
+		// station and time
+		want []int
+	}{{
+		name: "one per bucket",
+		in:   []*metarcsv.Observation{report("KBOS", 0, "METAR"), report("KBOS", 60, "METAR"), report("KBOS", 120, "METAR")},
+		want: []int{0, 60, 120},
+	}, {
+		name: "METAR over a nearer SPECI",
+		in:   []*metarcsv.Observation{report("KBOS", 2, "SPECI"), report("KBOS", 20, "METAR")},
+		want: []int{20},
+	}, {
+		name: "nearest a boundary",
+		in:   []*metarcsv.Observation{report("KBOS", 10, "METAR"), report("KBOS", 54, "METAR"), report("KBOS", 30, "METAR")},
+		want: []int{54},
+	}, {
+		name: "SPECIs nearest a boundary when there's no METAR",
+		in:   []*metarcsv.Observation{report("KBOS", 25, "SPECI"), report("KBOS", 8, "SPECI")},
+		want: []int{8},
+	}, {
+		name: "the earlier of two as near",
+		in:   []*metarcsv.Observation{report("KBOS", 50, "METAR"), report("KBOS", 10, "METAR")},
+		want: []int{10},
+	}, {
+		name: "stations separately",
+		in:   []*metarcsv.Observation{report("KJFK", 51, "METAR"), report("KBOS", 54, "METAR"), report("KBOS", 3, "SPECI")},
+		want: []int{54, 51},
+	}} {
+		s := newSampler(time.Hour)
+		for _, obs := range test.in {
+			s.add(obs)
+		}
+		var got []int
+		for _, obs := range s.observations() {
+			got = append(got, int(obs.ObservationTime.Sub(hour)/time.Minute))
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: kept %v, want %v", test.name, got, test.want)
+		}
+		if s.seen != len(test.in) {
+			t.Errorf("%s: seen %d, want %d", test.name, s.seen, len(test.in))
+		}
+	}
+}
+
+func TestSamplerBoundaryDistance(t *testing.T) {
+	s := newSampler(time.Hour)
+	base := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		offset, want time.Duration
+	}{
+		{0, 0},
+		{10 * time.Minute, 10 * time.Minute},
+		{30 * time.Minute, 30 * time.Minute},
+		{53 * time.Minute, 7 * time.Minute},
+	} {
+		if got := s.boundaryDistance(base.Add(test.offset)); got != test.want {
+			t.Errorf("boundaryDistance(12:00 + %v) = %v, want %v", test.offset, got, test.want)
+		}
+	}
+}
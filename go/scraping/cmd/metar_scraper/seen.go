@@ -0,0 +1,104 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// seenFalsePositiveRate is the chance, with the filter full, that a row
+// that hasn't been written is taken for one that has.
+const seenFalsePositiveRate = 1e-4
+
+// seenFilter is a Bloom filter of the rows written this session, by station,
+// observation_time and content_hash, for -seen-filter-size.  Most of each
+// daemon cycle's rows are the last cycle's again, and skipping them saves
+// building and running their upserts.
+//
+// A Bloom filter can have false positives: a row that was never written, a
+// new report or a correction, can be taken for a seen one and skipped for
+// the rest of the session.  The filter is sized for that to happen to about
+// one row in seenFalsePositiveRate of those checked when full, and is cleared
+// once it holds size keys so that it doesn't get worse; the next cycle then
+// writes everything again.  Something else writing the same database, like
+// prune or dedup, isn't seen either, so a row it deletes isn't rewritten
+// while it's still in the filter.
+type seenFilter struct {
+	bits []uint64
+	// hashes is the number of bits set per key.
+	hashes int
+	// size is how many keys the filter is sized for, and added how many
+	// it holds.
+	size, added int
+	// pending are the keys of the rows written since begin, added once
+	// they're committed.
+	pending [][2]uint32
+}
+
+func newSeenFilter(size int) *seenFilter {
+	// the optimal sizes for a false positive rate p are -n ln p / (ln 2)^2
+	// bits and (bits / n) ln 2 hashes
+	bits := int(math.Ceil(-float64(size) * math.Log(seenFalsePositiveRate) / (math.Ln2 * math.Ln2)))
+	return &seenFilter{
+		bits:   make([]uint64, (bits+63)/64),
+		hashes: max(1, int(math.Round(float64(bits)/float64(size)*math.Ln2))),
+		size:   size,
+	}
+}
+
+// key hashes obs's key into the two halves the filter's hashes are made
+// from.
+func (f *seenFilter) key(obs *metarcsv.Observation) [2]uint32 {
+	h := fnv.New64a()
+	h.Write([]byte(obs.Station))
+	h.Write([]byte{0})
+	h.Write([]byte(obs.ObservationTime.UTC().Format(time.RFC3339Nano)))
+	h.Write([]byte{0})
+	h.Write([]byte(store.ContentHash(obs)))
+	sum := h.Sum64()
+	// odd, so that the hashes don't repeat
+	return [2]uint32{uint32(sum), uint32(sum>>32) | 1}
+}
+
+// bit returns the word and bit of key's i'th hash.
+func (f *seenFilter) bit(key [2]uint32, i int) (int, uint64) {
+	n := (uint64(key[0]) + uint64(i)*uint64(key[1])) % uint64(len(f.bits)*64)
+	return int(n / 64), 1 << (n % 64)
+}
+
+// begin starts a file, forgetting the rows of any that wasn't committed.
+func (f *seenFilter) begin() {
+	f.pending = f.pending[:0]
+}
+
+// skip reports whether obs has probably been written already, and if not
+// notes it as written.
+func (f *seenFilter) skip(obs *metarcsv.Observation) bool {
+	key := f.key(obs)
+	for i := range f.hashes {
+		if word, bit := f.bit(key, i); f.bits[word]&bit == 0 {
+			f.pending = append(f.pending, key)
+			return false
+		}
+	}
+	return true
+}
+
+// commit adds the rows written since begin.
+func (f *seenFilter) commit() {
+	for _, key := range f.pending {
+		if f.added >= f.size {
+			clear(f.bits)
+			f.added = 0
+		}
+		for i := range f.hashes {
+			word, bit := f.bit(key, i)
+			f.bits[word] |= bit
+		}
+		f.added++
+	}
+	f.pending = f.pending[:0]
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+func TestSeenFilter(t *testing.T) {
+	at := time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC)
+	report := testObservation(t, "KBOS", at, "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", nil)
+	f := newSeenFilter(100)
+
+	f.begin()
+	if f.skip(report) {
+		t.Error("skipped before anything was written")
+	}
+	// not yet committed
+	if f.skip(report) {
+		t.Error("skipped a row written in the same file")
+	}
+	f.commit()
+
+	f.begin()
+	if !f.skip(report) {
+		t.Error("didn't skip a committed row")
+	}
+	for name, obs := range map[string]*metarcsv.Observation{
+		"correction":   testObservation(t, "KBOS", at, "KBOS 141154Z COR 27010KT 10SM FEW050 12/05 A2991", nil),
+		"another time": testObservation(t, "KBOS", at.Add(time.Hour), report.Field(metarcsv.ColRawText), nil),
+	} {
+		if f.skip(obs) {
+			t.Errorf("%s: skipped", name)
+		}
+	}
+
+	// a file rolled back isn't remembered
+	rolledBack := testObservation(t, "KJFK", at, "KJFK 141151Z 31015KT 10SM FEW050 12/05 A2992", nil)
+	f.begin()
+	f.skip(rolledBack)
+	f.begin()
+	if f.skip(rolledBack) {
+		t.Error("skipped a row from a file that wasn't committed")
+	}
+}
+
+func TestSeenFilterFull(t *testing.T) {
+	at := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	f := newSeenFilter(2)
+	f.begin()
+	for i := range 3 {
+		f.skip(testObservation(t, "KBOS", at.Add(time.Duration(i)*time.Hour), "KBOS", nil))
+	}
+	f.commit()
+	// clearing to add the third forgot the first two
+	f.begin()
+	if f.skip(testObservation(t, "KBOS", at, "KBOS", nil)) {
+		t.Error("first row still seen after the filter filled")
+	}
+	if !f.skip(testObservation(t, "KBOS", at.Add(2*time.Hour), "KBOS", nil)) {
+		t.Error("last row not seen")
+	}
+}
+
+func TestSeenFilterFalsePositives(t *testing.T) {
+	const size = 10000
+	at := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	f := newSeenFilter(size)
+	f.begin()
+	for i := range size {
+		f.skip(testObservation(t, fmt.Sprintf("K%03d", i%1000), at.Add(time.Duration(i/1000)*time.Hour), "", nil))
+	}
+	f.commit()
+	f.begin()
+	falsePositives := 0
+	for i := range size {
+		if f.skip(testObservation(t, fmt.Sprintf("E%03d", i%1000), at.Add(time.Duration(i/1000)*time.Hour), "", nil)) {
+			falsePositives++
+		}
+	}
+	// about one expected; ten would mean the sizing is off
+	if falsePositives >= 10 {
+		t.Errorf("%d false positives in %d, want about %v", falsePositives, size, seenFalsePositiveRate*size)
+	}
+}
+
+func TestReadToDBSeen(t *testing.T) {
+	db := testDB(t)
+	at := time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC)
+	first := testFile(
+		testObservation(t, "KBOS", at, "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", nil),
+		testObservation(t, "KJFK", at, "KJFK 141154Z 31015KT 10SM FEW050 12/05 A2992", nil),
+	)
+	second := testFile(
+		testObservation(t, "KBOS", at, "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", nil),
+		// corrected since the first
+		testObservation(t, "KJFK", at, "KJFK 141154Z COR 31015KT 10SM FEW050 12/05 A2991", nil),
+	)
+	opts := &ingestOptions{inputFormat: "csv", seen: newSeenFilter(100)}
+	for i, test := range []struct {
+		file          string
+		written, seen int
+	}{
+		{first, 2, 0},
+		{second, 1, 1},
+		{second, 0, 2},
+	} {
+		stats := &runStats{}
+		if err := readToDB(context.Background(), store.New(db, store.SQLite, nil), strings.NewReader(test.file), opts, stats); err != nil {
+			t.Fatal(err)
+		}
+		if stats.rowsWritten != test.written || stats.rowsSeen != test.seen {
+			t.Errorf("file %d: wrote %d and skipped %d as seen, want %d and %d", i, stats.rowsWritten, stats.rowsSeen, test.written, test.seen)
+		}
+	}
+	var raw string
+	if err := db.QueryRow("SELECT csv_parts FROM metars WHERE station = 'KJFK'").Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(raw, "COR") {
+		t.Errorf("correction not stored: %s", raw)
+	}
+}
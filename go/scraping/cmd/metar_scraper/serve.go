@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"mattdee123.com/aviationweather/calc"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+	"mattdee123.com/aviationweather/units"
+)
+
+type serveFlags struct {
+	dbURL  string
+	driver string
+	listen string
+	limit  int
+	// metricsStations are served by /metrics.
+	metricsStations stringsFlag
+	graphql         bool
+	logging         scraping.Logging
+}
+
+func (f *serveFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.driver, "driver", "postgres", "database driver for -dburl, as for the scraper")
+	fs.StringVar(&f.listen, "listen", ":8080", "address to serve on")
+	fs.IntVar(&f.limit, "limit", 1000, "most observations returned by one request")
+	fs.Var(&f.metricsStations, "metrics-station", "station whose current conditions are served as Prometheus metrics on /metrics, from metars_latest; may be repeated or comma-separated")
+	fs.BoolVar(&f.graphql, "graphql", false, "if set, also answer GraphQL queries over the stored observations, POSTed to /graphql, for choosing fields and stations in one request")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+// server answers the HTTP API from the metars table.
+type server struct {
+	db      *sql.DB
+	dialect *store.Dialect
+	limit   int
+	// postgis is set if metars_latest has a location column.
+	postgis bool
+	// latest is set if metars_latest is kept up to date, so a station's
+	// latest observation is read from it rather than the archive.
+	latest bool
+	// metricsStations are the stations served by /metrics, which is only
+	// routed if there are some.
+	metricsStations []string
+	// graphql, if set, is served on /graphql.
+	graphql *graphql.Schema
+}
+
+// apiObservation is an observation as the API returns it: the cache file's
+// columns by name, plus the raw text decoded.
+type apiObservation struct {
+	Columns map[string]interface{} `json:"columns"`
+	Decoded *metar.METAR           `json:"decoded,omitempty"`
+	// DistanceNM is set for /metar/near.
+	DistanceNM *float64 `json:"distance_nm,omitempty"`
+	// Units is set when the request asks for units.
+	Units *apiUnits `json:"units,omitempty"`
+}
+
+// apiUnits are an observation's main values, from its decoded raw text, in
+// the units asked for.
+type apiUnits struct {
+	System      units.System `json:"system"`
+	WindSpeed   *units.Value `json:"wind_speed,omitempty"`
+	WindGust    *units.Value `json:"wind_gust,omitempty"`
+	Visibility  *units.Value `json:"visibility,omitempty"`
+	Ceiling     *units.Value `json:"ceiling,omitempty"`
+	Temperature *units.Value `json:"temperature,omitempty"`
+	Dewpoint    *units.Value `json:"dewpoint,omitempty"`
+	Altimeter   *units.Value `json:"altimeter,omitempty"`
+}
+
+func newAPIObservation(obs *metarcsv.Observation) *apiObservation {
+	a := &apiObservation{Columns: observationRecord(obs)}
+	if decoded, err := metar.Decode(obs.Field(metarcsv.ColRawText)); err == nil {
+		a.Decoded = decoded
+	}
+	return a
+}
+
+// setUnits fills in a.Units in u, unless u is "" or a couldn't be decoded.
+func (a *apiObservation) setUnits(u units.System) {
+	m := a.Decoded
+	if u == "" || m == nil {
+		return
+	}
+	set := func(v units.Value) *units.Value { return &v }
+	a.Units = &apiUnits{System: u}
+	if w := m.Wind; w != nil {
+		knots := func(speed int) float64 {
+			switch w.Unit {
+			case "MPS":
+				return units.MPSToKnots(float64(speed))
+			case "KMH":
+				return units.KMHToKnots(float64(speed))
+			}
+			return float64(speed)
+		}
+		a.Units.WindSpeed = set(u.Speed(knots(w.Speed)))
+		if w.Gust != nil {
+			a.Units.WindGust = set(u.Speed(knots(*w.Gust)))
+		}
+	}
+	if miles := m.Conditions.Miles(); miles != nil {
+		a.Units.Visibility = set(u.Visibility(*miles))
+	}
+	if ceiling := m.Conditions.CeilingFt(); ceiling != nil {
+		a.Units.Ceiling = set(u.Height(float64(*ceiling)))
+	}
+	// the remarks' T group is more precise
+	if r := m.Remarks; r != nil && r.TempC != nil {
+		a.Units.Temperature = set(u.Temperature(*r.TempC))
+	} else if m.TempC != nil {
+		a.Units.Temperature = set(u.Temperature(float64(*m.TempC)))
+	}
+	if r := m.Remarks; r != nil && r.DewpointC != nil {
+		a.Units.Dewpoint = set(u.Temperature(*r.DewpointC))
+	} else if m.DewpointC != nil {
+		a.Units.Dewpoint = set(u.Temperature(float64(*m.DewpointC)))
+	}
+	if p := m.Pressure; p != nil && p.QNHInHg != nil {
+		a.Units.Altimeter = set(u.Pressure(*p.QNHInHg))
+	}
+}
+
+// unitsParam parses the units form value, which is "" if it's missing.
+func unitsParam(r *http.Request) (units.System, error) {
+	if value := r.FormValue("units"); value != "" {
+		return units.ParseSystem(value)
+	}
+	return "", nil
+}
+
+// writeObservations writes value, which holds observations, after putting
+// them in the units the request asks for.
+func writeObservations(w http.ResponseWriter, r *http.Request, value interface{}, observations []*apiObservation) {
+	u, err := unitsParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, a := range observations {
+		a.setUnits(u)
+	}
+	writeJSON(w, value)
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metar/near", s.handleNear)
+	mux.HandleFunc("GET /metar/bbox", s.handleBBox)
+	mux.HandleFunc("GET /metar/{station}/latest", s.handleLatest)
+	mux.HandleFunc("GET /metar/{station}/xwind", s.handleCrosswind)
+	mux.HandleFunc("GET /metar/{station}", s.handleRange)
+	if len(s.metricsStations) > 0 {
+		mux.HandleFunc("GET /metrics", s.handleMetrics)
+	}
+	if s.graphql != nil {
+		mux.Handle("POST /graphql", &relay.Handler{Schema: s.graphql})
+	}
+	return mux
+}
+
+// query returns the observations selected by query, which must select
+// station, observation_time and csv_parts.
+func (s *server) query(ctx context.Context, query sq.SelectBuilder) ([]*apiObservation, error) {
+	rows, err := query.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	observations := []*apiObservation{}
+	for rows.Next() {
+		obs := &metarcsv.Observation{}
+		if err := rows.Scan(&obs.Station, &obs.ObservationTime, s.dialect.ScanArray(&obs.Parts)); err != nil {
+			return nil, err
+		}
+		observations = append(observations, newAPIObservation(obs))
+	}
+	return observations, rows.Err()
+}
+
+func (s *server) selectMetars() sq.SelectBuilder {
+	return s.dialect.Builder.Select("station", "observation_time", "csv_parts").From("metars")
+}
+
+func (s *server) handleLatest(w http.ResponseWriter, r *http.Request) {
+	station := strings.ToUpper(r.PathValue("station"))
+	query := s.selectMetars().Where(sq.Eq{"station": station}).OrderBy("observation_time DESC").Limit(1)
+	if s.latest {
+		query = s.dialect.Builder.Select("station", "observation_time", "csv_parts").From("metars_latest").Where(sq.Eq{"station": station})
+	}
+	observations, err := s.query(r.Context(), query)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if len(observations) == 0 {
+		http.Error(w, fmt.Sprintf("no observations for %s", station), http.StatusNotFound)
+		return
+	}
+	writeObservations(w, r, observations[0], observations)
+}
+
+// handleCrosswind returns the latest wind's components along each runway
+// param, which may be repeated or comma-separated, or along every runway in
+// the runways table if there are none.
+func (s *server) handleCrosswind(w http.ResponseWriter, r *http.Request) {
+	station := strings.ToUpper(r.PathValue("station"))
+	latest, err := newestObservations(r.Context(), s.db, s.dialect, []string{station})
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	obs := latest[station]
+	if obs == nil {
+		http.Error(w, fmt.Sprintf("no observations for %s", station), http.StatusNotFound)
+		return
+	}
+	r.ParseForm()
+	headings, err := runwayHeadings(r.Context(), s.db, s.dialect, station, splitList(r.Form["runway"]))
+	if errors.Is(err, errNoRunways) || errors.Is(err, errUnknownRunway) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		serverError(w, err)
+		return
+	}
+	report, err := newCrosswindReport(obs, headings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeObservations(w, r, report, []*apiObservation{report.Observation})
+}
+
+// handleRange returns a station's observations in [from, to), oldest first.
+// to defaults to now and from to a day before to.
+func (s *server) handleRange(w http.ResponseWriter, r *http.Request) {
+	station := strings.ToUpper(r.PathValue("station"))
+	to := time.Now()
+	if value := r.FormValue("to"); value != "" {
+		var err error
+		if to, err = parseTimeFlag(value); err != nil {
+			http.Error(w, "bad to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if value := r.FormValue("from"); value != "" {
+		var err error
+		if from, err = parseTimeFlag(value); err != nil {
+			http.Error(w, "bad from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := s.limit
+	if value := r.FormValue("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "bad limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, s.limit)
+	}
+	observations, err := s.query(r.Context(), s.selectMetars().
+		Where(sq.Eq{"station": station}).
+		Where(sq.GtOrEq{"observation_time": from}).
+		Where(sq.Lt{"observation_time": to}).
+		OrderBy("observation_time").
+		Limit(uint64(limit)))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeObservations(w, r, observations, observations)
+}
+
+// floatParams parses the named float form values into their pointers.
+// Optional parameters that are missing keep their current value.
+func floatParams(r *http.Request, params ...floatParam) error {
+	for _, p := range params {
+		text := r.FormValue(p.name)
+		if text == "" && p.optional {
+			continue
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("bad %s", p.name)
+		}
+		*p.value = value
+	}
+	return nil
+}
+
+type floatParam struct {
+	name     string
+	value    *float64
+	optional bool
+}
+
+// handleNear returns the latest observation of each station within radius
+// nautical miles of lat, lon, nearest first.  It reads metars_latest, using
+// the latitude and longitude written by -latest -typed-columns, or the
+// location column from sql/postgis.sql if it's there.
+func (s *server) handleNear(w http.ResponseWriter, r *http.Request) {
+	var lat, lon float64
+	radius := 50.0
+	err := floatParams(r, floatParam{"lat", &lat, false}, floatParam{"lon", &lon, false}, floatParam{"radius", &radius, true})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 || radius <= 0 {
+		http.Error(w, "lat, lon or radius out of range", http.StatusBadRequest)
+		return
+	}
+	var observations []*apiObservation
+	if s.postgis {
+		observations, err = s.nearPostGIS(r.Context(), lat, lon, radius)
+	} else {
+		observations, err = s.nearLatLon(r.Context(), lat, lon, radius)
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeObservations(w, r, observations, observations)
+}
+
+// nearPostGIS finds the stations near lat, lon with the location index.
+func (s *server) nearPostGIS(ctx context.Context, lat, lon, radius float64) ([]*apiObservation, error) {
+	const point = "ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography"
+	rows, err := s.dialect.Builder.Select("station", "observation_time", "csv_parts").
+		Column(sq.Expr("ST_Distance(location, "+point+") / ?", lon, lat, calc.MetersPerNM)).
+		From("metars_latest").
+		Where(sq.Expr("ST_DWithin(location, "+point+", ?)", lon, lat, radius*calc.MetersPerNM)).
+		OrderBy("4").
+		Limit(uint64(s.limit)).
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	observations := []*apiObservation{}
+	for rows.Next() {
+		obs := &metarcsv.Observation{}
+		var distance float64
+		if err := rows.Scan(&obs.Station, &obs.ObservationTime, s.dialect.ScanArray(&obs.Parts), &distance); err != nil {
+			return nil, err
+		}
+		a := newAPIObservation(obs)
+		a.DistanceNM = &distance
+		observations = append(observations, a)
+	}
+	return observations, rows.Err()
+}
+
+// nearLatLon finds the stations near lat, lon from the latitude and longitude
+// columns.
+func (s *server) nearLatLon(ctx context.Context, lat, lon, radius float64) ([]*apiObservation, error) {
+	// the box narrows the scan down, and the exact distance is checked below
+	minLat, maxLat, minLon, maxLon, wraps := calc.BoundingBox(lat, lon, radius)
+	query := s.dialect.Builder.Select("station", "observation_time", "csv_parts", "latitude", "longitude").
+		From("metars_latest").
+		Where(sq.GtOrEq{"latitude": minLat}).
+		Where(sq.LtOrEq{"latitude": maxLat})
+	if !wraps {
+		query = query.Where(sq.GtOrEq{"longitude": minLon}).Where(sq.LtOrEq{"longitude": maxLon})
+	}
+	rows, err := query.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	observations := []*apiObservation{}
+	for rows.Next() {
+		obs := &metarcsv.Observation{}
+		var stationLat, stationLon float64
+		if err := rows.Scan(&obs.Station, &obs.ObservationTime, s.dialect.ScanArray(&obs.Parts), &stationLat, &stationLon); err != nil {
+			return nil, err
+		}
+		distance := calc.DistanceNM(lat, lon, stationLat, stationLon)
+		if distance > radius {
+			continue
+		}
+		a := newAPIObservation(obs)
+		a.DistanceNM = &distance
+		observations = append(observations, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(observations, func(i, j int) bool {
+		return *observations[i].DistanceNM < *observations[j].DistanceNM
+	})
+	if len(observations) > s.limit {
+		observations = observations[:s.limit]
+	}
+	return observations, nil
+}
+
+// handleBBox returns the latest observation of each station inside the box,
+// by station.  min_lon may be greater than max_lon for a box that crosses the
+// antimeridian.
+func (s *server) handleBBox(w http.ResponseWriter, r *http.Request) {
+	var minLat, minLon, maxLat, maxLon float64
+	err := floatParams(r, floatParam{"min_lat", &minLat, false}, floatParam{"min_lon", &minLon, false},
+		floatParam{"max_lat", &maxLat, false}, floatParam{"max_lon", &maxLon, false})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if minLat < -90 || maxLat > 90 || minLat > maxLat || minLon < -180 || minLon > 180 || maxLon < -180 || maxLon > 180 {
+		http.Error(w, "box out of range", http.StatusBadRequest)
+		return
+	}
+	query := s.dialect.Builder.Select("station", "observation_time", "csv_parts").
+		From("metars_latest").
+		OrderBy("station").
+		Limit(uint64(s.limit))
+	if s.postgis {
+		const envelope = "location && ST_MakeEnvelope(?, ?, ?, ?, 4326)::geography"
+		if minLon <= maxLon {
+			query = query.Where(sq.Expr(envelope, minLon, minLat, maxLon, maxLat))
+		} else {
+			// split at the antimeridian
+			query = query.Where(sq.Or{
+				sq.Expr(envelope, minLon, minLat, 180, maxLat),
+				sq.Expr(envelope, -180, minLat, maxLon, maxLat),
+			})
+		}
+	} else {
+		query = query.Where(sq.GtOrEq{"latitude": minLat}).Where(sq.LtOrEq{"latitude": maxLat})
+		if minLon <= maxLon {
+			query = query.Where(sq.GtOrEq{"longitude": minLon}).Where(sq.LtOrEq{"longitude": maxLon})
+		} else {
+			query = query.Where(sq.Or{sq.GtOrEq{"longitude": minLon}, sq.LtOrEq{"longitude": maxLon}})
+		}
+	}
+	observations, err := s.query(r.Context(), query)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeObservations(w, r, observations, observations)
+}
+
+// hasPostGIS reports whether metars_latest has the location column added by
+// sql/postgis.sql.
+func hasPostGIS(ctx context.Context, db *sql.DB) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'metars_latest' AND column_name = 'location')").Scan(&exists)
+	return exists, err
+}
+
+// hasLatest reports whether metars_latest has rows.  The other dialects'
+// CreateSchema makes it whether or not -latest is used, so an empty one is
+// taken as not being kept up to date.  It's an error if there's no
+// metars_latest at all, as before sql/002.sql.
+func hasLatest(ctx context.Context, db *sql.DB, d *store.Dialect) (bool, error) {
+	var one int
+	err := d.Builder.Select("1").From("metars_latest").Limit(1).RunWith(db).QueryRowContext(ctx).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func serverError(w http.ResponseWriter, err error) {
+	slog.Error("serving request", "err", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Warn("writing response", "err", err)
+	}
+}
+
+func runServe(ctx context.Context, flags *serveFlags) error {
+	db, d, err := store.Open(flags.dbURL, flags.driver)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+	if d == store.ClickHouse {
+		return errors.New("serve isn't supported for clickhouse")
+	}
+	s := &server{db: db, dialect: d, limit: flags.limit, metricsStations: splitList(flags.metricsStations)}
+	if d == store.Postgres {
+		if s.postgis, err = hasPostGIS(ctx, db); err != nil {
+			return fmt.Errorf("checking for postgis: %w", err)
+		}
+		if s.postgis {
+			slog.Info("using postgis locations")
+		}
+	}
+	if s.latest, err = hasLatest(ctx, db, d); err != nil {
+		slog.Warn("serving latest observations from metars", "err", err)
+	} else if s.latest {
+		slog.Info("serving latest observations from metars_latest")
+	}
+	if flags.graphql {
+		if s.graphql, err = newGraphQLSchema(s); err != nil {
+			return fmt.Errorf("parsing graphql schema: %w", err)
+		}
+	}
+	srv := &http.Server{Addr: flags.listen, Handler: s.routes()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("serving", "addr", flags.listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
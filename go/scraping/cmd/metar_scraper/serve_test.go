@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/store"
+)
+
+func TestHandleLatest(t *testing.T) {
+	db := testDB(t)
+	at := time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC)
+	older := "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992"
+	newer := "KBOS 141254Z 27012KT 10SM FEW050 13/05 A2991"
+	latest := func() string {
+		t.Helper()
+		var err error
+		s := &server{db: db, dialect: store.SQLite, limit: 10}
+		if s.latest, err = hasLatest(context.Background(), db, store.SQLite); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		s.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metar/kbos/latest", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	// without -latest, metars_latest is empty and the archive is read
+	writeAll(t, store.New(db, store.SQLite, nil), testObservation(t, "KBOS", at, older, nil))
+	if got := latest(); !strings.Contains(got, older) {
+		t.Errorf("served %s, want %s from metars", got, older)
+	}
+
+	// once it's kept, it's read instead, even if metars is newer
+	writeAll(t, store.New(db, store.SQLite, &store.Options{Latest: true}), testObservation(t, "KBOS", at, older, nil))
+	writeAll(t, store.New(db, store.SQLite, nil), testObservation(t, "KBOS", at.Add(time.Hour), newer, nil))
+	if got := latest(); !strings.Contains(got, older) {
+		t.Errorf("served %s, want %s from metars_latest", got, older)
+	}
+}
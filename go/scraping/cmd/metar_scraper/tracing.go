@@ -0,0 +1,20 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer makes the spans around each phase of a run.  They're only exported
+// if scraping.InitTracing found an endpoint.
+var tracer = otel.Tracer("mattdee123.com/aviationweather/scraping/cmd/metar_scraper")
+
+// endSpan ends span, marking it failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
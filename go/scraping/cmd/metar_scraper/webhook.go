@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/store"
+)
+
+// webhookEvent is what's posted to each webhook for a watched station's new
+// or corrected observation.
+type webhookEvent struct {
+	// Event is "new" for an observation newer than the station's latest, or
+	// "corrected" for a different report at the latest's time.
+	Event       string          `json:"event"`
+	Station     string          `json:"station"`
+	Observation *apiObservation `json:"observation"`
+}
+
+// webhookStore posts an event to each of urls for every new or corrected
+// observation at stations once a file commits.  What's new is judged against
+// each station's newest row in metars as it was when the file began, plus
+// the file's earlier rows.  Failed posts are logged rather than failing the run, which has
+// already been stored.
+type webhookStore struct {
+	db       *sql.DB
+	dialect  *store.Dialect
+	urls     []string
+	stations []string
+	watched  map[string]bool
+	// latest is each watched station's newest observation so far.
+	latest map[string]*metarcsv.Observation
+	events []*webhookEvent
+}
+
+func (s *webhookStore) Begin(ctx context.Context) error {
+	s.watched = map[string]bool{}
+	for _, station := range s.stations {
+		s.watched[station] = true
+	}
+	s.events = nil
+	latest, err := newestObservations(ctx, s.db, s.dialect, s.stations)
+	if err != nil {
+		return fmt.Errorf("reading latest observations for webhooks: %w", err)
+	}
+	s.latest = latest
+	return nil
+}
+
+// newestObservations returns the newest observation in metars at each of
+// stations that has one.  Unlike metars_latest, it doesn't need -latest.
+func newestObservations(ctx context.Context, db *sql.DB, d *store.Dialect, stations []string) (map[string]*metarcsv.Observation, error) {
+	rows, err := d.Builder.Select("station", "observation_time", "csv_parts").
+		From("metars").
+		Where(sq.Eq{"station": stations}).
+		Where("observation_time = (SELECT max(observation_time) FROM metars newest WHERE newest.station = metars.station)").
+		RunWith(db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	newest := map[string]*metarcsv.Observation{}
+	for rows.Next() {
+		obs := &metarcsv.Observation{}
+		if err := rows.Scan(&obs.Station, &obs.ObservationTime, d.ScanArray(&obs.Parts)); err != nil {
+			return nil, err
+		}
+		newest[obs.Station] = obs
+	}
+	return newest, rows.Err()
+}
+
+func (s *webhookStore) Write(obs *metarcsv.Observation) error {
+	if !s.watched[obs.Station] {
+		return nil
+	}
+	event := "new"
+	if prev := s.latest[obs.Station]; prev != nil {
+		switch {
+		case obs.ObservationTime.Before(prev.ObservationTime):
+			return nil
+		case obs.ObservationTime.Equal(prev.ObservationTime):
+			if obs.Field(metarcsv.ColRawText) == prev.Field(metarcsv.ColRawText) {
+				return nil
+			}
+			event = "corrected"
+		}
+	}
+	s.latest[obs.Station] = obs
+	s.events = append(s.events, &webhookEvent{Event: event, Station: obs.Station, Observation: newAPIObservation(obs)})
+	return nil
+}
+
+func (s *webhookStore) Commit() error {
+	events := s.events
+	s.events = nil
+	for _, event := range events {
+		for _, u := range s.urls {
+			if err := postJSON(u, event); err != nil {
+				slog.Warn("posting webhook", "station", event.Station, "event", event.Event, "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *webhookStore) Rollback() {
+	s.events = nil
+}
+
+// postJSON posts value to url as JSON.
+func postJSON(url string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/calc"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+	"mattdee123.com/aviationweather/scraping"
+	"mattdee123.com/aviationweather/store"
+	"mattdee123.com/aviationweather/units"
+)
+
+type xwindFlags struct {
+	dbURL   string
+	driver  string
+	apiURL  string
+	json    bool
+	units   units.System
+	station string
+	runways []string
+	logging scraping.Logging
+}
+
+func (f *xwindFlags) Parse(args []string) {
+	fs := flag.NewFlagSet("xwind", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database, for the latest observation and the runways table; if unset, the AWC API is asked instead")
+	fs.StringVar(&f.driver, "driver", "postgres", "database driver for -dburl, as for the scraper")
+	fs.StringVar(&f.apiURL, "api-url", awc.MetarAPIURL, "url of the AWC METAR API, used without -dburl")
+	fs.BoolVar(&f.json, "json", false, "if set, print the components as JSON, as the serve command returns them")
+	unitsFlag(fs, &f.units)
+	f.logging.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: metar_scraper xwind [flags] STATION [RUNWAY...]\n"+
+			"prints the latest wind's components along each runway, or every runway in the runways table if none are given\n")
+		fs.PrintDefaults()
+	}
+	scraping.ParseFlags(fs, args)
+	if args := splitList(fs.Args()); len(args) > 0 {
+		f.station, f.runways = args[0], args[1:]
+	}
+}
+
+var (
+	errNoRunways     = errors.New("no runways known")
+	errUnknownRunway = errors.New("unknown runway")
+	errNoWind        = errors.New("no wind reported")
+)
+
+// runwayHeading is a runway end and the direction it points.
+type runwayHeading struct {
+	runway  string
+	heading float64
+	// estimated is set for a heading taken from the runway's number.
+	estimated bool
+}
+
+// runwayHeadings returns the headings of runways at station, or of all of
+// station's runways if runways is empty, from the runways table written by
+// station_scraper -runways-url.  Named runways that aren't in the table, or
+// all of them if db is nil or the table can't be read, get the heading their
+// number implies.
+func runwayHeadings(ctx context.Context, db *sql.DB, d *store.Dialect, station string, runways []string) ([]runwayHeading, error) {
+	runways = slices.Clone(runways)
+	for i, runway := range runways {
+		// the runways table has 04L, not 4L
+		if len(runway) == 1 || len(runway) > 1 && runway[1] > '9' {
+			runways[i] = "0" + runway
+		}
+	}
+	known := map[string]float64{}
+	if db != nil {
+		query := d.Builder.Select("runway", "heading_degrees").From("runways").
+			Where(sq.Eq{"station": station}).
+			Where(sq.NotEq{"heading_degrees": nil})
+		if len(runways) > 0 {
+			query = query.Where(sq.Eq{"runway": runways})
+		}
+		err := func() error {
+			rows, err := query.RunWith(db).QueryContext(ctx)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var runway string
+				var heading float64
+				if err := rows.Scan(&runway, &heading); err != nil {
+					return err
+				}
+				known[runway] = heading
+			}
+			return rows.Err()
+		}()
+		if err != nil && len(runways) == 0 {
+			return nil, fmt.Errorf("reading runways: %w", err)
+		} else if err != nil {
+			slog.Debug("reading runways, using headings from their numbers", "err", err)
+		}
+	}
+	if len(runways) == 0 {
+		for runway := range known {
+			runways = append(runways, runway)
+		}
+		if len(runways) == 0 {
+			return nil, fmt.Errorf("%w at %s; name them, or load them with station_scraper -runways-url", errNoRunways, station)
+		}
+		sort.Strings(runways)
+	}
+	var headings []runwayHeading
+	for _, runway := range runways {
+		if heading, ok := known[runway]; ok {
+			headings = append(headings, runwayHeading{runway: runway, heading: heading})
+			continue
+		}
+		heading, ok := calc.RunwayHeading(runway)
+		if !ok {
+			return nil, fmt.Errorf("%w %s at %s", errUnknownRunway, runway, station)
+		}
+		headings = append(headings, runwayHeading{runway: runway, heading: heading, estimated: true})
+	}
+	return headings, nil
+}
+
+// crosswindReport is a station's latest wind split along its runways.
+type crosswindReport struct {
+	Station     string          `json:"station"`
+	Observation *apiObservation `json:"observation"`
+	// Variable is set for a VRB wind, which doesn't have components.
+	Variable bool         `json:"variable,omitempty"`
+	Runways  []runwayWind `json:"runways"`
+}
+
+// runwayWind is the wind along and across one runway, in knots.  Headwind is
+// negative for a tailwind, and crosswind positive from the right.
+type runwayWind struct {
+	Runway           string   `json:"runway"`
+	HeadingDegrees   float64  `json:"heading_degrees"`
+	HeadingEstimated bool     `json:"heading_estimated,omitempty"`
+	HeadwindKt       *float64 `json:"headwind_kt,omitempty"`
+	CrosswindKt      *float64 `json:"crosswind_kt,omitempty"`
+	GustHeadwindKt   *float64 `json:"gust_headwind_kt,omitempty"`
+	GustCrosswindKt  *float64 `json:"gust_crosswind_kt,omitempty"`
+}
+
+// newCrosswindReport splits obs's wind along each of headings.
+func newCrosswindReport(obs *metarcsv.Observation, headings []runwayHeading) (*crosswindReport, error) {
+	report := &crosswindReport{Station: obs.Station, Observation: newAPIObservation(obs)}
+	if report.Observation.Decoded == nil || report.Observation.Decoded.Wind == nil {
+		return nil, fmt.Errorf("%w in %s's latest observation", errNoWind, obs.Station)
+	}
+	wind := report.Observation.Decoded.Wind
+	report.Variable = wind.DirectionDegrees == nil && wind.Speed > 0
+	for _, h := range headings {
+		rw := runwayWind{Runway: h.runway, HeadingDegrees: h.heading, HeadingEstimated: h.estimated}
+		if !report.Variable {
+			direction := 0.0
+			if wind.DirectionDegrees != nil {
+				direction = float64(*wind.DirectionDegrees)
+			}
+			components := func(speed int) (*float64, *float64) {
+				head, cross := calc.WindComponents(direction, windKnots(wind, speed), h.heading)
+				// adding 0 turns -0 into 0
+				head, cross = units.Round(head, 1)+0, units.Round(cross, 1)+0
+				return &head, &cross
+			}
+			rw.HeadwindKt, rw.CrosswindKt = components(wind.Speed)
+			if wind.Gust != nil {
+				rw.GustHeadwindKt, rw.GustCrosswindKt = components(*wind.Gust)
+			}
+		}
+		report.Runways = append(report.Runways, rw)
+	}
+	return report, nil
+}
+
+// windKnots converts a speed in wind's unit to knots.
+func windKnots(wind *metar.Wind, speed int) float64 {
+	switch wind.Unit {
+	case "MPS":
+		return units.MPSToKnots(float64(speed))
+	case "KMH":
+		return units.KMHToKnots(float64(speed))
+	}
+	return float64(speed)
+}
+
+func runXwind(ctx context.Context, flags *xwindFlags) error {
+	if flags.station == "" {
+		return errors.New("no station given")
+	}
+	var db *sql.DB
+	var d *store.Dialect
+	var latest map[string]*metarcsv.Observation
+	var err error
+	if flags.dbURL != "" {
+		db, d, err = store.Open(flags.dbURL, flags.driver)
+		if err != nil {
+			return fmt.Errorf("connecting to database: %w", err)
+		}
+		defer db.Close()
+		latest, err = newestObservations(ctx, db, d, []string{flags.station})
+	} else {
+		latest, err = latestFromAPI(ctx, flags.apiURL, []string{flags.station})
+	}
+	if err != nil {
+		return fmt.Errorf("finding the latest observation: %w", err)
+	}
+	obs := latest[flags.station]
+	if obs == nil {
+		return fmt.Errorf("no observations for %s", flags.station)
+	}
+	headings, err := runwayHeadings(ctx, db, d, flags.station, flags.runways)
+	if err != nil {
+		return err
+	}
+	report, err := newCrosswindReport(obs, headings)
+	if err != nil {
+		return err
+	}
+	if flags.json {
+		report.Observation.setUnits(flags.units)
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return printCrosswinds(os.Stdout, obs, report, flags.units)
+}
+
+// printCrosswinds writes a table of report's runways, in u or else knots.
+func printCrosswinds(w io.Writer, obs *metarcsv.Observation, report *crosswindReport, u units.System) error {
+	if u == "" {
+		u = units.Aviation
+	}
+	age := time.Since(obs.ObservationTime).Round(time.Minute)
+	fmt.Fprintf(w, "%s at %s (%v ago)\n%s\n", obs.Station, obs.ObservationTime.UTC().Format(time.RFC3339), age, obs.Field(metarcsv.ColRawText))
+	if report.Variable {
+		fmt.Fprintln(w, "the wind is variable, so could be a crosswind or tailwind on any runway")
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  runway\theading\theadwind\tcrosswind")
+	estimated := false
+	for _, rw := range report.Runways {
+		heading := fmt.Sprintf("%03.0f°", rw.HeadingDegrees)
+		if rw.HeadingEstimated {
+			heading = "~" + heading
+			estimated = true
+		}
+		head, cross := "", ""
+		if rw.HeadwindKt != nil {
+			head = headwindText(u, *rw.HeadwindKt, rw.GustHeadwindKt)
+			cross = crosswindText(u, *rw.CrosswindKt, rw.GustCrosswindKt)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", rw.Runway, heading, head, cross)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if estimated {
+		fmt.Fprintln(w, "~ headings are from the runway numbers, so only to within about 10°")
+	}
+	return nil
+}
+
+// componentText formats a wind component's size, and its gusts' if they
+// make a difference.
+func componentText(u units.System, kt float64, gustKt *float64) string {
+	text := u.Speed(math.Abs(kt)).String()
+	if gustKt != nil && u.Speed(math.Abs(*gustKt)).Value > u.Speed(math.Abs(kt)).Value {
+		text += fmt.Sprintf(" (gusts %s)", u.Speed(math.Abs(*gustKt)))
+	}
+	return text
+}
+
+func headwindText(u units.System, kt float64, gustKt *float64) string {
+	text := componentText(u, kt, gustKt)
+	if kt < 0 && u.Speed(-kt).Value > 0 {
+		return "tailwind " + text
+	}
+	return text
+}
+
+func crosswindText(u units.System, kt float64, gustKt *float64) string {
+	text := componentText(u, kt, gustKt)
+	switch {
+	case u.Speed(math.Abs(kt)).Value == 0:
+		return text
+	case kt > 0:
+		return text + " from the right"
+	}
+	return text + " from the left"
+}
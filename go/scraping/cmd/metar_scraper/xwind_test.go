@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestXwindFlagsUpperCase(t *testing.T) {
+	var f xwindFlags
+	f.Parse([]string{"kbos", "22l", "4r,33l"})
+	if f.station != "KBOS" {
+		t.Errorf("station = %q, want KBOS", f.station)
+	}
+	if want := []string{"22L", "4R", "33L"}; !slices.Equal(f.runways, want) {
+		t.Errorf("runways = %q, want %q", f.runways, want)
+	}
+}
+
+func TestRunwayHeadingsFromNumbers(t *testing.T) {
+	headings, err := runwayHeadings(context.Background(), nil, nil, "KBOS", []string{"22L", "4R", "9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []runwayHeading{
+		{runway: "22L", heading: 220, estimated: true},
+		{runway: "04R", heading: 40, estimated: true},
+		{runway: "09", heading: 90, estimated: true},
+	}
+	if !slices.Equal(headings, want) {
+		t.Errorf("headings = %+v, want %+v", headings, want)
+	}
+	if _, err := runwayHeadings(context.Background(), nil, nil, "KBOS", nil); err == nil {
+		t.Error("no runways and no table: got no error")
+	}
+}
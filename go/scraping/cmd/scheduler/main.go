@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"mattdee123.com/aviationweather/scraping"
+)
+
+type Flags struct {
+	config string
+	// shutdownTimeout is how long a job gets to exit after SIGTERM.
+	shutdownTimeout time.Duration
+	logging         scraping.Logging
+}
+
+func (f *Flags) Parse(args []string) {
+	fs := flag.NewFlagSet("scheduler", flag.ExitOnError)
+	fs.StringVar(&f.config, "config", "", "JSON file listing the jobs to run")
+	fs.DurationVar(&f.shutdownTimeout, "shutdown-timeout", time.Minute, "how long running jobs get to exit on shutdown before they're killed")
+	f.logging.AddFlags(fs)
+	fs.Parse(args)
+}
+
+// job is an entry in the -config file, which is a JSON list of jobs like
+//
+//	[
+//	  {"name": "metar", "schedule": "*/10 * * * *", "command": ["dist/metar_scraper", "-dburl", "dbname=weather"]},
+//	  {"name": "stations", "schedule": "15 3 * * *", "command": ["dist/station_scraper", "-dburl", "dbname=weather"]}
+//	]
+//
+// A job whose previous run is still going when it's next due skips that
+// time.  On shutdown, running jobs are sent SIGTERM so that they stop as
+// they would on their own.
+type job struct {
+	Name     string   `json:"name"`
+	Schedule string   `json:"schedule"`
+	Command  []string `json:"command"`
+
+	schedule *scraping.Schedule
+}
+
+func readJobs(fname string) ([]*job, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", fname, err)
+	}
+	for _, j := range jobs {
+		if len(j.Command) == 0 {
+			return nil, fmt.Errorf("job %q has no command", j.Name)
+		}
+		if j.schedule, err = scraping.ParseSchedule(j.Schedule); err != nil {
+			return nil, fmt.Errorf("job %q: %w", j.Name, err)
+		}
+	}
+	return jobs, nil
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// a second signal kills the process as usual
+		<-ctx.Done()
+		stop()
+	}()
+	flags := &Flags{}
+	flags.Parse(os.Args[1:])
+	if err := run(ctx, flags); err != nil {
+		scraping.Exit(err)
+	}
+}
+
+func run(ctx context.Context, flags *Flags) error {
+	jobs, err := readJobs(flags.config)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	if len(jobs) == 0 {
+		return errors.New("no jobs in config")
+	}
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.loop(ctx, flags.shutdownTimeout)
+		}()
+	}
+	wg.Wait()
+	slog.Info("all jobs stopped")
+	return nil
+}
+
+// loop runs j at each time its schedule is due until ctx is cancelled.
+func (j *job) loop(ctx context.Context, shutdownTimeout time.Duration) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Error("schedule never matches", "job", j.Name, "schedule", j.schedule.String())
+			return
+		}
+		slog.Info("next run", "job", j.Name, "at", next)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		j.run(ctx, shutdownTimeout)
+	}
+}
+
+func (j *job) run(ctx context.Context, shutdownTimeout time.Duration) {
+	cmd := exec.CommandContext(ctx, j.Command[0], j.Command[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = shutdownTimeout
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		slog.Error("job failed", "job", j.Name, "duration", elapsed, "err", err)
+		return
+	}
+	slog.Info("job done", "job", j.Name, "duration", elapsed)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	sq "github.com/Masterminds/squirrel"
+	pq "github.com/lib/pq"
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/scraping"
+)
+
+const stationURL = "https://www.aviationweather.gov/adds/dataserver_current/current/stations.cache.csv.gz"
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// requiredColumns are the columns the scraper reads by name.  The rest of the
+// header isn't checked, so upstream adding or reordering columns is fine.
+var requiredColumns = []string{"station_id", "site", "latitude", "longitude", "elevation_m", "state", "country", "site_type"}
+
+type Flags struct {
+	dbURL           string
+	url             string
+	filename        string
+	download        bool
+	deleteFile      bool
+	downloader      awc.Downloader
+	lenientPreamble bool
+	runwaysURL      string
+	logging         scraping.Logging
+}
+
+func (f *Flags) Parse(args []string) {
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.url, "url", stationURL, "url to download from; .gz and .zst are both supported")
+	fs.StringVar(&f.filename, "filename", "", "file to download to and read from; if unset with -download, the download is streamed straight into the database")
+	fs.BoolVar(&f.download, "download", true, "if set, file will be downloaded")
+	fs.BoolVar(&f.deleteFile, "delete", true, "if set, file will be deleted on success")
+	f.downloader.AddFlags(fs)
+	fs.BoolVar(&f.lenientPreamble, "lenient-preamble", false, "if set, log unexpected lines before the header, like upstream warnings, instead of failing the run")
+	fs.StringVar(&f.runwaysURL, "runways-url", "", "if set, also replace the runways table with the runways.csv at this url, like "+runwaysURL)
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// a second signal kills the process as usual
+		<-ctx.Done()
+		stop()
+	}()
+	flags := &Flags{}
+	flags.Parse(os.Args[1:])
+	if err := run(ctx, flags); err != nil {
+		scraping.Exit(err)
+	}
+}
+
+func run(ctx context.Context, flags *Flags) error {
+	input, err := flags.downloader.Input(ctx, flags.download, flags.url, flags.filename)
+	if errors.Is(err, awc.ErrNotModified) {
+		slog.Info("unchanged since the last run, skipping", "url", flags.url)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	db, err := sql.Open("postgres", flags.dbURL)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	if err := readToDB(ctx, db, input, flags.lenientPreamble); err != nil {
+		if ctx.Err() != nil && flags.download && flags.deleteFile && flags.filename != "" {
+			// it would have been removed on success
+			os.Remove(flags.filename)
+		}
+		return fmt.Errorf("storing in database: %w", err)
+	}
+	if flags.runwaysURL != "" {
+		body, err := flags.downloader.Open(ctx, flags.runwaysURL)
+		if err != nil {
+			return fmt.Errorf("downloading runways: %w", err)
+		}
+		defer body.Close()
+		if err := loadRunways(ctx, db, body); err != nil {
+			return fmt.Errorf("storing runways: %w", err)
+		}
+	}
+	if err := flags.downloader.Archive(ctx); err != nil {
+		return err
+	}
+	if err := flags.downloader.SaveValidators(); err != nil {
+		return fmt.Errorf("saving validators: %w", err)
+	}
+	if flags.deleteFile && flags.filename != "" {
+		if err := os.Remove(flags.filename); err != nil {
+			return fmt.Errorf("removing file: %w", err)
+		}
+	}
+	return nil
+}
+
+func readToDB(ctx context.Context, db *sql.DB, r io.Reader, lenientPreamble bool) error {
+	scanner := bufio.NewScanner(r)
+	columns, err := awc.ReadHeader(scanner, "stations", lenientPreamble, requiredColumns...)
+	if err != nil {
+		return fmt.Errorf("bad headers: %w", err)
+	}
+	index := awc.ColumnIndex(columns)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	count := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("shutting down, rolling back %d stations: %w", count, err)
+		}
+		text := strings.ReplaceAll(scanner.Text(), "\x00", "")
+		written, err := writeLine(tx, index, text)
+		if err != nil {
+			return fmt.Errorf("writing line %q: %w", text, err)
+		}
+		if written {
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	slog.Info("wrote stations", "rows", count)
+	return nil
+}
+
+// nullableNumber parses value as a number, returning nil for an empty one.
+func nullableNumber(value string) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// writeLine upserts the station in text.  It returns false if the line was
+// skipped as invalid.
+func writeLine(tx *sql.Tx, index map[string]int, text string) (bool, error) {
+	parts, err := csv.NewReader(strings.NewReader(text)).Read()
+	if err != nil {
+		return false, fmt.Errorf("parsing line: %w", err)
+	}
+	field := func(name string) string {
+		if i := index[name]; i < len(parts) {
+			return parts[i]
+		}
+		return ""
+	}
+	// sometimes there's a cut-off line.  some rough heuristics to catch this
+	if len(parts) < len(index) || field("station_id") == "" {
+		slog.Debug("invalid line", "text", text)
+		return false, nil
+	}
+	row := map[string]interface{}{
+		"station":   field("station_id"),
+		"site":      field("site"),
+		"state":     field("state"),
+		"country":   field("country"),
+		"site_type": pq.StringArray(strings.Fields(field("site_type"))),
+		"csv_parts": pq.StringArray(parts),
+	}
+	for _, col := range []string{"latitude", "longitude", "elevation_m"} {
+		value, err := nullableNumber(field(col))
+		if err != nil {
+			return false, fmt.Errorf("bad %s %q: %w", col, field(col), err)
+		}
+		row[col] = value
+	}
+	_, err = psql.Insert("stations").SetMap(row).
+		Suffix("ON CONFLICT (station) DO UPDATE set site=EXCLUDED.site, latitude=EXCLUDED.latitude, longitude=EXCLUDED.longitude, elevation_m=EXCLUDED.elevation_m, state=EXCLUDED.state, country=EXCLUDED.country, site_type=EXCLUDED.site_type, csv_parts=EXCLUDED.csv_parts").
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// runwaysURL is OurAirports' runway list, which -runways-url suggests.
+const runwaysURL = "https://davidmegginson.github.io/ourairports-data/runways.csv"
+
+// runwayColumns are the columns of runways.csv that are read.  Each runway
+// has a low-numbered (le_) and high-numbered (he_) end.
+var runwayColumns = []string{"airport_ident", "length_ft", "closed", "le_ident", "le_heading_degT", "he_ident", "he_heading_degT"}
+
+// loadRunways replaces the runways table with the open runways in r, an
+// OurAirports runways.csv.
+func loadRunways(ctx context.Context, db *sql.DB, r io.Reader) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[name] = i
+	}
+	for _, name := range runwayColumns {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("header %q is missing column %q", header, name)
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM runways"); err != nil {
+		return fmt.Errorf("clearing runways: %w", err)
+	}
+	count := 0
+	for {
+		parts, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("reading runways: %w", err)
+		}
+		field := func(name string) string {
+			if i := index[name]; i < len(parts) {
+				return parts[i]
+			}
+			return ""
+		}
+		if field("airport_ident") == "" || field("closed") == "1" {
+			continue
+		}
+		length, err := nullableNumber(field("length_ft"))
+		if err != nil {
+			slog.Debug("bad runway length", "station", field("airport_ident"), "length_ft", field("length_ft"))
+			length = nil
+		}
+		for _, end := range []string{"le_", "he_"} {
+			runway := field(end + "ident")
+			if runway == "" {
+				continue
+			}
+			heading, err := nullableNumber(field(end + "heading_degT"))
+			if err != nil {
+				heading = nil
+			}
+			_, err = psql.Insert("runways").
+				Columns("station", "runway", "heading_degrees", "length_ft").
+				Values(field("airport_ident"), runway, heading, length).
+				Suffix("ON CONFLICT (station, runway) DO NOTHING").
+				RunWith(tx).ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("writing %s runway %s: %w", field("airport_ident"), runway, err)
+			}
+			count++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	slog.Info("wrote runways", "rows", count)
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	pq "github.com/lib/pq"
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/scraping"
+)
+
+const tafURL = "https://www.aviationweather.gov/adds/dataserver_current/current/tafs.cache.csv.gz"
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+var tafPreamble = awc.Preamble("tafs")
+
+// tafHeader matches the column header.  The forecast-period columns starting
+// at fcst_time_from are repeated once per period, so only the fixed columns
+// and the start of the first period are checked; the rest is read by name.
+var tafHeader = regexp.MustCompile("^raw_text,station_id,issue_time,bulletin_time,valid_time_from,valid_time_to,remarks,latitude,longitude,elevation_m,fcst_time_from,")
+
+// periodStart is the first column of each forecast period.
+const periodStart = "fcst_time_from"
+
+type Flags struct {
+	dbURL           string
+	url             string
+	filename        string
+	download        bool
+	deleteFile      bool
+	downloader      awc.Downloader
+	lenientPreamble bool
+	logging         scraping.Logging
+}
+
+func (f *Flags) Parse(args []string) {
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.url, "url", tafURL, "url to download from; .gz and .zst are both supported")
+	fs.StringVar(&f.filename, "filename", "", "file to download to and read from; if unset with -download, the download is streamed straight into the database")
+	fs.BoolVar(&f.download, "download", true, "if set, file will be downloaded")
+	fs.BoolVar(&f.deleteFile, "delete", true, "if set, file will be deleted on success")
+	f.downloader.AddFlags(fs)
+	fs.BoolVar(&f.lenientPreamble, "lenient-preamble", false, "if set, log unexpected lines before the header, like upstream warnings, instead of failing the run")
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// a second signal kills the process as usual
+		<-ctx.Done()
+		stop()
+	}()
+	flags := &Flags{}
+	flags.Parse(os.Args[1:])
+	if err := run(ctx, flags); err != nil {
+		scraping.Exit(err)
+	}
+}
+
+func run(ctx context.Context, flags *Flags) error {
+	input, err := flags.downloader.Input(ctx, flags.download, flags.url, flags.filename)
+	if errors.Is(err, awc.ErrNotModified) {
+		slog.Info("unchanged since the last run, skipping", "url", flags.url)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	db, err := sql.Open("postgres", flags.dbURL)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	if err := readToDB(ctx, db, input, flags.lenientPreamble); err != nil {
+		if ctx.Err() != nil && flags.download && flags.deleteFile && flags.filename != "" {
+			// it would have been removed on success
+			os.Remove(flags.filename)
+		}
+		return fmt.Errorf("storing in database: %w", err)
+	}
+	if err := flags.downloader.Archive(ctx); err != nil {
+		return err
+	}
+	if err := flags.downloader.SaveValidators(); err != nil {
+		return fmt.Errorf("saving validators: %w", err)
+	}
+	if flags.deleteFile && flags.filename != "" {
+		if err := os.Remove(flags.filename); err != nil {
+			return fmt.Errorf("removing file: %w", err)
+		}
+	}
+	return nil
+}
+
+func readToDB(ctx context.Context, db *sql.DB, r io.Reader, lenientPreamble bool) error {
+	scanner := bufio.NewScanner(r)
+	// TAF lines carry every forecast period, so they can be much longer than
+	// bufio's 64k default
+	scanner.Buffer(nil, 1<<20)
+	if err := awc.CheckPreamble(tafPreamble, scanner, lenientPreamble); err != nil {
+		return fmt.Errorf("bad headers: %w", err)
+	}
+	if !scanner.Scan() {
+		return fmt.Errorf("scan error while looking for header: %w", scanner.Err())
+	}
+	header := scanner.Text()
+	if !tafHeader.MatchString(header) {
+		return fmt.Errorf("expected %v, got %q", tafHeader, header)
+	}
+	layout := newLayout(strings.Split(header, ","))
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	count := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("shutting down, rolling back %d tafs: %w", count, err)
+		}
+		text := strings.ReplaceAll(scanner.Text(), "\x00", "")
+		written, err := writeLine(tx, layout, text)
+		if err != nil {
+			return fmt.Errorf("writing line %q: %w", text, err)
+		}
+		if written {
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	slog.Info("wrote tafs", "rows", count)
+	return nil
+}
+
+// layout describes where things are in a line, from the header.
+type layout struct {
+	index map[string]int
+	// periods holds the columns of each forecast period: the index of each
+	// column, and its name, with repeated names (sky_cover etc.) suffixed
+	// _2, _3, ...
+	periods [][]periodColumn
+}
+
+type periodColumn struct {
+	index int
+	name  string
+}
+
+func newLayout(columns []string) *layout {
+	l := &layout{index: map[string]int{}}
+	var seen map[string]int
+	for i, name := range columns {
+		if name == periodStart {
+			l.periods = append(l.periods, nil)
+			seen = map[string]int{}
+		}
+		if l.periods == nil {
+			if _, ok := l.index[name]; !ok {
+				l.index[name] = i
+			}
+			continue
+		}
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+		last := len(l.periods) - 1
+		l.periods[last] = append(l.periods[last], periodColumn{i, name})
+	}
+	return l
+}
+
+// field returns the fixed column called name, or "" if the line is short.
+func (l *layout) field(parts []string, name string) string {
+	i, ok := l.index[name]
+	if !ok || i >= len(parts) {
+		return ""
+	}
+	return parts[i]
+}
+
+// forecasts returns each forecast period in parts as a map of its non-empty
+// columns.  Periods with no values are left out; the header always has room
+// for more periods than most TAFs use.
+func (l *layout) forecasts(parts []string) []map[string]string {
+	var forecasts []map[string]string
+	for _, period := range l.periods {
+		forecast := map[string]string{}
+		for _, col := range period {
+			if col.index < len(parts) && parts[col.index] != "" {
+				forecast[col.name] = parts[col.index]
+			}
+		}
+		if len(forecast) > 0 {
+			forecasts = append(forecasts, forecast)
+		}
+	}
+	return forecasts
+}
+
+// writeLine upserts the TAF in text.  It returns false if the line was
+// skipped as invalid.
+func writeLine(tx *sql.Tx, l *layout, text string) (bool, error) {
+	parts, err := csv.NewReader(strings.NewReader(text)).Read()
+	if err != nil {
+		return false, fmt.Errorf("parsing line: %w", err)
+	}
+	// sometimes there's a cut-off line.  some rough heuristics to catch this
+	if len(parts) < 6 || len(parts[0]) < 5 {
+		slog.Debug("invalid line", "text", text)
+		return false, nil
+	}
+	row := map[string]interface{}{
+		"station":   l.field(parts, "station_id"),
+		"csv_parts": pq.StringArray(parts),
+	}
+	for _, col := range []string{"issue_time", "valid_time_from", "valid_time_to"} {
+		value := l.field(parts, col)
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return false, fmt.Errorf("bad %s %q: %w", col, value, err)
+		}
+		row[col] = t
+	}
+	forecasts, err := json.Marshal(l.forecasts(parts))
+	if err != nil {
+		return false, fmt.Errorf("encoding forecasts: %w", err)
+	}
+	row["forecasts"] = string(forecasts)
+	_, err = psql.Insert("tafs").SetMap(row).
+		Suffix("ON CONFLICT (station, issue_time) DO UPDATE set valid_time_from=EXCLUDED.valid_time_from, valid_time_to=EXCLUDED.valid_time_to, csv_parts=EXCLUDED.csv_parts, forecasts=EXCLUDED.forecasts").
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
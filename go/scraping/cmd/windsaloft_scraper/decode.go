@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// forecast is one station and altitude from an FB winds and temperatures
+// aloft product.
+type forecast struct {
+	station  string
+	altitude int
+	// group is the undecoded forecast, e.g. 2315+14
+	group     string
+	basedOn   time.Time
+	validTime time.Time
+	useFrom   time.Time
+	useTo     time.Time
+	// direction is nil for light and variable winds
+	direction *int
+	speed     int
+	// temperature is nil where it isn't forecast, e.g. at 3000 ft
+	temperature *int
+}
+
+var (
+	basedOnLine = regexp.MustCompile(`^DATA BASED ON (\d{2})(\d{2})(\d{2})Z`)
+	validLine   = regexp.MustCompile(`^VALID (\d{2})(\d{2})(\d{2})Z\s+FOR USE (\d{2})(\d{2})-(\d{2})(\d{2})Z`)
+	stationID   = regexp.MustCompile(`^[A-Z0-9]{3,4}$`)
+)
+
+// column is an altitude in the FT header line, and the position its values
+// are right-aligned to.
+type column struct {
+	altitude int
+	end      int
+}
+
+// decode parses an FB product.  The product only gives day of month and time,
+// so times are placed in the month nearest to now.  A text with several
+// products (e.g. one per region) is fine; each VALID and FT line applies to
+// the stations after it.
+func decode(text string, now time.Time) ([]forecast, error) {
+	var (
+		forecasts                []forecast
+		basedOn, valid, from, to time.Time
+		columns                  []column
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if m := basedOnLine.FindStringSubmatch(line); m != nil {
+			basedOn = nearestDay(now, atoi(m[1]), atoi(m[2]), atoi(m[3]))
+			continue
+		}
+		if m := validLine.FindStringSubmatch(line); m != nil {
+			valid = nearestDay(now, atoi(m[1]), atoi(m[2]), atoi(m[3]))
+			from = atOrBefore(valid, atoi(m[4]), atoi(m[5]))
+			to = atOrBefore(from.Add(24*time.Hour), atoi(m[6]), atoi(m[7]))
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "FT" {
+			var err error
+			if columns, err = parseColumns(line); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if columns == nil || valid.IsZero() || !stationID.MatchString(fields[0]) {
+			continue
+		}
+		station := fields[0]
+		for _, token := range tokens(line[len(station):], len(station)) {
+			altitude, ok := nearestColumn(columns, token.end)
+			if !ok {
+				return nil, fmt.Errorf("station %s: group %q doesn't line up with the header", station, token.text)
+			}
+			f := forecast{
+				station:   station,
+				altitude:  altitude,
+				group:     token.text,
+				basedOn:   basedOn,
+				validTime: valid,
+				useFrom:   from,
+				useTo:     to,
+			}
+			if err := f.decodeGroup(); err != nil {
+				return nil, fmt.Errorf("station %s at %d ft: %w", station, altitude, err)
+			}
+			forecasts = append(forecasts, f)
+		}
+	}
+	return forecasts, nil
+}
+
+func parseColumns(line string) ([]column, error) {
+	var columns []column
+	for _, token := range tokens(line, 0)[1:] {
+		altitude, err := strconv.Atoi(token.text)
+		if err != nil {
+			return nil, fmt.Errorf("bad altitude %q in header", token.text)
+		}
+		columns = append(columns, column{altitude, token.end})
+	}
+	return columns, nil
+}
+
+type token struct {
+	text string
+	end  int
+}
+
+// tokens splits line on spaces, recording where each token ends.  offset is
+// added to the positions.
+func tokens(line string, offset int) []token {
+	var result []token
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && line[i] != ' ' {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			result = append(result, token{line[start:i], offset + i})
+			start = -1
+		}
+	}
+	return result
+}
+
+// nearestColumn returns the altitude whose header ends closest to end.
+// Values are right-aligned under the header, but allow a little slop.
+func nearestColumn(columns []column, end int) (int, bool) {
+	for _, c := range columns {
+		if d := c.end - end; d >= -1 && d <= 1 {
+			return c.altitude, true
+		}
+	}
+	return 0, false
+}
+
+// decodeGroup decodes f.group: DDff, DDff+TT, DDff-TT, or DDffTT, where the
+// last is above 24000 ft and implicitly negative.  9900 is light and
+// variable, and a direction of 51-86 means 100 kt has been subtracted from
+// the speed and 50 added to the direction.
+func (f *forecast) decodeGroup() error {
+	g := f.group
+	if len(g) < 4 {
+		return fmt.Errorf("bad group %q", g)
+	}
+	dir, err1 := strconv.Atoi(g[:2])
+	speed, err2 := strconv.Atoi(g[2:4])
+	if err1 != nil || err2 != nil {
+		return fmt.Errorf("bad group %q", g)
+	}
+	if temp := g[4:]; temp != "" {
+		t, err := strconv.Atoi(temp)
+		if err != nil {
+			return fmt.Errorf("bad temperature in group %q", g)
+		}
+		if temp[0] != '+' && temp[0] != '-' {
+			t = -t
+		}
+		f.temperature = &t
+	}
+	if dir == 99 && speed == 0 {
+		return nil
+	}
+	if dir > 50 {
+		dir -= 50
+		speed += 100
+	}
+	dir *= 10
+	f.direction = &dir
+	f.speed = speed
+	return nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// nearestDay returns the time on the given day of month nearest to now,
+// looking at the previous, current, and next months.
+func nearestDay(now time.Time, day, hour, minute int) time.Time {
+	var best time.Time
+	for _, months := range []int{-1, 0, 1} {
+		t := time.Date(now.Year(), now.Month()+time.Month(months), day, hour, minute, 0, 0, time.UTC)
+		if t.Day() != day {
+			// the day doesn't exist in that month
+			continue
+		}
+		if best.IsZero() || t.Sub(now).Abs() < best.Sub(now).Abs() {
+			best = t
+		}
+	}
+	return best
+}
+
+// atOrBefore returns the latest time at hour:minute that is no later than t.
+func atOrBefore(t time.Time, hour, minute int) time.Time {
+	result := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, time.UTC)
+	if result.After(t) {
+		result = result.Add(-24 * time.Hour)
+	}
+	return result
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// product is an FB excerpt for the period just after midnight at the turn of
+// a month, with ABI's 3000 ft column blank, as it is for stations whose
+// elevation is above it.
+const product = `000
+FBUS31 KWNO 311359
+FD1US1
+DATA BASED ON 311200Z
+VALID 010000Z   FOR USE 1800-0300Z. TEMPS NEG ABV 24000
+
+FT  3000    6000    9000   12000   18000   24000  30000  34000  39000
+ABI      2214+17 2419+12 2526+06 2545-08 2563-20 258735 750145 739952
+ALB 9900 2718+08 2826+02 2835-04 2852-17 2868-29 288844 780052 780157
+`
+
+// want is a decoded forecast's fields, with -1 for a nil direction or
+// temperature.
+type want struct {
+	altitude, direction, speed, temperature int
+}
+
+func TestDecode(t *testing.T) {
+	now := time.Date(2026, 10, 31, 14, 0, 0, 0, time.UTC)
+	forecasts, err := decode(product, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wants := map[string][]want{
+		"ABI": {
+			{6000, 220, 14, 17},
+			{9000, 240, 19, 12},
+			{12000, 250, 26, 6},
+			{18000, 250, 45, -8},
+			{24000, 250, 63, -20},
+			// implicitly negative above 24000 ft
+			{30000, 250, 87, -35},
+			// 100 kt more, with 50 added to the direction
+			{34000, 250, 101, -45},
+			{39000, 230, 199, -52},
+		},
+		"ALB": {
+			// light and variable, and no temperature at 3000 ft
+			{3000, -1, 0, -1},
+			{6000, 270, 18, 8},
+			{9000, 280, 26, 2},
+			{12000, 280, 35, -4},
+			{18000, 280, 52, -17},
+			{24000, 280, 68, -29},
+			{30000, 280, 88, -44},
+			{34000, 280, 100, -52},
+			{39000, 280, 101, -57},
+		},
+	}
+	got := map[string][]want{}
+	for _, f := range forecasts {
+		w := want{f.altitude, -1, f.speed, -1}
+		if f.direction != nil {
+			w.direction = *f.direction
+		}
+		if f.temperature != nil {
+			w.temperature = *f.temperature
+		}
+		got[f.station] = append(got[f.station], w)
+
+		// the product is based on the last day of October, and valid and
+		// used across midnight into November
+		if want := time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC); !f.basedOn.Equal(want) {
+			t.Errorf("%s at %d ft: based on %v, want %v", f.station, f.altitude, f.basedOn, want)
+		}
+		if want := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC); !f.validTime.Equal(want) {
+			t.Errorf("%s at %d ft: valid %v, want %v", f.station, f.altitude, f.validTime, want)
+		}
+		if want := time.Date(2026, 10, 31, 18, 0, 0, 0, time.UTC); !f.useFrom.Equal(want) {
+			t.Errorf("%s at %d ft: for use from %v, want %v", f.station, f.altitude, f.useFrom, want)
+		}
+		if want := time.Date(2026, 11, 1, 3, 0, 0, 0, time.UTC); !f.useTo.Equal(want) {
+			t.Errorf("%s at %d ft: for use to %v, want %v", f.station, f.altitude, f.useTo, want)
+		}
+	}
+	for station, want := range wants {
+		if len(got[station]) != len(want) {
+			t.Errorf("%s: decoded %v, want %v", station, got[station], want)
+			continue
+		}
+		for i := range want {
+			if got[station][i] != want[i] {
+				t.Errorf("%s: decoded %v, want %v", station, got[station][i], want[i])
+			}
+		}
+	}
+}
+
+func TestDecodeAlignment(t *testing.T) {
+	now := time.Date(2026, 10, 31, 14, 0, 0, 0, time.UTC)
+	header := strings.Join(strings.Split(product, "\n")[:7], "\n") + "\n"
+	for _, test := range []struct {
+		name, line string
+		altitude   int
+		ok         bool
+	}{
+		{"aligned", "ABI      2214+17", 6000, true},
+		{"one short", "ABI     2214+17", 6000, true},
+		{"one over", "ABI       2214+17", 6000, true},
+		{"between columns", "ABI         2214+17", 0, false},
+	} {
+		forecasts, err := decode(header+test.line+"\n", now)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("%s: decoded %v, want an error", test.name, forecasts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if len(forecasts) != 1 || forecasts[0].altitude != test.altitude {
+			t.Errorf("%s: decoded %v, want one at %d ft", test.name, forecasts, test.altitude)
+		}
+	}
+}
+
+func TestDecodeGroup(t *testing.T) {
+	for _, test := range []struct {
+		group string
+		want  want
+	}{
+		{"2312", want{0, 230, 12, -1}},
+		{"9900", want{0, -1, 0, -1}},
+		{"9900+05", want{0, -1, 0, 5}},
+		{"2214+17", want{0, 220, 14, 17}},
+		{"2545-08", want{0, 250, 45, -8}},
+		{"258735", want{0, 250, 87, -35}},
+		{"7399-52", want{0, 230, 199, -52}},
+		{"510060", want{0, 10, 100, -60}},
+	} {
+		f := forecast{group: test.group}
+		if err := f.decodeGroup(); err != nil {
+			t.Errorf("%s: %v", test.group, err)
+			continue
+		}
+		got := want{0, -1, f.speed, -1}
+		if f.direction != nil {
+			got.direction = *f.direction
+		}
+		if f.temperature != nil {
+			got.temperature = *f.temperature
+		}
+		if got != test.want {
+			t.Errorf("%s: decoded %v, want %v", test.group, got, test.want)
+		}
+	}
+	for _, group := range []string{"23", "23A2", "2312+X5"} {
+		f := forecast{group: group}
+		if err := f.decodeGroup(); err == nil {
+			t.Errorf("%s: got no error", group)
+		}
+	}
+}
+
+func TestNearestDay(t *testing.T) {
+	for _, test := range []struct {
+		now  time.Time
+		day  int
+		want time.Time
+	}{
+		{time.Date(2026, 10, 14, 14, 0, 0, 0, time.UTC), 14, time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+		// into the next month, or back into the last
+		{time.Date(2026, 10, 31, 22, 0, 0, 0, time.UTC), 1, time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 11, 1, 2, 0, 0, 0, time.UTC), 31, time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC)},
+		// and the year
+		{time.Date(2026, 12, 31, 22, 0, 0, 0, time.UTC), 1, time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC)},
+		// skipping months without the day
+		{time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC), 30, time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)},
+	} {
+		if got := nearestDay(test.now, test.day, 12, 0); !got.Equal(test.want) {
+			t.Errorf("day %d nearest %v: %v, want %v", test.day, test.now, got, test.want)
+		}
+	}
+}
+
+func TestAtOrBefore(t *testing.T) {
+	valid := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		t            time.Time
+		hour, minute int
+		want         time.Time
+	}{
+		{valid, 18, 0, time.Date(2026, 10, 31, 18, 0, 0, 0, time.UTC)},
+		{valid, 0, 0, valid},
+		{valid.Add(21 * time.Hour), 21, 0, valid.Add(21 * time.Hour)},
+		{valid.Add(18 * time.Hour), 3, 0, valid.Add(3 * time.Hour)},
+	} {
+		if got := atOrBefore(test.t, test.hour, test.minute); !got.Equal(test.want) {
+			t.Errorf("%02d%02d at or before %v: %v, want %v", test.hour, test.minute, test.t, got, test.want)
+		}
+	}
+}
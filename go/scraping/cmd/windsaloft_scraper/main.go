@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/lib/pq"
+	"mattdee123.com/aviationweather/awc"
+	"mattdee123.com/aviationweather/scraping"
+)
+
+// windsURL is the FB winds and temperatures aloft text product for every
+// region, for the low altitudes and 6 hour forecast.
+const windsURL = "https://aviationweather.gov/api/data/windtemp?region=all&level=low&fcst=06"
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+type Flags struct {
+	dbURL      string
+	url        string
+	filename   string
+	download   bool
+	deleteFile bool
+	downloader awc.Downloader
+	logging    scraping.Logging
+}
+
+func (f *Flags) Parse(args []string) {
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	fs.StringVar(&f.dbURL, "dburl", "", "url or connection string to the database")
+	fs.StringVar(&f.url, "url", windsURL, "url to download from; .gz and .zst are decompressed, anything else is read as text")
+	fs.StringVar(&f.filename, "filename", "", "file to download to and read from; if unset with -download, the download is streamed straight into the database")
+	fs.BoolVar(&f.download, "download", true, "if set, file will be downloaded")
+	fs.BoolVar(&f.deleteFile, "delete", true, "if set, file will be deleted on success")
+	f.downloader.AddFlags(fs)
+	f.logging.AddFlags(fs)
+	scraping.ParseFlags(fs, args)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// a second signal kills the process as usual
+		<-ctx.Done()
+		stop()
+	}()
+	flags := &Flags{}
+	flags.Parse(os.Args[1:])
+	if err := run(ctx, flags); err != nil {
+		scraping.Exit(err)
+	}
+}
+
+func run(ctx context.Context, flags *Flags) error {
+	input, err := flags.downloader.Input(ctx, flags.download, flags.url, flags.filename)
+	if errors.Is(err, awc.ErrNotModified) {
+		slog.Info("unchanged since the last run, skipping", "url", flags.url)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	db, err := sql.Open("postgres", flags.dbURL)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer db.Close()
+
+	if err := readToDB(ctx, db, input); err != nil {
+		if ctx.Err() != nil && flags.download && flags.deleteFile && flags.filename != "" {
+			// it would have been removed on success
+			os.Remove(flags.filename)
+		}
+		return fmt.Errorf("storing in database: %w", err)
+	}
+	if err := flags.downloader.Archive(ctx); err != nil {
+		return err
+	}
+	if err := flags.downloader.SaveValidators(); err != nil {
+		return fmt.Errorf("saving validators: %w", err)
+	}
+	if flags.deleteFile && flags.filename != "" {
+		if err := os.Remove(flags.filename); err != nil {
+			return fmt.Errorf("removing file: %w", err)
+		}
+	}
+	return nil
+}
+
+func readToDB(ctx context.Context, db *sql.DB, r io.Reader) error {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading: %w", err)
+	}
+	forecasts, err := decode(string(text), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("decoding: %w", err)
+	}
+	if len(forecasts) == 0 {
+		return errors.New("no forecasts found")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for i, f := range forecasts {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("shutting down, rolling back %d forecasts: %w", i, err)
+		}
+		if err := writeForecast(tx, f); err != nil {
+			return fmt.Errorf("writing %s at %d ft: %w", f.station, f.altitude, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	slog.Info("wrote winds aloft forecasts", "rows", len(forecasts))
+	return nil
+}
+
+func writeForecast(tx *sql.Tx, f forecast) error {
+	row := map[string]interface{}{
+		"station":          f.station,
+		"valid_time":       f.validTime,
+		"altitude_ft":      f.altitude,
+		"based_on":         f.basedOn,
+		"use_from":         f.useFrom,
+		"use_to":           f.useTo,
+		"wind_group":       f.group,
+		"wind_dir_degrees": f.direction,
+		"wind_speed_kt":    f.speed,
+		"temp_c":           f.temperature,
+	}
+	_, err := psql.Insert("winds_aloft").SetMap(row).
+		Suffix("ON CONFLICT (station, valid_time, altitude_ft) DO UPDATE set based_on=EXCLUDED.based_on, use_from=EXCLUDED.use_from, use_to=EXCLUDED.use_to, wind_group=EXCLUDED.wind_group, wind_dir_degrees=EXCLUDED.wind_dir_degrees, wind_speed_kt=EXCLUDED.wind_speed_kt, temp_c=EXCLUDED.temp_c").
+		RunWith(tx).
+		Exec()
+	return err
+}
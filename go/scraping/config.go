@@ -0,0 +1,129 @@
+// Package scraping has the command-line plumbing shared by the scrapers:
+// flags and config files, logging, tracing, schedules and exiting.
+package scraping
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseFlags parses args like fs.Parse, adding a -config flag naming a YAML
+// or TOML file of flag values to fall back on.  Its keys are flag names,
+// like
+//
+//	dburl: postgres://localhost/weather
+//	batch-size: 1000
+//	daemon: true
+//	serve:
+//	  listen: :8080
+//
+// and a list sets a repeatable flag once per element.  A subcommand's flag
+// set, named after it, reads the table with its name, falling back on the
+// top-level keys it has flags for; the main command, whose flag set has no
+// name, reads the top-level keys and fails on any it doesn't know.  Flags on
+// the command line override the file.  A -dburl that neither sets comes
+// from -dburl-file or $DATABASE_URL.  fs should be made with
+// flag.ExitOnError, as a bad file exits the same way.
+func ParseFlags(fs *flag.FlagSet, args []string) {
+	config := fs.String("config", "", "YAML or TOML `file` of flag values, keyed by flag name; flags on the command line override it")
+	dbURLFile := addDBURLFile(fs)
+	fs.Parse(args)
+	if *config != "" {
+		if err := applyConfig(fs, *config); err != nil {
+			fmt.Fprintf(fs.Output(), "reading -config: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if err := setDBURL(fs, dbURLFile); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		os.Exit(2)
+	}
+}
+
+// applyConfig sets the flags in fname that weren't set on the command line.
+func applyConfig(fs *flag.FlagSet, fname string) error {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	switch filepath.Ext(fname) {
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", fname, err)
+	}
+	set := map[string]bool{"config": true}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	merged := map[string]interface{}{}
+	for name, value := range values {
+		if _, ok := value.(map[string]interface{}); ok {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			if fs.Name() == "" {
+				return fmt.Errorf("unknown flag %q", name)
+			}
+			continue
+		}
+		merged[name] = value
+	}
+	if fs.Name() != "" {
+		if section, ok := values[fs.Name()].(map[string]interface{}); ok {
+			for name, value := range section {
+				if fs.Lookup(name) == nil {
+					return fmt.Errorf("unknown %s flag %q", fs.Name(), name)
+				}
+				merged[name] = value
+			}
+		}
+	}
+	for name, value := range merged {
+		if set[name] {
+			continue
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			list = []interface{}{value}
+		}
+		for _, element := range list {
+			text, err := configString(element)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := fs.Set(name, text); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configString formats a config value as it would be written for its flag.
+func configString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
@@ -0,0 +1,128 @@
+package scraping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression: minute, hour, day of month, month
+// and day of week, each a *, a number, a range a-b, a step */n or a-b/n, or
+// a comma-separated list of those.  As in cron, if both day fields are
+// restricted a time matches either.  Times are in UTC.
+type Schedule struct {
+	minute, hour, dom, month, dow []bool
+	// domAny and dowAny are set if the day field is *.
+	domAny, dowAny bool
+	expr           string
+}
+
+// ParseSchedule parses a five-field cron expression.
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q has %d fields, want 5", expr, len(fields))
+	}
+	s := &Schedule{expr: expr}
+	var err error
+	parse := func(field string, lo, hi int) []bool {
+		var set []bool
+		if err == nil {
+			set, err = parseCronField(field, lo, hi)
+		}
+		return set
+	}
+	s.minute = parse(fields[0], 0, 59)
+	s.hour = parse(fields[1], 0, 23)
+	s.dom = parse(fields[2], 1, 31)
+	s.month = parse(fields[3], 1, 12)
+	s.dow = parse(fields[4], 0, 7)
+	if err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	// 7 is Sunday too
+	s.dow[0] = s.dow[0] || s.dow[7]
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return s, nil
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// parseCronField returns which of 0..hi are in field, for values lo..hi.
+func parseCronField(field string, lo, hi int) ([]bool, error) {
+	set := make([]bool, hi+1)
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("bad step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		start, end := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("bad value in %q", part)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("bad value in %q", part)
+				}
+			} else if step > 1 {
+				// 5/15 means from 5 to the end, every 15
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return nil, fmt.Errorf("%q is out of range %d-%d", part, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[t.Weekday()]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time matching s that's after t, or the zero time
+// if there's none in the next five years (e.g. for February 30th).
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.month[t.Month()]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !s.hour[t.Hour()]:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,42 @@
+package scraping
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// addDBURLFile registers -dburl-file on fs if it has a -dburl flag, for
+// setDBURL to read after parsing.
+func addDBURLFile(fs *flag.FlagSet) *string {
+	if fs.Lookup("dburl") == nil {
+		return nil
+	}
+	return fs.String("dburl-file", "", "`file` holding the -dburl, like a mounted secret; without either, the DATABASE_URL environment variable is used if set.  Either keeps the url out of process listings and shell history")
+}
+
+// setDBURL sets -dburl, if the command line and -config left it unset, from
+// the -dburl-file or else $DATABASE_URL.
+func setDBURL(fs *flag.FlagSet, file *string) error {
+	if file == nil {
+		return nil
+	}
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == "dburl" })
+	if set {
+		return nil
+	}
+	dbURL := os.Getenv("DATABASE_URL")
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return fmt.Errorf("reading -dburl-file: %w", err)
+		}
+		dbURL = strings.TrimSpace(string(data))
+	}
+	if dbURL == "" {
+		return nil
+	}
+	return fs.Set("dburl", dbURL)
+}
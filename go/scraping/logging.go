@@ -0,0 +1,56 @@
+package scraping
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// Logging holds the -v and -log-format settings.  They take effect as the
+// flags are parsed, by replacing slog's default logger, which the log
+// package's output goes through too.
+type Logging struct {
+	verbose bool
+	json    bool
+}
+
+// AddFlags registers flags for l's settings on fs.
+func (l *Logging) AddFlags(fs *flag.FlagSet) {
+	fs.BoolFunc("v", "if set, debug messages like each invalid line are logged too", func(s string) error {
+		verbose, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		l.verbose = verbose
+		l.apply()
+		return nil
+	})
+	fs.Func("log-format", "`format` of log lines: text, or json for one object per line (default text)", func(s string) error {
+		switch s {
+		case "text":
+			l.json = false
+		case "json":
+			l.json = true
+		default:
+			return fmt.Errorf("unknown log format %q", s)
+		}
+		l.apply()
+		return nil
+	})
+}
+
+func (l *Logging) apply() {
+	level := slog.LevelInfo
+	if l.verbose {
+		level = slog.LevelDebug
+	}
+	if l.json {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+		return
+	}
+	// the default handler writes through the log package, keeping its
+	// timestamps
+	slog.SetLogLoggerLevel(level)
+}
@@ -0,0 +1,25 @@
+package scraping
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+)
+
+// ExitInterrupted is the exit status of a run stopped by SIGINT or SIGTERM.
+// It follows the shell's 128+SIGINT convention, so that whatever runs the
+// scraper can tell a shutdown from a failure.
+const ExitInterrupted = 130
+
+// Exit logs err and exits, with ExitInterrupted if err came from the run's
+// context being cancelled and 1 otherwise.  Traces are flushed first.
+func Exit(err error) {
+	FlushTracing()
+	if errors.Is(err, context.Canceled) {
+		slog.Error("interrupted", "err", err)
+		os.Exit(ExitInterrupted)
+	}
+	slog.Error("failed", "err", err)
+	os.Exit(1)
+}
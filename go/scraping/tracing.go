@@ -0,0 +1,58 @@
+package scraping
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// shutdownTracing flushes and stops the exporter set up by InitTracing.
+var shutdownTracing = func(context.Context) error { return nil }
+
+// InitTracing exports OpenTelemetry traces over OTLP/HTTP if an endpoint is
+// set with the standard OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables, which along with the other
+// OTEL_* variables configure the exporter.  Otherwise spans are dropped.
+// service is the service.name unless OTEL_SERVICE_NAME overrides it.
+// FlushTracing, which Exit calls, should be called before exiting so that the
+// last spans aren't lost.
+func InitTracing(ctx context.Context, service string) error {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return fmt.Errorf("creating trace exporter: %w", err)
+	}
+	// later options win, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	// override service
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", service)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv())
+	if err != nil {
+		return fmt.Errorf("creating trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	shutdownTracing = provider.Shutdown
+	return nil
+}
+
+// FlushTracing exports any spans that haven't been yet, giving up after a
+// few seconds.
+func FlushTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("flushing traces", "err", err)
+	}
+}
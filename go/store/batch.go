@@ -0,0 +1,79 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// insertBatch upserts rows, which all have the same columns, with one
+// multi-row INSERT per table.  A statement can't touch the same key twice in
+// Postgres, so rows are first collapsed to what upserting them one at a time
+// would leave: the last row for each metars key, and the newest row for
+// each station in metars_latest.
+func (s *sqlStore) insertBatch(rows []map[string]interface{}, opts *Options) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var cols []string
+	for col := range rows[0] {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	key := opts.ConflictKey()
+
+	byKey := map[string]int{}
+	var unique []map[string]interface{}
+	for _, row := range rows {
+		var parts []string
+		for _, k := range key {
+			parts = append(parts, fmt.Sprint(row[k]))
+		}
+		k := strings.Join(parts, "\x00")
+		if i, ok := byKey[k]; ok {
+			unique[i] = row
+			continue
+		}
+		byKey[k] = len(unique)
+		unique = append(unique, row)
+	}
+	if err := s.insertRows("metars", cols, unique, s.dialect.Upsert("metars", rows[0], key, false, opts.SkipUnchanged)); err != nil {
+		return err
+	}
+	if !opts.Latest {
+		return nil
+	}
+
+	newest := map[string]int{}
+	var latest []map[string]interface{}
+	for _, row := range rows {
+		station := row["station"].(string)
+		i, ok := newest[station]
+		if !ok {
+			newest[station] = len(latest)
+			latest = append(latest, row)
+			continue
+		}
+		if !row["observation_time"].(time.Time).Before(latest[i]["observation_time"].(time.Time)) {
+			latest[i] = row
+		}
+	}
+	if err := s.insertRows("metars_latest", cols, latest, s.dialect.Upsert("metars_latest", rows[0], []string{"station"}, true, opts.SkipUnchanged)); err != nil {
+		return fmt.Errorf("updating latest: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) insertRows(table string, cols []string, rows []map[string]interface{}, suffix string) error {
+	insert := s.dialect.Builder.Insert(table).Columns(cols...)
+	for _, row := range rows {
+		values := make([]interface{}, len(cols))
+		for i, col := range cols {
+			values[i] = row[col]
+		}
+		insert = insert.Values(values...)
+	}
+	_, err := insert.Suffix(suffix).RunWith(s.tx).Exec()
+	return err
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/ClickHouse/clickhouse-go/v2"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// clickhouseSchema is the ClickHouse equivalent of the metars table, keyed
+// on key.  ReplacingMergeTree keeps the most recently inserted row for each
+// key when parts merge, which stands in for the Postgres upsert; queries that
+// can't wait for merges should use FINAL.  Partitions are daily, so that
+// dropping old data is a matter of dropping whole partitions.
+func clickhouseSchema(key []string) string {
+	return `CREATE TABLE IF NOT EXISTS metars (
+    station String,
+    observation_time DateTime('UTC'),
+    metar_type LowCardinality(String),
+    raw_text String,
+    csv_parts Array(String)
+) ENGINE = ReplacingMergeTree
+PARTITION BY toDate(observation_time)
+ORDER BY (` + strings.Join(key, ", ") + `)`
+}
+
+const clickhouseInsert = "INSERT INTO metars (station, observation_time, metar_type, raw_text, csv_parts)"
+
+func createClickHouseSchema(ctx context.Context, db *sql.DB, key []string) error {
+	if _, err := db.ExecContext(ctx, clickhouseSchema(key)); err != nil {
+		return fmt.Errorf("creating clickhouse schema: %w", err)
+	}
+	return nil
+}
+
+// writeClickHouse adds obs to the batch being built by stmt, which is sent
+// when the transaction commits.
+func writeClickHouse(stmt *sql.Stmt, obs *metarcsv.Observation) error {
+	_, err := stmt.Exec(obs.Station, obs.ObservationTime, obs.Field(metarcsv.ColMetarType), obs.Field(metarcsv.ColRawText), obs.Parts)
+	return err
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// recordingDriver is a database/sql driver that records what it's asked to
+// do, standing in for clickhouse-go, whose batches are sent on commit.
+type recordingDriver struct {
+	mu sync.Mutex
+	// log has an entry per call: "exec <query>", "prepare <query>", "row
+	// <args>", "begin", "commit" or "rollback".
+	log []string
+	// rows are the args of each statement execution.
+	rows [][]driver.NamedValue
+}
+
+func (d *recordingDriver) record(entry string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, entry)
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.record("prepare " + query)
+	return &recordingStmt{c.d}, nil
+}
+
+func (c *recordingConn) Close() error { return nil }
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	c.d.record("begin")
+	return &recordingTx{c.d}, nil
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.record("exec " + query)
+	return driver.RowsAffected(0), nil
+}
+
+// CheckNamedValue accepts any argument, like clickhouse-go's []string.
+func (c *recordingConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+type recordingStmt struct{ d *recordingDriver }
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("unexpected Exec without context")
+}
+
+func (s *recordingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	s.d.record("row")
+	s.d.mu.Lock()
+	s.d.rows = append(s.d.rows, args)
+	s.d.mu.Unlock()
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("unexpected Query")
+}
+
+type recordingTx struct{ d *recordingDriver }
+
+func (t *recordingTx) Commit() error {
+	t.d.record("commit")
+	return nil
+}
+
+func (t *recordingTx) Rollback() error {
+	t.d.record("rollback")
+	return nil
+}
+
+func newRecordingDB(t *testing.T) (*sql.DB, *recordingDriver) {
+	d := &recordingDriver{}
+	db := sql.OpenDB(&recordingConnector{d})
+	// one connection, so the log is in order
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+type recordingConnector struct{ d *recordingDriver }
+
+func (c *recordingConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c *recordingConnector) Driver() driver.Driver                        { return c.d }
+
+func TestClickHouseSchema(t *testing.T) {
+	for _, test := range []struct {
+		opts  *Options
+		order string
+	}{
+		{&Options{}, "ORDER BY (station, observation_time)"},
+		// so a METAR and SPECI at the same time aren't merged into one
+		{&Options{KeyMetarType: true}, "ORDER BY (station, observation_time, metar_type)"},
+	} {
+		db, d := newRecordingDB(t)
+		if err := CreateSchema(context.Background(), db, ClickHouse, test.opts.ConflictKey()); err != nil {
+			t.Fatal(err)
+		}
+		schema := clickhouseSchema(test.opts.ConflictKey())
+		if len(d.log) != 1 || d.log[0] != "exec "+schema {
+			t.Fatalf("CreateSchema ran %q", d.log)
+		}
+		for _, want := range []string{
+			"ENGINE = ReplacingMergeTree",
+			"PARTITION BY toDate(observation_time)",
+			test.order,
+			"csv_parts Array(String)",
+		} {
+			if !strings.Contains(schema, want) {
+				t.Errorf("schema has no %q", want)
+			}
+		}
+	}
+}
+
+func TestClickHouseBatch(t *testing.T) {
+	db, d := newRecordingDB(t)
+	s := New(db, ClickHouse, &Options{BatchSize: 2, Copy: true})
+	if _, ok := s.(*clickhouseStore); !ok {
+		t.Fatalf("New returned a %T for ClickHouse", s)
+	}
+	at := time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC)
+	records, err := metarcsv.Open(strings.NewReader(testFile(
+		testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", map[int]string{metarcsv.ColMetarType: "METAR"}),
+		testLine("KJFK 141151Z 31015G25KT 10SM FEW050 12/05 A2992", "KJFK", "2026-10-14T11:51:00Z", map[int]string{metarcsv.ColMetarType: "METAR"}),
+		testLine("KJFK 141209Z 31020G30KT 10SM BKN030 12/05 A2990", "KJFK", "2026-10-14T12:09:00Z", map[int]string{metarcsv.ColMetarType: "SPECI"}),
+	)), "csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := Load(context.Background(), s, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("wrote %d, want 3", n)
+	}
+	// one prepared insert per file, whatever BatchSize says, with every row
+	// added to it before the commit sends them
+	want := []string{"begin", "prepare " + clickhouseInsert, "row", "row", "row", "commit"}
+	if !slices.Equal(d.log, want) {
+		t.Errorf("calls %q, want %q", d.log, want)
+	}
+	first := d.rows[0]
+	if len(first) != 5 || first[0].Value != "KBOS" || !first[1].Value.(time.Time).Equal(at) || first[2].Value != "METAR" || first[3].Value != "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992" {
+		t.Errorf("first row %v", first)
+	}
+	if parts, ok := first[4].Value.([]string); !ok || len(parts) != len(metarcsv.Columns) {
+		t.Errorf("csv_parts %v, want %d columns", first[4].Value, len(metarcsv.Columns))
+	}
+	if d.rows[2][2].Value != "SPECI" {
+		t.Errorf("third row's metar_type %v, want SPECI", d.rows[2][2].Value)
+	}
+}
+
+func TestClickHouseRollback(t *testing.T) {
+	db, d := newRecordingDB(t)
+	s := New(db, ClickHouse, nil)
+	if err := s.Begin(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(&metarcsv.Observation{Station: "KBOS", Parts: []string{"KBOS 141154Z"}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Rollback()
+	if want := []string{"begin", "prepare " + clickhouseInsert, "row", "rollback"}; !slices.Equal(d.log, want) {
+		t.Errorf("calls %q, want %q", d.log, want)
+	}
+}
+
+func TestOpenClickHouse(t *testing.T) {
+	db, d, err := Open("clickhouse://localhost:9000/weather", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if d != ClickHouse {
+		t.Errorf("clickhouse:// url opened with %s", d.Driver)
+	}
+	if IsClickHouse("postgres://localhost/weather") {
+		t.Error("postgres url taken for ClickHouse")
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	pq "github.com/lib/pq"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// copyStore loads a Postgres database with COPY rather than an upsert per
+// row: rows are copied into a temporary staging table, which is merged into
+// metars (and metars_latest) on commit.  This saves a round trip per row,
+// which dominates a large file's ingest time.
+type copyStore struct {
+	db   *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+	// columns are the staged columns, fixed by the first row written.
+	columns []string
+	opts    *Options
+}
+
+// stagingTable is dropped when the transaction ends.  copy_seq records file
+// order, so that when a file has the same key twice the later row wins, as
+// it would with one upsert per row.
+const stagingTable = "CREATE TEMPORARY TABLE metars_staging (LIKE metars INCLUDING DEFAULTS, copy_seq bigserial) ON COMMIT DROP"
+
+func (s *copyStore) Begin(ctx context.Context) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	s.tx = tx
+	s.stmt, s.columns = nil, nil
+	if _, err := tx.Exec(stagingTable); err != nil {
+		return fmt.Errorf("creating staging table: %w", err)
+	}
+	return nil
+}
+
+func (s *copyStore) Write(obs *metarcsv.Observation) error {
+	row, err := Row(Postgres, obs, s.opts)
+	if err != nil {
+		return err
+	}
+	if s.stmt == nil {
+		for col := range row {
+			s.columns = append(s.columns, col)
+		}
+		sort.Strings(s.columns)
+		if s.stmt, err = s.tx.Prepare(pq.CopyIn("metars_staging", s.columns...)); err != nil {
+			return fmt.Errorf("starting copy: %w", err)
+		}
+	}
+	values := make([]interface{}, len(s.columns))
+	for i, col := range s.columns {
+		values[i] = row[col]
+	}
+	_, err = s.stmt.Exec(values...)
+	return err
+}
+
+func (s *copyStore) Commit() error {
+	if s.stmt != nil {
+		if _, err := s.stmt.Exec(); err != nil {
+			return fmt.Errorf("finishing copy: %w", err)
+		}
+		if err := s.stmt.Close(); err != nil {
+			return fmt.Errorf("finishing copy: %w", err)
+		}
+		if err := s.merge(); err != nil {
+			return err
+		}
+	}
+	if err := s.tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	return nil
+}
+
+// merge upserts the staged rows.
+func (s *copyStore) merge() error {
+	row := map[string]interface{}{}
+	for _, col := range s.columns {
+		row[col] = nil
+	}
+	cols := strings.Join(s.columns, ", ")
+	key := s.opts.ConflictKey()
+	_, err := s.tx.Exec(fmt.Sprintf(`INSERT INTO metars (%s)
+SELECT DISTINCT ON (%s) %s FROM metars_staging
+ORDER BY %s, copy_seq DESC
+%s`, cols, strings.Join(key, ", "), cols, strings.Join(key, ", "), onConflict("metars", row, key, false, s.opts.SkipUnchanged)))
+	if err != nil {
+		return fmt.Errorf("merging staged rows: %w", err)
+	}
+	if s.opts.Latest {
+		_, err := s.tx.Exec(fmt.Sprintf(`INSERT INTO metars_latest (%s)
+SELECT DISTINCT ON (station) %s FROM metars_staging
+ORDER BY station, observation_time DESC, copy_seq DESC
+%s`, cols, cols, onConflict("metars_latest", row, []string{"station"}, true, s.opts.SkipUnchanged)))
+		if err != nil {
+			return fmt.Errorf("updating latest: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *copyStore) Rollback() {
+	if s.tx != nil {
+		s.tx.Rollback()
+	}
+}
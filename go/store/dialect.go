@@ -0,0 +1,238 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/go-sql-driver/mysql"
+	pq "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Dialect is what differs between the databases observations can be written
+// to.  ClickHouse batches inserts instead and has its own write path, so
+// only its Driver is set.
+type Dialect struct {
+	// Driver is the database/sql driver name.
+	Driver  string
+	Builder sq.StatementBuilderType
+	// Array encodes csv_parts.  SQLite and MySQL have no array type, so they
+	// get a JSON array.
+	Array func(parts []string) (interface{}, error)
+	// ScanArray returns a scanner that decodes csv_parts into dest.
+	ScanArray func(dest *[]string) sql.Scanner
+	// Upsert returns the suffix that makes inserting row into table
+	// overwrite an existing row with the same key.  With newerOnly, a row
+	// is only overwritten by one with a later or equal observation_time.
+	// With skipUnchanged, a row with the same content_hash isn't rewritten.
+	Upsert func(table string, row map[string]interface{}, key []string, newerOnly, skipUnchanged bool) string
+	// ColumnType returns the type of col when CreateSchema creates the
+	// tables.
+	ColumnType func(col string) string
+}
+
+func jsonArray(parts []string) (interface{}, error) {
+	encoded, err := json.Marshal(parts)
+	return string(encoded), err
+}
+
+// jsonArrayScanner scans a column written by jsonArray.
+type jsonArrayScanner struct {
+	dest *[]string
+}
+
+func (j jsonArrayScanner) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*j.dest = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), j.dest)
+	case []byte:
+		return json.Unmarshal(src, j.dest)
+	}
+	return fmt.Errorf("can't scan %T as a JSON array", src)
+}
+
+func scanJSONArray(dest *[]string) sql.Scanner {
+	return jsonArrayScanner{dest}
+}
+
+// onConflict is the Postgres and SQLite upsert.
+func onConflict(table string, row map[string]interface{}, key []string, newerOnly, skipUnchanged bool) string {
+	var where []string
+	if newerOnly {
+		where = append(where, fmt.Sprintf("%s.observation_time <= EXCLUDED.observation_time", table))
+	}
+	if skipUnchanged {
+		// skipping the update avoids writing a new row version, which is
+		// what costs WAL and vacuum time
+		where = append(where, fmt.Sprintf("%s.content_hash IS DISTINCT FROM EXCLUDED.content_hash", table))
+	}
+	suffix := upsertSuffix(row, key...)
+	if len(where) > 0 {
+		suffix += " WHERE " + strings.Join(where, " AND ")
+	}
+	return suffix
+}
+
+// upsertSuffix returns an ON CONFLICT clause for key that overwrites every
+// other column in row.
+func upsertSuffix(row map[string]interface{}, key ...string) string {
+	isKey := map[string]bool{}
+	for _, k := range key {
+		isKey[k] = true
+	}
+	var sets []string
+	for col := range row {
+		if !isKey[col] {
+			sets = append(sets, fmt.Sprintf("%s=EXCLUDED.%s", col, col))
+		}
+	}
+	sort.Strings(sets)
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE set %s", strings.Join(key, ", "), strings.Join(sets, ", "))
+}
+
+// onDuplicateKey is the MySQL upsert.  MySQL has no WHERE on its upsert, so
+// newerOnly makes each column conditional instead, with observation_time
+// set last so the others compare against the old value.  skipUnchanged is
+// ignored, since InnoDB already skips writing a row whose values don't
+// change.
+func onDuplicateKey(table string, row map[string]interface{}, key []string, newerOnly, skipUnchanged bool) string {
+	isKey := map[string]bool{}
+	for _, k := range key {
+		isKey[k] = true
+	}
+	var sets []string
+	for col := range row {
+		if isKey[col] || newerOnly && col == "observation_time" {
+			continue
+		}
+		if newerOnly {
+			sets = append(sets, fmt.Sprintf("%s=IF(VALUES(observation_time) >= observation_time, VALUES(%s), %s)", col, col, col))
+		} else {
+			sets = append(sets, fmt.Sprintf("%s=VALUES(%s)", col, col))
+		}
+	}
+	sort.Strings(sets)
+	if newerOnly {
+		sets = append(sets, "observation_time=GREATEST(observation_time, VALUES(observation_time))")
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// sqliteColumnType leaves columns untyped, except the timestamps, which the
+// driver only turns back into times for columns declared as such.
+func sqliteColumnType(col string) string {
+	if col == "observation_time" {
+		return "timestamp"
+	}
+	return ""
+}
+
+// mysqlColumnTypes are the types of the MySQL columns other than
+// typedColumns.  Key columns need a bounded length.
+var mysqlColumnTypes = map[string]string{
+	"station":              "varchar(16)",
+	"observation_time":     "datetime",
+	"metar_type":           "varchar(8)",
+	"content_hash":         "char(64)",
+	"csv_parts":            "json",
+	"remarks":              "json",
+	"supplementary":        "json",
+	"cloud_layers":         "json",
+	"unparsed":             "json",
+	"clear_sky":            "varchar(8)",
+	"flight_category":      "varchar(8)",
+	"qnh_hpa":              "double",
+	"qnh_inhg":             "double",
+	"qfe_hpa":              "double",
+	"pressure_altitude_ft": "int",
+	"density_altitude_ft":  "int",
+	"station_country":      "varchar(8)",
+	"station_elevation_m":  "double",
+}
+
+func mysqlColumnType(col string) string {
+	if t, ok := mysqlColumnTypes[col]; ok {
+		return t
+	}
+	for _, typed := range typedColumns {
+		if typed.name != col {
+			continue
+		}
+		switch typed.kind {
+		case kindReal:
+			return "double"
+		case kindInteger:
+			return "int"
+		case kindBoolean:
+			return "boolean"
+		}
+	}
+	return "text"
+}
+
+// The dialects of the supported databases.
+var (
+	Postgres = &Dialect{
+		Driver:  "postgres",
+		Builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		Array: func(parts []string) (interface{}, error) {
+			return pq.StringArray(parts), nil
+		},
+		ScanArray: func(dest *[]string) sql.Scanner {
+			return (*pq.StringArray)(dest)
+		},
+		Upsert: onConflict,
+	}
+	SQLite = &Dialect{
+		Driver:     "sqlite3",
+		Builder:    sq.StatementBuilder.PlaceholderFormat(sq.Question),
+		Array:      jsonArray,
+		ScanArray:  scanJSONArray,
+		Upsert:     onConflict,
+		ColumnType: sqliteColumnType,
+	}
+	MySQL = &Dialect{
+		Driver:     "mysql",
+		Builder:    sq.StatementBuilder.PlaceholderFormat(sq.Question),
+		Array:      jsonArray,
+		ScanArray:  scanJSONArray,
+		Upsert:     onDuplicateKey,
+		ColumnType: mysqlColumnType,
+	}
+	ClickHouse = &Dialect{Driver: "clickhouse"}
+)
+
+// Drivers are the dialects by the names Open takes: postgres, sqlite and
+// mysql.
+var Drivers = map[string]*Dialect{
+	"postgres": Postgres,
+	"sqlite":   SQLite,
+	"mysql":    MySQL,
+}
+
+// IsClickHouse reports whether dbURL is for ClickHouse, which Open uses
+// whatever the driver.
+func IsClickHouse(dbURL string) bool {
+	return strings.HasPrefix(dbURL, "clickhouse://")
+}
+
+// Open opens dbURL with driver (a key of Drivers), or with ClickHouse for a
+// clickhouse:// url.  For SQLite, dbURL is the database's filename.
+func Open(dbURL, driver string) (*sql.DB, *Dialect, error) {
+	d := Drivers[driver]
+	if IsClickHouse(dbURL) {
+		d = ClickHouse
+	}
+	if d == nil {
+		return nil, nil, fmt.Errorf("unknown driver %q", driver)
+	}
+	db, err := sql.Open(d.Driver, dbURL)
+	return db, d, err
+}
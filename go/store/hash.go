@@ -0,0 +1,32 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// ContentHash returns the content_hash column: a hex SHA-256 of obs's fields
+// for change detection.  It hashes the sorted name=value pairs of the
+// non-empty columns, so it doesn't depend on column order, and a column being
+// added upstream doesn't change the hash of rows where it's empty.
+func ContentHash(obs *metarcsv.Observation) string {
+	var pairs []string
+	for i, value := range obs.Parts {
+		if value == "" {
+			continue
+		}
+		name := fmt.Sprintf("column_%d", i)
+		if i < len(metarcsv.Columns) {
+			name = metarcsv.Columns[i]
+		}
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	sum := sha256.Sum256([]byte(strings.Join(pairs, "\n")))
+	return hex.EncodeToString(sum[:])
+}
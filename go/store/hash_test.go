@@ -0,0 +1,68 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+func hashOf(t *testing.T, file string) string {
+	t.Helper()
+	records, err := metarcsv.Open(strings.NewReader(file), "csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !records.Next() {
+		t.Fatalf("no records: %v", records.Err())
+	}
+	_, obs, err := records.Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ContentHash(obs)
+}
+
+func TestContentHash(t *testing.T) {
+	fields := map[int]string{metarcsv.ColTempC: "12.0", metarcsv.ColDewpointC: "5.0", metarcsv.ColMetarType: "METAR"}
+	line := testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", fields)
+	hash := hashOf(t, testFile(line))
+
+	// a change to the hash changes every row's, which -skip-unchanged would
+	// then rewrite, so it should only change on purpose
+	const want = "db39a2a4b7eda67d99794e140728080f75df9bb0a5da2a25201e36b0c009a460"
+	if hash != want {
+		t.Errorf("hash = %s, want %s", hash, want)
+	}
+	if again := hashOf(t, testFile(line)); again != hash {
+		t.Errorf("hash changed from %s to %s", hash, again)
+	}
+
+	// the same fields in another column order
+	reordered := "No errors\nNo warnings\n5 ms\ndata source=metars\n1 results\n" +
+		"station_id,metar_type,observation_time,dewpoint_c,temp_c,raw_text\n" +
+		"KBOS,METAR,2026-10-14T11:54:00Z,5.0,12.0,KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992\n"
+	if got := hashOf(t, reordered); got != hash {
+		t.Errorf("reordered columns: hash %s, want %s", got, hash)
+	}
+
+	// an empty column upstream adds doesn't count
+	obs := &metarcsv.Observation{Parts: strings.Split(line, ",")}
+	widened := &metarcsv.Observation{Parts: append(strings.Split(line, ","), "")}
+	if ContentHash(widened) != ContentHash(obs) {
+		t.Error("hash changed by an empty extra column")
+	}
+
+	// but any value does
+	changed := testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z",
+		map[int]string{metarcsv.ColTempC: "12.1", metarcsv.ColDewpointC: "5.0", metarcsv.ColMetarType: "METAR"})
+	if got := hashOf(t, testFile(changed)); got == hash {
+		t.Error("hash unchanged by a new temperature")
+	}
+	// including one moved to another column
+	swapped := testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z",
+		map[int]string{metarcsv.ColTempC: "5.0", metarcsv.ColDewpointC: "12.0", metarcsv.ColMetarType: "METAR"})
+	if got := hashOf(t, testFile(swapped)); got == hash {
+		t.Error("hash unchanged by swapping temperature and dewpoint")
+	}
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+func TestConflictKey(t *testing.T) {
+	for _, key := range []bool{false, true} {
+		for _, batch := range []int{0, 10} {
+			opts := &Options{KeyMetarType: key, BatchSize: batch, Latest: true}
+			db := testDB(t, opts)
+			load(t, db, opts, &metarcsv.Options{}, testFile(
+				testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", map[int]string{metarcsv.ColMetarType: "METAR"}),
+				testLine("KBOS 141154Z 27015G25KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", map[int]string{metarcsv.ColMetarType: "SPECI"}),
+				// a correction of the METAR
+				testLine("KBOS 141154Z COR 27010KT 10SM FEW045 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", map[int]string{metarcsv.ColMetarType: "METAR"}),
+			))
+			var raws []string
+			for _, r := range storedRows(t, db) {
+				raws = append(raws, r.raw)
+			}
+			want := []string{"KBOS 141154Z COR 27010KT 10SM FEW045 12/05 A2992"}
+			if key {
+				// ordered by metar_type
+				want = []string{"KBOS 141154Z COR 27010KT 10SM FEW045 12/05 A2992", "KBOS 141154Z 27015G25KT 10SM FEW050 12/05 A2992"}
+			}
+			if !slices.Equal(raws, want) {
+				t.Errorf("key metar_type %v, batch %d: rows %q, want %q", key, batch, raws, want)
+			}
+			var latest int
+			if err := db.QueryRow("SELECT count(*) FROM metars_latest").Scan(&latest); err != nil {
+				t.Fatal(err)
+			}
+			if latest != 1 {
+				t.Errorf("key metar_type %v, batch %d: %d rows in metars_latest, want 1", key, batch, latest)
+			}
+		}
+	}
+}
+
+func TestConflictKeyMismatch(t *testing.T) {
+	// the table is keyed without metar_type, which the upsert can't target
+	db := testDB(t, &Options{})
+	opts := &Options{KeyMetarType: true}
+	records, err := metarcsv.Open(strings.NewReader(testFile(
+		testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", map[int]string{metarcsv.ColMetarType: "METAR"}),
+	)), "csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(context.Background(), New(db, SQLite, opts), records); err == nil {
+		t.Error("upserting on a key the table doesn't have: got no error")
+	}
+}
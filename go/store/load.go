@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// Load writes each observation in records to s in one transaction, and
+// returns how many were written.  Cut-off lines are skipped, but anything
+// that can't be parsed or written rolls the whole file back, as does ctx
+// being cancelled.
+func Load(ctx context.Context, s Store, records metarcsv.Records) (int, error) {
+	if err := s.Begin(ctx); err != nil {
+		return 0, err
+	}
+	defer s.Rollback()
+	written := 0
+	for records.Next() {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		text, obs, err := records.Record()
+		if err != nil {
+			return 0, fmt.Errorf("parsing %q: %w", text, err)
+		}
+		if obs == nil {
+			continue
+		}
+		if err := s.Write(obs); err != nil {
+			return 0, fmt.Errorf("writing %s at %v: %w", obs.Station, obs.ObservationTime, err)
+		}
+		written++
+	}
+	if err := records.Err(); err != nil {
+		return 0, err
+	}
+	if err := s.Commit(); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
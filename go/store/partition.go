@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// partitionName is the name sql/partition.sql gives the partition of metars
+// for the month starting at month.
+func partitionName(month time.Time) string {
+	return fmt.Sprintf("metars_%04d_%02d", month.Year(), int(month.Month()))
+}
+
+// EnsurePartitions creates the monthly partitions of metars for last month,
+// this month and next month, if they don't exist.  This needs metars to be
+// partitioned by sql/partition.sql.  Creating a partition fails if
+// metars_default already holds rows for its month; those have to be moved
+// by hand.
+func EnsurePartitions(ctx context.Context, db *sql.DB, now time.Time) error {
+	now = now.UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, offset := range []int{-1, 0, 1} {
+		from := thisMonth.AddDate(0, offset, 0)
+		to := from.AddDate(0, 1, 0)
+		stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF metars FOR VALUES FROM ('%s') TO ('%s')",
+			partitionName(from), from.Format(time.RFC3339), to.Format(time.RFC3339))
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("creating partition %s: %w", partitionName(from), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"mattdee123.com/aviationweather/calc"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// Row returns the metars columns for obs, encoded for d.  Optional columns
+// are set, if only to NULL, whenever the option for them is on, so every row
+// from a run has the same columns.
+func Row(d *Dialect, obs *metarcsv.Observation, opts *Options) (map[string]interface{}, error) {
+	parts, err := d.Array(obs.Parts)
+	if err != nil {
+		return nil, fmt.Errorf("encoding csv_parts: %w", err)
+	}
+	row := map[string]interface{}{
+		"station":          obs.Station,
+		"observation_time": obs.ObservationTime,
+		"csv_parts":        parts,
+		"metar_type":       obs.Field(metarcsv.ColMetarType),
+		"content_hash":     ContentHash(obs),
+	}
+	if opts.Remarks {
+		if err := setJSON(row, "remarks", metar.DecodeRemarks(obs.Field(metarcsv.ColRawText))); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Supplementary {
+		if err := setJSON(row, "supplementary", metar.DecodeSupplementary(obs.Field(metarcsv.ColRawText))); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Pressure {
+		row["qnh_hpa"], row["qnh_inhg"], row["qfe_hpa"] = nil, nil, nil
+		if p := metar.DecodePressure(obs.Field(metarcsv.ColRawText)); p != nil {
+			row["qnh_hpa"], row["qnh_inhg"], row["qfe_hpa"] = p.QNHHPa, p.QNHInHg, p.QFEHPa
+		}
+	}
+	if opts.FlightCategory {
+		row["flight_category"] = nil
+		if category := obs.FlightCategory(); category != "" {
+			row["flight_category"] = category
+		}
+	}
+	if opts.TypedColumns {
+		if err := SetTypedColumns(row, obs); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DensityAltitude {
+		row["pressure_altitude_ft"], row["density_altitude_ft"] = altitudes(obs)
+	}
+	if opts.Unparsed {
+		row["unparsed"] = nil
+		m, err := metar.DecodeWith(obs.Field(metarcsv.ColRawText), opts.Strictness)
+		var unrecognized *metar.UnparsedError
+		var groups []string
+		switch {
+		case errors.As(err, &unrecognized):
+			groups = unrecognized.Groups
+		case err == nil:
+			groups = m.Unparsed
+		}
+		if len(groups) > 0 {
+			if err := setJSON(row, "unparsed", groups); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if opts.Stations != nil {
+		row["station_name"], row["station_country"], row["station_elevation_m"] = nil, nil, nil
+		if s := opts.Stations[obs.Station]; s != nil {
+			row["station_name"], row["station_country"], row["station_elevation_m"] = orNull(s.Name), orNull(s.Country), orNull(s.ElevationM)
+		}
+	}
+	if opts.ClearSky {
+		row["clear_sky"] = nil
+		if clear := metar.ClearSky(obs.Field(metarcsv.ColRawText)); clear != "" {
+			row["clear_sky"] = clear
+		}
+	}
+	return row, nil
+}
+
+// altitudes returns obs's pressure and density altitudes in feet, or nils
+// for whichever can't be computed.
+func altitudes(obs *metarcsv.Observation) (pressureAltitude, densityAltitude interface{}) {
+	elevation, ok := obs.Number(metarcsv.ColElevationM)
+	altimeter, ok2 := obs.Number(metarcsv.ColAltimInHg)
+	if !ok || !ok2 {
+		return nil, nil
+	}
+	pa := calc.PressureAltitudeFt(calc.MetersToFeet(elevation), altimeter)
+	temp, ok := obs.Number(metarcsv.ColTempC)
+	if !ok {
+		return calc.RoundFt(pa), nil
+	}
+	return calc.RoundFt(pa), calc.RoundFt(calc.DensityAltitudeFt(pa, temp))
+}
+
+// orNull returns *p, or nil for a nil p.
+func orNull[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// setJSON sets row[col] to v encoded as JSON, or to NULL if v is a nil
+// pointer.
+func setJSON(row map[string]interface{}, col string, v interface{}) error {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		row[col] = nil
+		return nil
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", col, err)
+	}
+	row[col] = string(encoded)
+	return nil
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+func TestRowUnparsed(t *testing.T) {
+	line := testLine("KBOS 141154Z 27010 10SM BKN30 -05/M08 A2992 XYZZY", "KBOS", "2026-10-14T11:54:00Z", nil)
+	obs := &metarcsv.Observation{Station: "KBOS", Parts: strings.Split(line, ",")}
+	for _, test := range []struct {
+		strictness metar.Strictness
+		want       interface{}
+	}{
+		{"", `["27010","BKN30","-05/M08","XYZZY"]`},
+		{metar.Lenient, `["27010","BKN30","-05/M08","XYZZY"]`},
+		// an observation strict would reject still records why
+		{metar.Strict, `["27010","BKN30","-05/M08","XYZZY"]`},
+		{metar.BestEffort, `["XYZZY"]`},
+	} {
+		row, err := Row(SQLite, obs, &Options{Unparsed: true, Strictness: test.strictness})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := row["unparsed"]; got != test.want {
+			t.Errorf("%q: unparsed %v, want %v", test.strictness, got, test.want)
+		}
+	}
+
+	clean := testLine("KBOS 141154Z 27010KT 10SM BKN030 M05/M08 A2992", "KBOS", "2026-10-14T11:54:00Z", nil)
+	row, err := Row(SQLite, &metarcsv.Observation{Station: "KBOS", Parts: strings.Split(clean, ",")}, &Options{Unparsed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := row["unparsed"]; !ok || got != nil {
+		t.Errorf("fully decoded: unparsed %v (set %v), want NULL", got, ok)
+	}
+}
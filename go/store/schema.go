@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// metarErrorsTable is created by CreateSchema for dialects without
+// migrations; see sql/017.sql for postgres.
+const metarErrorsTable = "CREATE TABLE IF NOT EXISTS metar_errors (recorded_at timestamp, source text, line_number integer, stage text, line text, error text)"
+
+// scrapeRunsTable is created by CreateSchema for dialects without
+// migrations; see sql/018.sql for postgres.
+const scrapeRunsTable = "CREATE TABLE IF NOT EXISTS scrape_runs (started_at timestamp, finished_at timestamp, product text, success boolean, error text, lines_scanned integer, rows_written integer, rows_skipped integer)"
+
+// CreateSchema creates the tables the scrapers write if they don't exist,
+// with every column Row can set and metars keyed on key, for the databases
+// the migrations in sql/ don't cover.  It does nothing for Postgres, which
+// those are for.
+func CreateSchema(ctx context.Context, db *sql.DB, d *Dialect, key []string) error {
+	switch d {
+	case Postgres:
+		return nil
+	case ClickHouse:
+		return createClickHouseSchema(ctx, db, key)
+	}
+	var cols []string
+	for _, col := range latestColumns() {
+		cols = append(cols, strings.TrimSpace(col+" "+d.ColumnType(col)))
+	}
+	for table, key := range map[string][]string{"metars": key, "metars_latest": {"station"}} {
+		stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, PRIMARY KEY (%s))", table, strings.Join(cols, ", "), strings.Join(key, ", "))
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("creating %s schema: %w", d.Driver, err)
+		}
+	}
+	for _, stmt := range []string{metarErrorsTable, scrapeRunsTable} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("creating %s schema: %w", d.Driver, err)
+		}
+	}
+	return nil
+}
+
+// latestColumns returns the columns metars and metars_latest share, which
+// RebuildLatest copies.
+func latestColumns() []string {
+	cols := []string{"station", "observation_time", "csv_parts", "remarks", "supplementary", "clear_sky", "metar_type", "qnh_hpa", "qnh_inhg", "qfe_hpa", "content_hash", "flight_category", "pressure_altitude_ft", "density_altitude_ft", "station_name", "station_country", "station_elevation_m", "unparsed"}
+	for _, col := range typedColumns {
+		cols = append(cols, col.name)
+	}
+	return append(cols, "cloud_layers")
+}
+
+// RebuildLatest repopulates metars_latest from the full metars table of a
+// Postgres database.
+func RebuildLatest(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM metars_latest"); err != nil {
+		return fmt.Errorf("clearing: %w", err)
+	}
+	cols := strings.Join(latestColumns(), ", ")
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO metars_latest (%s)
+SELECT DISTINCT ON (station) %s FROM metars
+ORDER BY station, observation_time DESC`, cols, cols))
+	if err != nil {
+		return fmt.Errorf("copying: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Station is a station's row of the stations table written by
+// station_scraper.  Columns the table has null are nil.
+type Station struct {
+	// Name is the table's site, like "Boston/Logan Intl".
+	Name                *string
+	Country             *string
+	Latitude, Longitude *float64
+	ElevationM          *float64
+}
+
+// LoadStations reads the whole stations table of db into memory, by station.
+// It's a few tens of thousands of rows, read once per run.
+func LoadStations(ctx context.Context, db *sql.DB, d *Dialect) (map[string]*Station, error) {
+	rows, err := d.Builder.Select("station", "site", "country", "latitude", "longitude", "elevation_m").
+		From("stations").RunWith(db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stations := map[string]*Station{}
+	for rows.Next() {
+		var id string
+		s := &Station{}
+		if err := rows.Scan(&id, &s.Name, &s.Country, &s.Latitude, &s.Longitude, &s.ElevationM); err != nil {
+			return nil, err
+		}
+		stations[id] = s
+	}
+	return stations, rows.Err()
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+func TestStationEnrichment(t *testing.T) {
+	for _, batch := range []int{0, 10} {
+		opts := &Options{BatchSize: batch}
+		db := testDB(t, opts)
+		for _, stmt := range []string{
+			"CREATE TABLE stations (station text primary key, site text, latitude double precision, longitude double precision, elevation_m double precision, state text, country text, site_type text, csv_parts text)",
+			"INSERT INTO stations (station, site, latitude, longitude, elevation_m, state, country) VALUES ('KBOS', 'Boston/Logan Intl', 42.36, -71.01, 6, 'MA', 'US')",
+			// a station the table only partly knows
+			"INSERT INTO stations (station, country) VALUES ('EGLL', 'GB')",
+		} {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stations, err := LoadStations(context.Background(), db, SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Stations = stations
+		load(t, db, opts, nil, testFile(
+			testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", nil),
+			testLine("EGLL 141150Z 24012KT 9999 FEW030 14/09 Q1012", "EGLL", "2026-10-14T11:50:00Z", nil),
+			// missing from the table
+			testLine("KXXX 141154Z 00000KT 10SM CLR 12/05 A2992", "KXXX", "2026-10-14T11:54:00Z", nil),
+		))
+
+		rows, err := db.Query("SELECT station, station_name, station_country, station_elevation_m FROM metars ORDER BY station")
+		if err != nil {
+			t.Fatal(err)
+		}
+		type enriched struct {
+			station       string
+			name, country sql.NullString
+			elevation     sql.NullFloat64
+		}
+		var got []enriched
+		for rows.Next() {
+			var e enriched
+			if err := rows.Scan(&e.station, &e.name, &e.country, &e.elevation); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, e)
+		}
+		rows.Close()
+		want := []enriched{
+			{"EGLL", sql.NullString{}, sql.NullString{String: "GB", Valid: true}, sql.NullFloat64{}},
+			{"KBOS", sql.NullString{String: "Boston/Logan Intl", Valid: true}, sql.NullString{String: "US", Valid: true}, sql.NullFloat64{Float64: 6, Valid: true}},
+			{"KXXX", sql.NullString{}, sql.NullString{}, sql.NullFloat64{}},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("batch %d: rows %v, want %v", batch, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("batch %d: row %v, want %v", batch, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestRowStationsOff(t *testing.T) {
+	line := testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", nil)
+	row, err := Row(SQLite, &metarcsv.Observation{Station: "KBOS", Parts: strings.Split(line, ",")}, &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"station_name", "station_country", "station_elevation_m"} {
+		if _, ok := row[col]; ok {
+			t.Errorf("Row set %s without Stations", col)
+		}
+	}
+}
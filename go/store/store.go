@@ -0,0 +1,243 @@
+// Package store writes observations to the databases the scrapers keep them
+// in: Postgres (with the migrations in sql/), SQLite, MySQL and ClickHouse.
+// Each is upserted on its key, so writing an observation twice leaves one
+// row, and metars_latest holds each station's newest observation.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"mattdee123.com/aviationweather/metar"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// tracer is used for the spans around batch inserts.
+var tracer = otel.Tracer("mattdee123.com/aviationweather/store")
+
+// endSpan ends span, marking it failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Store is somewhere observations are written.  Each file is written
+// between a Begin and a Commit; Rollback discards anything not committed and
+// is a no-op after a Commit.  Begin's ctx is the parent of any trace spans
+// the store makes; transactions aren't bound to it, so that a caller
+// shutting down can still commit after it's cancelled.
+type Store interface {
+	Begin(ctx context.Context) error
+	Write(obs *metarcsv.Observation) error
+	Commit() error
+	Rollback()
+}
+
+// Options are what's written of each observation, and how.  The zero value
+// writes the base columns of metars one row at a time.
+type Options struct {
+	// Latest also upserts each row into metars_latest.
+	Latest bool
+	// Remarks stores the decoded RMK section as JSON.
+	Remarks bool
+	// Supplementary stores decoded wind shear and sea groups as JSON.
+	Supplementary bool
+	// ClearSky stores the exact clear-sky indicator from raw_text.
+	ClearSky bool
+	// Pressure stores QNH and QFE decoded from raw_text.
+	Pressure bool
+	// FlightCategory stores the flight category computed from raw_text,
+	// rather than trusting the CSV's, which is often blank outside the US.
+	FlightCategory bool
+	// DensityAltitude stores pressure and density altitude, from the CSV's
+	// temperature, altimeter setting and elevation.
+	DensityAltitude bool
+	// TypedColumns stores the main CSV columns in typed columns as well as
+	// csv_parts.
+	TypedColumns bool
+	// Stations, if set, denormalizes each observation's station name, country
+	// and elevation from it, as LoadStations returns, into station_name,
+	// station_country and station_elevation_m.  They're NULL for a station
+	// that isn't in it.
+	Stations map[string]*Station
+	// Unparsed stores the groups of raw_text the decoder didn't recognize as
+	// JSON, decoded as Strictness says, so that nothing in a nonstandard
+	// report is silently lost.
+	Unparsed   bool
+	Strictness metar.Strictness
+	// SkipUnchanged leaves existing rows with the same content_hash alone,
+	// rather than rewriting them with identical data.
+	SkipUnchanged bool
+	// KeyMetarType keys metars on metar_type as well, which must match the
+	// table's unique constraint (see sql/metar_type_key.sql).
+	KeyMetarType bool
+	// BatchSize, if more than 1, buffers rows and writes them this many at a
+	// time with multi-row inserts.
+	BatchSize int
+	// Copy loads Postgres with COPY, which takes precedence over BatchSize.
+	Copy bool
+}
+
+// ConflictKey returns the metars columns that identify an observation.
+func (o *Options) ConflictKey() []string {
+	if o.KeyMetarType {
+		return []string{"station", "observation_time", "metar_type"}
+	}
+	return []string{"station", "observation_time"}
+}
+
+// New returns the store for db, given its dialect.  A nil opts is the zero
+// Options.
+func New(db *sql.DB, d *Dialect, opts *Options) Store {
+	if opts == nil {
+		opts = &Options{}
+	}
+	switch {
+	case d == ClickHouse:
+		return &clickhouseStore{db: db}
+	case d == Postgres && opts.Copy:
+		return &copyStore{db: db, opts: opts}
+	}
+	return &sqlStore{db: db, dialect: d, opts: opts}
+}
+
+// sqlStore upserts into a Postgres, SQLite or MySQL database, in a
+// transaction per file.
+type sqlStore struct {
+	db      *sql.DB
+	dialect *Dialect
+	tx      *sql.Tx
+	opts    *Options
+	pending []map[string]interface{}
+	// traceCtx is Begin's ctx, for the batch spans.
+	traceCtx context.Context
+}
+
+func (s *sqlStore) Begin(ctx context.Context) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	s.tx = tx
+	s.traceCtx = ctx
+	s.pending = nil
+	return nil
+}
+
+func (s *sqlStore) Write(obs *metarcsv.Observation) error {
+	if s.opts.BatchSize <= 1 {
+		return writeObservation(s.tx, s.dialect, obs, s.opts)
+	}
+	row, err := Row(s.dialect, obs, s.opts)
+	if err != nil {
+		return err
+	}
+	s.pending = append(s.pending, row)
+	if len(s.pending) >= s.opts.BatchSize {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows.
+func (s *sqlStore) flush() error {
+	rows := s.pending
+	s.pending = nil
+	if len(rows) == 0 {
+		return nil
+	}
+	_, span := tracer.Start(s.traceCtx, "insert batch", trace.WithAttributes(attribute.Int("rows", len(rows))))
+	err := s.insertBatch(rows, s.opts)
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("writing batch of %d: %w", len(rows), err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Commit() error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if err := s.tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Rollback() {
+	if s.tx != nil {
+		s.tx.Rollback()
+	}
+}
+
+// clickhouseStore batches rows through a prepared insert, which is sent
+// when the transaction commits.
+type clickhouseStore struct {
+	db   *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+}
+
+func (s *clickhouseStore) Begin(ctx context.Context) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	s.tx = tx
+	if s.stmt, err = tx.Prepare(clickhouseInsert); err != nil {
+		return fmt.Errorf("preparing batch: %w", err)
+	}
+	return nil
+}
+
+func (s *clickhouseStore) Write(obs *metarcsv.Observation) error {
+	return writeClickHouse(s.stmt, obs)
+}
+
+func (s *clickhouseStore) Commit() error {
+	if err := s.tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+	return nil
+}
+
+func (s *clickhouseStore) Rollback() {
+	if s.tx != nil {
+		s.tx.Rollback()
+	}
+}
+
+// writeObservation upserts obs.
+func writeObservation(tx *sql.Tx, d *Dialect, obs *metarcsv.Observation, opts *Options) error {
+	row, err := Row(d, obs, opts)
+	if err != nil {
+		return err
+	}
+	_, err = d.Builder.Insert("metars").SetMap(row).
+		Suffix(d.Upsert("metars", row, opts.ConflictKey(), false, opts.SkipUnchanged)).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return err
+	}
+	if opts.Latest {
+		// only move forward, so an older file can't clobber a newer observation
+		_, err = d.Builder.Insert("metars_latest").SetMap(row).
+			Suffix(d.Upsert("metars_latest", row, []string{"station"}, true, opts.SkipUnchanged)).
+			RunWith(tx).
+			Exec()
+		if err != nil {
+			return fmt.Errorf("updating latest: %w", err)
+		}
+	}
+	return nil
+}
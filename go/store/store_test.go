@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// testDB returns a new SQLite database with metars keyed as opts says.
+func testDB(t *testing.T, opts *Options) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(SQLite.Driver, filepath.Join(t.TempDir(), "metars.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := CreateSchema(context.Background(), db, SQLite, opts.ConflictKey()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// testLine is a metars cache file line for station at time, with the rest of
+// the columns empty but for any in fields.
+func testLine(raw, station, time string, fields map[int]string) string {
+	parts := make([]string, len(metarcsv.Columns))
+	parts[metarcsv.ColRawText] = raw
+	parts[metarcsv.ColStation] = station
+	parts[metarcsv.ColObservationTime] = time
+	for i, value := range fields {
+		parts[i] = value
+	}
+	return strings.Join(parts, ",")
+}
+
+// testFile is a metars cache file of lines.
+func testFile(lines ...string) string {
+	return "No errors\nNo warnings\n5 ms\ndata source=metars\n" +
+		strconv.Itoa(len(lines)) + " results\n" +
+		metarcsv.Header + "\n" +
+		strings.Join(lines, "\n") + "\n"
+}
+
+// load reads file with ropts and writes it to db with opts, returning how
+// many observations were written.
+func load(t *testing.T, db *sql.DB, opts *Options, ropts *metarcsv.Options, file string) int {
+	t.Helper()
+	records, err := metarcsv.Open(strings.NewReader(file), "csv", ropts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := Load(context.Background(), New(db, SQLite, opts), records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+type storedRow struct {
+	station string
+	time    time.Time
+	raw     string
+}
+
+// storedRows returns metars's rows, by station and time.
+func storedRows(t *testing.T, db *sql.DB) []storedRow {
+	t.Helper()
+	rows, err := db.Query("SELECT station, observation_time, csv_parts FROM metars ORDER BY station, observation_time, metar_type")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var stored []storedRow
+	for rows.Next() {
+		var r storedRow
+		var parts []string
+		if err := rows.Scan(&r.station, &r.time, SQLite.ScanArray(&parts)); err != nil {
+			t.Fatal(err)
+		}
+		r.raw = parts[metarcsv.ColRawText]
+		stored = append(stored, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestAliases(t *testing.T) {
+	for _, batch := range []int{0, 10} {
+		opts := &Options{BatchSize: batch}
+		db := testDB(t, opts)
+		aliases := &metarcsv.Options{Aliases: map[string]string{"PAFA": "PAFB"}}
+		n := load(t, db, opts, aliases, testFile(
+			testLine("PAFA 141153Z 00000KT 10SM CLR M05/M08 A3012", "PAFA", "2026-10-14T11:53:00Z", nil),
+			testLine("KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992", "KBOS", "2026-10-14T11:54:00Z", nil),
+			// the new code's report at the same time collides with the
+			// aliased one, and the later in the file wins
+			testLine("PAFB 141153Z 00000KT 10SM FEW100 M05/M08 A3012", "PAFB", "2026-10-14T11:53:00Z", nil),
+			testLine("PAFA 141253Z 00000KT 10SM CLR M05/M08 A3013", "PAFA", "2026-10-14T12:53:00Z", nil),
+		))
+		if n != 4 {
+			t.Errorf("batch %d: wrote %d, want 4", batch, n)
+		}
+		got := storedRows(t, db)
+		want := []storedRow{
+			{"KBOS", time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC), "KBOS 141154Z 27010KT 10SM FEW050 12/05 A2992"},
+			{"PAFB", time.Date(2026, 10, 14, 11, 53, 0, 0, time.UTC), "PAFB 141153Z 00000KT 10SM FEW100 M05/M08 A3012"},
+			{"PAFB", time.Date(2026, 10, 14, 12, 53, 0, 0, time.UTC), "PAFA 141253Z 00000KT 10SM CLR M05/M08 A3013"},
+		}
+		if !equalRows(got, want) {
+			t.Errorf("batch %d: rows %v, want %v", batch, got, want)
+		}
+	}
+}
+
+func equalRows(got, want []storedRow) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].station != want[i].station || !got[i].time.Equal(want[i].time) || got[i].raw != want[i].raw {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRoundTimeCollision(t *testing.T) {
+	for _, batch := range []int{0, 10} {
+		opts := &Options{BatchSize: batch, Latest: true}
+		db := testDB(t, opts)
+		n := load(t, db, opts, &metarcsv.Options{RoundTime: time.Minute}, testFile(
+			testLine("KBOS 141152Z 27010KT 3SM BR OVC010 12/11 A2992", "KBOS", "2026-10-14T11:52:40Z", nil),
+			// rounds to the same minute as the first, and wins as the later
+			// of the two in the file
+			testLine("KBOS 141153Z 27010KT 2SM BR OVC008 12/11 A2992", "KBOS", "2026-10-14T11:53:10Z", nil),
+			testLine("KBOS 141154Z 27010KT 5SM BR OVC012 12/11 A2992", "KBOS", "2026-10-14T11:54:00Z", nil),
+		))
+		if n != 3 {
+			t.Errorf("batch %d: wrote %d, want 3", batch, n)
+		}
+		got := storedRows(t, db)
+		want := []storedRow{
+			{"KBOS", time.Date(2026, 10, 14, 11, 53, 0, 0, time.UTC), "KBOS 141153Z 27010KT 2SM BR OVC008 12/11 A2992"},
+			{"KBOS", time.Date(2026, 10, 14, 11, 54, 0, 0, time.UTC), "KBOS 141154Z 27010KT 5SM BR OVC012 12/11 A2992"},
+		}
+		if !equalRows(got, want) {
+			t.Errorf("batch %d: rows %v, want %v", batch, got, want)
+		}
+	}
+}
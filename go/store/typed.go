@@ -0,0 +1,104 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"mattdee123.com/aviationweather/metarcsv"
+)
+
+// columnKind is how a typed column is converted from its CSV text.
+type columnKind int
+
+const (
+	kindReal columnKind = iota
+	kindInteger
+	kindBoolean
+	kindText
+)
+
+// typedColumns are the metars file columns stored in their own typed column
+// with Options.TypedColumns, under the same name as in the file.  The
+// sky_cover and cloud_base_ft_agl pairs go in cloud_layers instead.
+var typedColumns = []struct {
+	name string
+	kind columnKind
+}{
+	{"latitude", kindReal},
+	{"longitude", kindReal},
+	{"temp_c", kindReal},
+	{"dewpoint_c", kindReal},
+	{"wind_dir_degrees", kindInteger},
+	{"wind_speed_kt", kindInteger},
+	{"wind_gust_kt", kindInteger},
+	{"visibility_statute_mi", kindReal},
+	{"altim_in_hg", kindReal},
+	{"sea_level_pressure_mb", kindReal},
+	{"corrected", kindBoolean},
+	{"auto", kindBoolean},
+	{"wx_string", kindText},
+	{"vert_vis_ft", kindInteger},
+	{"elevation_m", kindReal},
+}
+
+// CloudLayer is an element of the cloud_layers column.
+type CloudLayer struct {
+	SkyCover       string `json:"sky_cover"`
+	CloudBaseFtAGL *int   `json:"cloud_base_ft_agl,omitempty"`
+}
+
+// SetTypedColumns adds typedColumns and cloud_layers to row.  Values that are
+// empty or don't parse are stored as NULL.
+func SetTypedColumns(row map[string]interface{}, obs *metarcsv.Observation) error {
+	for _, col := range typedColumns {
+		i := metarcsv.ColumnIndex[col.name]
+		row[col.name] = nil
+		switch col.kind {
+		case kindReal:
+			if value, ok := obs.Number(i); ok {
+				row[col.name] = value
+			}
+		case kindInteger:
+			if value, err := strconv.Atoi(obs.Field(i)); err == nil {
+				row[col.name] = value
+			}
+		case kindBoolean:
+			// the file leaves flags empty when false
+			row[col.name] = strings.EqualFold(obs.Field(i), "TRUE")
+		case kindText:
+			if value := obs.Field(i); value != "" {
+				row[col.name] = value
+			}
+		}
+	}
+	var layers []CloudLayer
+	for n := 1; ; n++ {
+		suffix := ""
+		if n > 1 {
+			suffix = fmt.Sprintf("_%d", n)
+		}
+		i, ok := metarcsv.ColumnIndex["sky_cover"+suffix]
+		if !ok {
+			break
+		}
+		layer := CloudLayer{SkyCover: obs.Field(i)}
+		if layer.SkyCover == "" {
+			continue
+		}
+		if base, err := strconv.Atoi(obs.Field(metarcsv.ColumnIndex["cloud_base_ft_agl"+suffix])); err == nil {
+			layer.CloudBaseFtAGL = &base
+		}
+		layers = append(layers, layer)
+	}
+	row["cloud_layers"] = nil
+	if layers != nil {
+		encoded, err := json.Marshal(layers)
+		if err != nil {
+			return fmt.Errorf("encoding cloud_layers: %w", err)
+		}
+		row["cloud_layers"] = string(encoded)
+	}
+	return nil
+}
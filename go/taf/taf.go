@@ -0,0 +1,165 @@
+// Package taf decodes raw TAF reports into forecast periods.
+package taf
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mattdee123.com/aviationweather/metar"
+)
+
+// Kinds of forecast period.
+const (
+	// KindBase is the initial forecast, before any change groups.
+	KindBase = "BASE"
+	// KindFM is a FM (from) group, which replaces the forecast entirely from
+	// its start time.
+	KindFM = "FM"
+	// KindBECMG is a gradual change over the period, which then persists.
+	KindBECMG = "BECMG"
+	// KindTEMPO is a temporary fluctuation during the period.
+	KindTEMPO = "TEMPO"
+	// KindPROB is a PROB30 or PROB40 group without TEMPO.
+	KindPROB = "PROB"
+)
+
+// Time is a day of month and UTC time.  TAFs don't carry a month or year, and
+// use hour 24 for the end of a day.
+type Time struct {
+	Day    int `json:"day"`
+	Hour   int `json:"hour"`
+	Minute int `json:"minute"`
+}
+
+// TAF is a decoded TAF.  Periods are in the order they appear, starting with
+// the base forecast.
+type TAF struct {
+	Station   string   `json:"station"`
+	Issued    Time     `json:"issued"`
+	ValidFrom Time     `json:"valid_from"`
+	ValidTo   Time     `json:"valid_to"`
+	Amended   bool     `json:"amended,omitempty"`
+	Corrected bool     `json:"corrected,omitempty"`
+	Periods   []Period `json:"periods"`
+	Remarks   string   `json:"remarks,omitempty"`
+}
+
+// Period is one forecast period.  An FM period runs to the start of the next
+// FM period, or the end of the TAF; the other kinds give their own window.
+type Period struct {
+	Kind string `json:"kind"`
+	// Probability is 30 or 40 for a PROB group (including PROB TEMPO), and 0
+	// otherwise.
+	Probability int  `json:"probability,omitempty"`
+	From        Time `json:"from"`
+	To          Time `json:"to"`
+	metar.Conditions
+	Unparsed []string `json:"unparsed,omitempty"`
+}
+
+var (
+	issuedPattern = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	windowPattern = regexp.MustCompile(`^(\d{2})(\d{2})/(\d{2})(\d{2})$`)
+	fmPattern     = regexp.MustCompile(`^FM(\d{2})(\d{2})(\d{2})$`)
+	probPattern   = regexp.MustCompile(`^PROB(\d{2})$`)
+)
+
+// ErrNotTAF is returned by Decode for text without a station, issue time and
+// valid period.
+var ErrNotTAF = errors.New("not a TAF: missing station, issue time or valid period")
+
+// Decode decodes the raw TAF text raw.
+func Decode(raw string) (*TAF, error) {
+	groups := strings.Fields(raw)
+	t := &TAF{}
+	for len(groups) > 0 && (groups[0] == "TAF" || groups[0] == "AMD" || groups[0] == "COR") {
+		t.Amended = t.Amended || groups[0] == "AMD"
+		t.Corrected = t.Corrected || groups[0] == "COR"
+		groups = groups[1:]
+	}
+	if len(groups) < 3 || !issuedPattern.MatchString(groups[1]) || !windowPattern.MatchString(groups[2]) {
+		return nil, ErrNotTAF
+	}
+	t.Station = groups[0]
+	m := issuedPattern.FindStringSubmatch(groups[1])
+	t.Issued = Time{atoi(m[1]), atoi(m[2]), atoi(m[3])}
+	t.ValidFrom, t.ValidTo = parseWindow(groups[2])
+	groups = groups[3:]
+
+	for i, group := range groups {
+		if group == "RMK" {
+			t.Remarks = strings.Join(groups[i+1:], " ")
+			groups = groups[:i]
+			break
+		}
+	}
+
+	current := &Period{Kind: KindBase, From: t.ValidFrom, To: t.ValidTo}
+	var body []string
+	finish := func() {
+		current.Conditions, current.Unparsed = metar.DecodeConditions(body, current.Kind != KindBase)
+		t.Periods = append(t.Periods, *current)
+		body = nil
+	}
+	for i := 0; i < len(groups); i++ {
+		group := groups[i]
+		next := &Period{To: t.ValidTo}
+		switch {
+		case fmPattern.MatchString(group):
+			m := fmPattern.FindStringSubmatch(group)
+			next.Kind = KindFM
+			next.From = Time{atoi(m[1]), atoi(m[2]), atoi(m[3])}
+		case group == KindBECMG || group == KindTEMPO:
+			next.Kind = group
+			i += readWindow(groups[i+1:], next)
+		case probPattern.MatchString(group):
+			next.Kind = KindPROB
+			next.Probability = atoi(probPattern.FindStringSubmatch(group)[1])
+			if i+1 < len(groups) && groups[i+1] == KindTEMPO {
+				next.Kind = KindTEMPO
+				i++
+			}
+			i += readWindow(groups[i+1:], next)
+		default:
+			body = append(body, group)
+			continue
+		}
+		finish()
+		current = next
+	}
+	finish()
+	// FM periods run until the next one
+	lastFM := -1
+	for i := range t.Periods {
+		if t.Periods[i].Kind != KindFM && t.Periods[i].Kind != KindBase {
+			continue
+		}
+		if lastFM >= 0 {
+			t.Periods[lastFM].To = t.Periods[i].From
+		}
+		lastFM = i
+	}
+	return t, nil
+}
+
+// readWindow reads the ddhh/ddhh window at the start of groups into p,
+// returning how many groups it used.
+func readWindow(groups []string, p *Period) int {
+	if len(groups) == 0 || !windowPattern.MatchString(groups[0]) {
+		return 0
+	}
+	p.From, p.To = parseWindow(groups[0])
+	return 1
+}
+
+func parseWindow(group string) (Time, Time) {
+	m := windowPattern.FindStringSubmatch(group)
+	return Time{Day: atoi(m[1]), Hour: atoi(m[2])}, Time{Day: atoi(m[3]), Hour: atoi(m[4])}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,98 @@
+package taf
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDecodePeriods(t *testing.T) {
+	taf, err := Decode("TAF EGLL 261700Z 2618/2724 24010KT 9999 SCT030 " +
+		"TEMPO 2618/2622 4000 -SHRA BKN012 " +
+		"BECMG 2700/2703 3000 BR " +
+		"PROB30 TEMPO 2706/2709 0800 FG " +
+		"PROB40 2712/2715 1500 RA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taf.Station != "EGLL" || taf.Issued != (Time{26, 17, 0}) || taf.ValidFrom != (Time{26, 18, 0}) || taf.ValidTo != (Time{27, 24, 0}) {
+		t.Errorf("header = %s %+v %+v-%+v", taf.Station, taf.Issued, taf.ValidFrom, taf.ValidTo)
+	}
+	want := []struct {
+		kind        string
+		probability int
+		from, to    Time
+		meters      int
+	}{
+		{KindBase, 0, Time{26, 18, 0}, Time{27, 24, 0}, 9999},
+		{KindTEMPO, 0, Time{26, 18, 0}, Time{26, 22, 0}, 4000},
+		{KindBECMG, 0, Time{27, 0, 0}, Time{27, 3, 0}, 3000},
+		{KindTEMPO, 30, Time{27, 6, 0}, Time{27, 9, 0}, 800},
+		{KindPROB, 40, Time{27, 12, 0}, Time{27, 15, 0}, 1500},
+	}
+	if len(taf.Periods) != len(want) {
+		t.Fatalf("got %d periods, want %d: %+v", len(taf.Periods), len(want), taf.Periods)
+	}
+	for i, w := range want {
+		p := taf.Periods[i]
+		if p.Kind != w.kind || p.Probability != w.probability || p.From != w.from || p.To != w.to {
+			t.Errorf("period %d = %s %d %+v-%+v, want %s %d %+v-%+v", i, p.Kind, p.Probability, p.From, p.To, w.kind, w.probability, w.from, w.to)
+		}
+		if p.Visibility == nil || p.Visibility.Meters == nil || *p.Visibility.Meters != w.meters {
+			t.Errorf("period %d visibility = %+v, want %d m", i, p.Visibility, w.meters)
+		}
+		if len(p.Unparsed) > 0 {
+			t.Errorf("period %d unparsed %q", i, p.Unparsed)
+		}
+	}
+	if got := taf.Periods[1].Weather; len(got) != 1 || got[0].Intensity != "-" || !slices.Equal(got[0].Phenomena, []string{"RA"}) {
+		t.Errorf("TEMPO weather = %+v, want -SHRA", got)
+	}
+}
+
+func TestDecodeFMChaining(t *testing.T) {
+	taf, err := Decode("TAF AMD KBOS 261730Z 2618/2724 27012KT P6SM SCT050 " +
+		"FM262200 30008KT P6SM BKN040 " +
+		"TEMPO 2700/2704 3SM -RA " +
+		"FM270600 VRB03KT 2SM BR OVC008 " +
+		"FM271500 32015G25KT P6SM FEW040 RMK NXT FCST BY 00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !taf.Amended {
+		t.Error("AMD not decoded")
+	}
+	if taf.Remarks != "NXT FCST BY 00Z" {
+		t.Errorf("remarks = %q", taf.Remarks)
+	}
+	want := []struct {
+		kind     string
+		from, to Time
+	}{
+		{KindBase, Time{26, 18, 0}, Time{26, 22, 0}},
+		{KindFM, Time{26, 22, 0}, Time{27, 6, 0}},
+		{KindTEMPO, Time{27, 0, 0}, Time{27, 4, 0}},
+		{KindFM, Time{27, 6, 0}, Time{27, 15, 0}},
+		{KindFM, Time{27, 15, 0}, Time{27, 24, 0}},
+	}
+	if len(taf.Periods) != len(want) {
+		t.Fatalf("got %d periods, want %d: %+v", len(taf.Periods), len(want), taf.Periods)
+	}
+	for i, w := range want {
+		p := taf.Periods[i]
+		if p.Kind != w.kind || p.From != w.from || p.To != w.to {
+			t.Errorf("period %d = %s %+v-%+v, want %s %+v-%+v", i, p.Kind, p.From, p.To, w.kind, w.from, w.to)
+		}
+		if len(p.Unparsed) > 0 {
+			t.Errorf("period %d unparsed %q", i, p.Unparsed)
+		}
+	}
+	if ceiling := taf.Periods[3].CeilingFt(); ceiling == nil || *ceiling != 800 {
+		t.Errorf("FM270600 ceiling = %v, want 800", ceiling)
+	}
+}
+
+func TestDecodeNotTAF(t *testing.T) {
+	if _, err := Decode("KBOS 261754Z 27012KT 10SM FEW050 12/05 A2992"); err != ErrNotTAF {
+		t.Errorf("METAR: err = %v, want ErrNotTAF", err)
+	}
+}
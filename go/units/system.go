@@ -0,0 +1,90 @@
+package units
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// System is a choice of units to present values in.
+type System string
+
+const (
+	// Metric is m/s, °C, hPa and metres.
+	Metric System = "metric"
+	// Imperial is mph, °F, inHg, statute miles and feet.
+	Imperial System = "imperial"
+	// Aviation is the units of US reports: knots, °C, inHg, statute miles
+	// for visibility and feet for heights.
+	Aviation System = "aviation"
+)
+
+// ParseSystem parses the name of a System.
+func ParseSystem(name string) (System, error) {
+	switch s := System(strings.ToLower(name)); s {
+	case Metric, Imperial, Aviation:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown units %q; want metric, imperial or aviation", name)
+}
+
+// Value is a value and its unit, rounded to a precision that suits the unit.
+type Value struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+func value(v float64, unit string, places int) Value {
+	return Value{Value: Round(v, places), Unit: unit}
+}
+
+func (v Value) String() string {
+	number := strconv.FormatFloat(v.Value, 'f', -1, 64)
+	if strings.HasPrefix(v.Unit, "°") {
+		return number + v.Unit
+	}
+	return number + " " + v.Unit
+}
+
+// Speed returns a speed given in knots in s's units.
+func (s System) Speed(kt float64) Value {
+	switch s {
+	case Metric:
+		return value(KnotsToMPS(kt), "m/s", 1)
+	case Imperial:
+		return value(KnotsToMPH(kt), "mph", 0)
+	}
+	return value(kt, "kt", 0)
+}
+
+// Temperature returns a temperature given in °C in s's units.
+func (s System) Temperature(c float64) Value {
+	if s == Imperial {
+		return value(CToF(c), "°F", 1)
+	}
+	return value(c, "°C", 1)
+}
+
+// Pressure returns a pressure given in inHg in s's units.
+func (s System) Pressure(inHg float64) Value {
+	if s == Metric {
+		return value(InHgToHPa(inHg), "hPa", 1)
+	}
+	return value(inHg, "inHg", 2)
+}
+
+// Visibility returns a visibility given in statute miles in s's units.
+func (s System) Visibility(mi float64) Value {
+	if s == Metric {
+		return value(MilesToMeters(mi), "m", 0)
+	}
+	return value(mi, "SM", 2)
+}
+
+// Height returns a height given in feet in s's units.
+func (s System) Height(ft float64) Value {
+	if s == Metric {
+		return value(FeetToMeters(ft), "m", 0)
+	}
+	return value(ft, "ft", 0)
+}
@@ -0,0 +1,89 @@
+// Package units converts between the units weather reports use, and chooses
+// units to present values in.
+package units
+
+import "math"
+
+const (
+	// MetersPerMile is the length of a statute mile.
+	MetersPerMile = 1609.344
+	// MetersPerFoot is the length of an international foot.
+	MetersPerFoot = 0.3048
+	// MetersPerNM is the length of a nautical mile.
+	MetersPerNM = 1852
+	// HPaPerInHg is the pressure of an inch of mercury.
+	HPaPerInHg = 33.8639
+	// HPaPerMmHg is the pressure of a millimetre of mercury.
+	HPaPerMmHg = 1.33322
+)
+
+// KnotsToMPS converts knots to metres per second.
+func KnotsToMPS(kt float64) float64 {
+	return kt * MetersPerNM / 3600
+}
+
+// MPSToKnots converts metres per second to knots.
+func MPSToKnots(mps float64) float64 {
+	return mps * 3600 / MetersPerNM
+}
+
+// KnotsToMPH converts knots to statute miles per hour.
+func KnotsToMPH(kt float64) float64 {
+	return kt * MetersPerNM / MetersPerMile
+}
+
+// MPHToKnots converts statute miles per hour to knots.
+func MPHToKnots(mph float64) float64 {
+	return mph * MetersPerMile / MetersPerNM
+}
+
+// KMHToKnots converts kilometres per hour to knots.
+func KMHToKnots(kmh float64) float64 {
+	return kmh * 1000 / MetersPerNM
+}
+
+// CToF converts degrees Celsius to Fahrenheit.
+func CToF(c float64) float64 {
+	return c*9/5 + 32
+}
+
+// FToC converts degrees Fahrenheit to Celsius.
+func FToC(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+// InHgToHPa converts inches of mercury to hectopascals.
+func InHgToHPa(inHg float64) float64 {
+	return inHg * HPaPerInHg
+}
+
+// HPaToInHg converts hectopascals to inches of mercury.
+func HPaToInHg(hPa float64) float64 {
+	return hPa / HPaPerInHg
+}
+
+// MilesToMeters converts statute miles to metres.
+func MilesToMeters(mi float64) float64 {
+	return mi * MetersPerMile
+}
+
+// MetersToMiles converts metres to statute miles.
+func MetersToMiles(m float64) float64 {
+	return m / MetersPerMile
+}
+
+// FeetToMeters converts feet to metres.
+func FeetToMeters(ft float64) float64 {
+	return ft * MetersPerFoot
+}
+
+// MetersToFeet converts metres to feet.
+func MetersToFeet(m float64) float64 {
+	return m / MetersPerFoot
+}
+
+// Round rounds v to places decimal places.
+func Round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
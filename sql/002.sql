@@ -0,0 +1,7 @@
+-- latest observation per station, maintained by the scraper when run with
+-- -latest.  rebuild from the archive with -rebuild-latest.
+CREATE TABLE metars_latest (
+    station text primary key,
+    observation_time timestamptz,
+    csv_parts text[]
+)
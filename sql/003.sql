@@ -0,0 +1,3 @@
+-- decoded RMK groups, written when the scraper is run with -remarks
+ALTER TABLE metars ADD COLUMN remarks jsonb;
+ALTER TABLE metars_latest ADD COLUMN remarks jsonb;
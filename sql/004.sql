@@ -0,0 +1,4 @@
+-- decoded wind shear and sea groups, written when the scraper is run with
+-- -supplementary
+ALTER TABLE metars ADD COLUMN supplementary jsonb;
+ALTER TABLE metars_latest ADD COLUMN supplementary jsonb;
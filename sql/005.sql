@@ -0,0 +1,4 @@
+-- exact clear-sky indicator (CLR, SKC, NSC, NCD or CAVOK), written when the
+-- scraper is run with -clear-sky
+ALTER TABLE metars ADD COLUMN clear_sky text;
+ALTER TABLE metars_latest ADD COLUMN clear_sky text;
@@ -0,0 +1,6 @@
+-- metar_type (METAR or SPECI) as its own column, so it can be part of the key.
+-- see metar_type_key.sql to key on it.
+ALTER TABLE metars ADD COLUMN metar_type text NOT NULL DEFAULT '';
+UPDATE metars SET metar_type = csv_parts[43] WHERE csv_parts[43] IS NOT NULL;
+ALTER TABLE metars_latest ADD COLUMN metar_type text NOT NULL DEFAULT '';
+UPDATE metars_latest SET metar_type = csv_parts[43] WHERE csv_parts[43] IS NOT NULL;
@@ -0,0 +1,4 @@
+-- QNH in both units and QFE, in hPa, written when the scraper is run with
+-- -pressure
+ALTER TABLE metars ADD COLUMN qnh_hpa real, ADD COLUMN qnh_inhg real, ADD COLUMN qfe_hpa real;
+ALTER TABLE metars_latest ADD COLUMN qnh_hpa real, ADD COLUMN qnh_inhg real, ADD COLUMN qfe_hpa real;
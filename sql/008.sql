@@ -0,0 +1,3 @@
+-- hash of the observation's fields, for detecting when a row really changed
+ALTER TABLE metars ADD COLUMN content_hash text;
+ALTER TABLE metars_latest ADD COLUMN content_hash text;
@@ -0,0 +1,11 @@
+-- written by taf_scraper.  forecasts holds one object per forecast period,
+-- with that period's non-empty columns.
+CREATE TABLE tafs (
+    station text,
+    issue_time timestamptz,
+    valid_time_from timestamptz,
+    valid_time_to timestamptz,
+    csv_parts text[],
+    forecasts jsonb,
+    primary key (station, issue_time)
+)
@@ -0,0 +1,13 @@
+-- written by pirep_scraper.  report_type is UA (routine) or UUA (urgent),
+-- from raw_text.
+CREATE TABLE pireps (
+    observation_time timestamptz,
+    receipt_time timestamptz,
+    raw_text text,
+    report_type text,
+    latitude double precision,
+    longitude double precision,
+    altitude_ft_msl double precision,
+    csv_parts text[],
+    primary key (observation_time, raw_text)
+)
@@ -0,0 +1,15 @@
+-- written by airsigmet_scraper.  points is the advisory area as a json array
+-- of [lon, lat] pairs, in the order given by the source.
+CREATE TABLE airsigmets (
+    valid_time_from timestamptz,
+    valid_time_to timestamptz,
+    raw_text text,
+    airsigmet_type text,
+    hazard text,
+    severity text,
+    min_ft_msl double precision,
+    max_ft_msl double precision,
+    points jsonb,
+    csv_parts text[],
+    primary key (valid_time_from, raw_text)
+)
@@ -0,0 +1,14 @@
+-- written by station_scraper.  station matches metars.station, so the two
+-- can be joined for station coordinates.  site_type lists the products the
+-- station reports, e.g. {METAR,TAF}.
+CREATE TABLE stations (
+    station text primary key,
+    site text,
+    latitude double precision,
+    longitude double precision,
+    elevation_m double precision,
+    state text,
+    country text,
+    site_type text[],
+    csv_parts text[]
+)
@@ -0,0 +1,17 @@
+-- written by windsaloft_scraper, one row per station and altitude of an FB
+-- winds and temperatures aloft forecast.  wind_dir_degrees is null for light
+-- and variable winds, and temp_c is null where the product doesn't forecast
+-- one (e.g. 3000 ft).  wind_group is the undecoded group.
+CREATE TABLE winds_aloft (
+    station text,
+    valid_time timestamptz,
+    altitude_ft integer,
+    based_on timestamptz,
+    use_from timestamptz,
+    use_to timestamptz,
+    wind_group text,
+    wind_dir_degrees integer,
+    wind_speed_kt integer,
+    temp_c integer,
+    primary key (station, valid_time, altitude_ft)
+)
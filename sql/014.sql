@@ -0,0 +1,4 @@
+-- VFR/MVFR/IFR/LIFR computed from raw_text, written when the scraper is run
+-- with -flight-category
+ALTER TABLE metars ADD COLUMN flight_category text;
+ALTER TABLE metars_latest ADD COLUMN flight_category text;
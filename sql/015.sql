@@ -0,0 +1,4 @@
+-- pressure and density altitude in feet, written when the scraper is run with
+-- -density-altitude
+ALTER TABLE metars ADD COLUMN pressure_altitude_ft integer, ADD COLUMN density_altitude_ft integer;
+ALTER TABLE metars_latest ADD COLUMN pressure_altitude_ft integer, ADD COLUMN density_altitude_ft integer;
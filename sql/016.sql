@@ -0,0 +1,48 @@
+-- typed copies of the main csv columns, written when the scraper is run with
+-- -typed-columns, so queries don't need csv_parts[n]::type.  cloud_layers is
+-- a json array of {sky_cover, cloud_base_ft_agl} in the order reported.
+-- existing rows are backfilled from csv_parts (note it's 1-indexed).
+ALTER TABLE metars
+    ADD COLUMN latitude real, ADD COLUMN longitude real,
+    ADD COLUMN temp_c real, ADD COLUMN dewpoint_c real,
+    ADD COLUMN wind_dir_degrees integer, ADD COLUMN wind_speed_kt integer, ADD COLUMN wind_gust_kt integer,
+    ADD COLUMN visibility_statute_mi real, ADD COLUMN altim_in_hg real, ADD COLUMN sea_level_pressure_mb real,
+    ADD COLUMN corrected boolean, ADD COLUMN auto boolean,
+    ADD COLUMN wx_string text, ADD COLUMN vert_vis_ft integer, ADD COLUMN elevation_m real,
+    ADD COLUMN cloud_layers jsonb;
+ALTER TABLE metars_latest
+    ADD COLUMN latitude real, ADD COLUMN longitude real,
+    ADD COLUMN temp_c real, ADD COLUMN dewpoint_c real,
+    ADD COLUMN wind_dir_degrees integer, ADD COLUMN wind_speed_kt integer, ADD COLUMN wind_gust_kt integer,
+    ADD COLUMN visibility_statute_mi real, ADD COLUMN altim_in_hg real, ADD COLUMN sea_level_pressure_mb real,
+    ADD COLUMN corrected boolean, ADD COLUMN auto boolean,
+    ADD COLUMN wx_string text, ADD COLUMN vert_vis_ft integer, ADD COLUMN elevation_m real,
+    ADD COLUMN cloud_layers jsonb;
+
+CREATE FUNCTION pg_temp.num(v text) RETURNS real AS $$
+    SELECT CASE WHEN rtrim(v, '+') ~ '^-?[0-9]+(\.[0-9]+)?$' THEN rtrim(v, '+')::real END
+$$ LANGUAGE sql IMMUTABLE;
+CREATE FUNCTION pg_temp.int(v text) RETURNS integer AS $$
+    SELECT CASE WHEN v ~ '^-?[0-9]+$' THEN v::integer END
+$$ LANGUAGE sql IMMUTABLE;
+CREATE FUNCTION pg_temp.layers(p text[]) RETURNS jsonb AS $$
+    SELECT jsonb_agg(jsonb_strip_nulls(jsonb_build_object('sky_cover', p[i], 'cloud_base_ft_agl', pg_temp.int(p[i + 1]))) ORDER BY i)
+    FROM unnest(ARRAY[23, 25, 27, 29]) i WHERE p[i] <> ''
+$$ LANGUAGE sql IMMUTABLE;
+
+UPDATE metars SET
+    latitude = pg_temp.num(csv_parts[4]), longitude = pg_temp.num(csv_parts[5]),
+    temp_c = pg_temp.num(csv_parts[6]), dewpoint_c = pg_temp.num(csv_parts[7]),
+    wind_dir_degrees = pg_temp.int(csv_parts[8]), wind_speed_kt = pg_temp.int(csv_parts[9]), wind_gust_kt = pg_temp.int(csv_parts[10]),
+    visibility_statute_mi = pg_temp.num(csv_parts[11]), altim_in_hg = pg_temp.num(csv_parts[12]), sea_level_pressure_mb = pg_temp.num(csv_parts[13]),
+    corrected = upper(coalesce(csv_parts[14], '')) = 'TRUE', auto = upper(coalesce(csv_parts[15], '')) = 'TRUE',
+    wx_string = NULLIF(csv_parts[22], ''), vert_vis_ft = pg_temp.int(csv_parts[42]), elevation_m = pg_temp.num(csv_parts[44]),
+    cloud_layers = pg_temp.layers(csv_parts);
+UPDATE metars_latest SET
+    latitude = pg_temp.num(csv_parts[4]), longitude = pg_temp.num(csv_parts[5]),
+    temp_c = pg_temp.num(csv_parts[6]), dewpoint_c = pg_temp.num(csv_parts[7]),
+    wind_dir_degrees = pg_temp.int(csv_parts[8]), wind_speed_kt = pg_temp.int(csv_parts[9]), wind_gust_kt = pg_temp.int(csv_parts[10]),
+    visibility_statute_mi = pg_temp.num(csv_parts[11]), altim_in_hg = pg_temp.num(csv_parts[12]), sea_level_pressure_mb = pg_temp.num(csv_parts[13]),
+    corrected = upper(coalesce(csv_parts[14], '')) = 'TRUE', auto = upper(coalesce(csv_parts[15], '')) = 'TRUE',
+    wx_string = NULLIF(csv_parts[22], ''), vert_vis_ft = pg_temp.int(csv_parts[42]), elevation_m = pg_temp.num(csv_parts[44]),
+    cloud_layers = pg_temp.layers(csv_parts);
@@ -0,0 +1,12 @@
+-- lines the scraper couldn't parse or store, written when it's run with
+-- -dead-letter.  stage is parse or insert.  line is the raw csv line, so rows
+-- can be re-processed after a fix.
+CREATE TABLE metar_errors (
+    recorded_at timestamptz,
+    source text,
+    line_number integer,
+    stage text,
+    line text,
+    error text
+);
+CREATE INDEX metar_errors_recorded_at ON metar_errors (recorded_at);
@@ -0,0 +1,15 @@
+-- one row per scraper run, written when it's run with -record-runs, so that
+-- monitoring can check for a recent successful run with a single query:
+--   SELECT max(finished_at) FROM scrape_runs WHERE product = 'metar' AND success
+-- error is the run's error if it failed.
+CREATE TABLE scrape_runs (
+    started_at timestamptz,
+    finished_at timestamptz,
+    product text,
+    success boolean,
+    error text,
+    lines_scanned integer,
+    rows_written integer,
+    rows_skipped integer
+);
+CREATE INDEX scrape_runs_product_finished_at ON scrape_runs (product, finished_at);
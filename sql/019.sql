@@ -0,0 +1,10 @@
+-- written by station_scraper -runways-url, two rows per runway, one for each
+-- end.  station matches stations.station.  heading_degrees is true, like
+-- METAR wind directions, and null where the source doesn't give it.
+CREATE TABLE runways (
+    station text,
+    runway text,
+    heading_degrees double precision,
+    length_ft double precision,
+    primary key (station, runway)
+)
@@ -0,0 +1,9 @@
+-- the station's name, country and elevation from the stations table,
+-- written when the scraper is run with -enrich-stations, and null for
+-- stations missing from it
+ALTER TABLE metars ADD COLUMN station_name text;
+ALTER TABLE metars ADD COLUMN station_country text;
+ALTER TABLE metars ADD COLUMN station_elevation_m double precision;
+ALTER TABLE metars_latest ADD COLUMN station_name text;
+ALTER TABLE metars_latest ADD COLUMN station_country text;
+ALTER TABLE metars_latest ADD COLUMN station_elevation_m double precision;
@@ -0,0 +1,4 @@
+-- the groups of raw_text the decoder didn't recognize, written when the
+-- scraper is run with -unparsed
+ALTER TABLE metars ADD COLUMN unparsed jsonb;
+ALTER TABLE metars_latest ADD COLUMN unparsed jsonb;
@@ -0,0 +1,7 @@
+-- optional: key metars on (station, observation_time, metar_type) so a
+-- routine METAR and a SPECI in the same minute are both kept.  run the
+-- scraper with -key-metar-type after applying this.  the unique constraint
+-- and the scraper's ON CONFLICT target have to agree, so this can't be
+-- mixed with scrapers running without the flag.
+ALTER TABLE metars DROP CONSTRAINT metars_pkey;
+ALTER TABLE metars ADD PRIMARY KEY (station, observation_time, metar_type);
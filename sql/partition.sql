@@ -0,0 +1,25 @@
+-- optional: make metars a table partitioned by month of observation_time.
+-- run by hand, after the numbered migrations.  rows with no partition for
+-- their month land in metars_default; run the scraper with
+-- -ensure-partitions to create each month's partition before it starts.
+-- partitions are named metars_YYYY_MM, in UTC.  after metar_type_key.sql,
+-- change the primary key below to match.
+SET TIME ZONE 'UTC';
+BEGIN;
+ALTER TABLE metars RENAME TO metars_unpartitioned;
+ALTER TABLE metars_unpartitioned RENAME CONSTRAINT metars_pkey TO metars_unpartitioned_pkey;
+CREATE TABLE metars (LIKE metars_unpartitioned INCLUDING DEFAULTS) PARTITION BY RANGE (observation_time);
+ALTER TABLE metars ADD PRIMARY KEY (station, observation_time);
+CREATE TABLE metars_default PARTITION OF metars DEFAULT;
+DO $$
+DECLARE
+    m timestamptz;
+BEGIN
+    FOR m IN SELECT generate_series(date_trunc('month', min(observation_time)), date_trunc('month', now()) + interval '1 month', interval '1 month') FROM metars_unpartitioned LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF metars FOR VALUES FROM (%L) TO (%L)', 'metars_' || to_char(m, 'YYYY_MM'), m, m + interval '1 month');
+    END LOOP;
+END
+$$;
+INSERT INTO metars SELECT * FROM metars_unpartitioned;
+COMMIT;
+-- once the copy is checked: DROP TABLE metars_unpartitioned;
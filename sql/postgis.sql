@@ -0,0 +1,12 @@
+-- optional: geography points for the station locations, with gist indexes,
+-- for radius and bounding box queries.  run by hand, after the numbered
+-- migrations, on a server with the postgis extension available.  the columns
+-- are generated from latitude and longitude, so the scrapers don't need to
+-- know about them, and serve uses them when they're there.
+CREATE EXTENSION IF NOT EXISTS postgis;
+ALTER TABLE metars_latest ADD COLUMN location geography(Point, 4326)
+    GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography) STORED;
+CREATE INDEX metars_latest_location ON metars_latest USING gist (location);
+ALTER TABLE stations ADD COLUMN location geography(Point, 4326)
+    GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography) STORED;
+CREATE INDEX stations_location ON stations USING gist (location);
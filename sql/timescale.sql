@@ -0,0 +1,13 @@
+-- optional: turn metars into a TimescaleDB hypertable partitioned on
+-- observation_time.  run by hand, after the numbered migrations, on a server
+-- with the timescaledb extension available.  existing rows are moved into
+-- chunks, which locks the table for a while on a big archive.
+-- metars_latest is small and stays a plain table.
+CREATE EXTENSION IF NOT EXISTS timescaledb;
+SELECT create_hypertable('metars', 'observation_time', chunk_time_interval => interval '7 days', migrate_data => true);
+
+-- compress chunks once they're a month old; a late report for a compressed
+-- chunk still upserts (timescaledb 2.11 and later), just more slowly.  leave
+-- this out to keep every chunk uncompressed.
+ALTER TABLE metars SET (timescaledb.compress, timescaledb.compress_segmentby = 'station', timescaledb.compress_orderby = 'observation_time DESC');
+SELECT add_compression_policy('metars', interval '30 days');